@@ -0,0 +1,58 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package agent
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/spf13/cobra"
+)
+
+// lockCmd represents the agent lock command
+var lockCmd = &cobra.Command{
+	Use:   "lock",
+	Short: "Wipe the vault from the agent memory and stop it.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+		_, path := socketPath()
+
+		client, err := agent.Connect(path)
+		if err != nil {
+			fmt.Println("No agent running.")
+			os.Exit(1)
+		}
+
+		if err := client.LockAgent(); err != nil {
+			fmt.Printf("Error locking the agent: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Agent locked")
+		os.Exit(0)
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(lockCmd)
+}
@@ -0,0 +1,62 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package agent
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// agentCmd represents the agent command
+var agentCmd = &cobra.Command{
+	Use:   "agent",
+	Short: "Keep the vault unlocked for a session",
+	Long: `The agent holds the unlocked vault of the active profile in memory, the vault
+and exec commands use it while it is running instead of opening the vault on
+every invocation. It listens on a socket only the user can connect to and stops
+after being idle for too long or when locked.`,
+}
+
+// socketPath returns the socket of the agent of the active profile.
+func socketPath() (*config.Config, string) {
+	cfg, err := config.ReadConfig(cmd.ConfigFile)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	path, err := agent.SocketPath(*cfg)
+	if err != nil {
+		fmt.Printf("Error locating the agent socket: %v\n", err)
+		os.Exit(1)
+	}
+	return cfg, path
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(agentCmd)
+}
@@ -0,0 +1,114 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package agent
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/spf13/cobra"
+)
+
+var idleTimeout time.Duration
+var foreground bool
+
+// startCmd represents the agent start command
+var startCmd = &cobra.Command{
+	Use:   "start",
+	Short: "Start the agent for the active profile.",
+	Long:  `Start the agent in the background for the vault of the active profile. The vault is opened once with the configured key, identity or ssh-agent, wrong credentials are reported before the agent starts. The agent stops after --idle without requests, 0 keeps it running until "agent lock".`,
+	Run: func(c *cobra.Command, args []string) {
+		cfg, path := socketPath()
+
+		if foreground {
+			// The agent holds the vault key for as long as it runs
+			if err := crypto.DisableDumps(); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: core dumps could not be disabled: %v\n", err)
+			}
+
+			server, err := agent.NewServer(*cfg, idleTimeout)
+			if err != nil {
+				fmt.Printf("Error opening the vault: %v\n", err)
+				os.Exit(1)
+			}
+
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			go func() {
+				<-signals
+				server.Lock()
+			}()
+
+			fmt.Printf("Agent listening on '%s'\n", path)
+			if err := server.ListenAndServe(path); err != nil {
+				fmt.Printf("Error running the agent: %v\n", err)
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		if client, err := agent.Connect(path); err == nil {
+			if status, err := client.Status(); err == nil {
+				fmt.Printf("Agent already running (pid %d)\n", status.PID)
+				os.Exit(0)
+			}
+		}
+
+		// The background agent reads the same configuration and profile
+		daemonArgs := []string{}
+		if cmd.ConfigFile != "" {
+			configFile, err := filepath.Abs(cmd.ConfigFile)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			daemonArgs = append(daemonArgs, "--config", configFile)
+		}
+		if config.Profile != "" {
+			daemonArgs = append(daemonArgs, "--profile", config.Profile)
+		}
+		daemonArgs = append(daemonArgs, "agent", "start", "--foreground", "--idle", idleTimeout.String())
+
+		pid, err := agent.Start(path, daemonArgs)
+		if err != nil {
+			fmt.Printf("Error starting the agent: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Agent started (pid %d)\n", pid)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(startCmd)
+	startCmd.Flags().DurationVar(&idleTimeout, "idle", 15*time.Minute, "Stop the agent after this long without requests, 0 disables it")
+	startCmd.Flags().BoolVar(&foreground, "foreground", false, "Run the agent in the foreground")
+}
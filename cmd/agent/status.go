@@ -0,0 +1,70 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package agent
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/spf13/cobra"
+)
+
+// statusCmd represents the agent status command
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the agent of the active profile.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+		_, path := socketPath()
+
+		client, err := agent.Connect(path)
+		if err != nil {
+			fmt.Println("No agent running.")
+			os.Exit(1)
+		}
+
+		status, err := client.Status()
+		if err != nil {
+			fmt.Printf("Error reading the agent status: %v\n", err)
+			os.Exit(1)
+		}
+
+		idle := "never"
+		if status.IdleTimeout > 0 {
+			idle = status.LastUsed.Add(status.IdleTimeout).Format(time.RFC3339)
+		}
+
+		fmt.Printf("Socket:   %s\n", path)
+		fmt.Printf("PID:      %d\n", status.PID)
+		fmt.Printf("Provider: %s\n", status.Provider)
+		fmt.Printf("Started:  %s\n", status.Started.Format(time.RFC3339))
+		fmt.Printf("Expires:  %s\n", idle)
+		fmt.Printf("Cached:   %t\n", status.Cached)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	agentCmd.AddCommand(statusCmd)
+}
@@ -0,0 +1,75 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package audit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// auditCmd represents the audit command
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Inspect the audit log",
+	Long: `Every operation of the vault, exec, ssh, file, values and git driver commands
+on the vault or its key is appended to the audit log with the keys involved,
+never their values. Each entry holds the hash of the previous one, keyed with a
+key stored in its own file, so changing or removing entries breaks the chain.
+The default key sits next to the log, so a local-only log only detects changes
+by other users, set key_file elsewhere or forward the entries to syslog.
+With audit.required set, operations that can't be recorded fail.`,
+}
+
+// logPath returns the audit log of the active profile.
+func logPath() string {
+	cfg, err := config.ReadConfig(cmd.ConfigFile)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		os.Exit(1)
+	}
+
+	path := audit.LogPath(cfg)
+	if path == "" {
+		fmt.Println("The audit log is disabled.")
+		os.Exit(1)
+	}
+	return path
+}
+
+// keyPath returns the key file of the audit log of the active profile.
+func keyPath() string {
+	cfg, err := config.ReadConfig(cmd.ConfigFile)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		os.Exit(1)
+	}
+	return audit.KeyPath(cfg)
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(auditCmd)
+}
@@ -0,0 +1,88 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package audit
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/a13labs/sectool/internal/audit"
+	"github.com/a13labs/sectool/internal/vault"
+	"github.com/spf13/cobra"
+)
+
+var showKey string
+var showSince string
+
+// showCmd represents the audit show command
+var showCmd = &cobra.Command{
+	Use:   "show",
+	Short: "Show the entries of the audit log.",
+	Long: `Show the entries of the audit log, oldest first. --key only shows the entries
+involving a key and --since the ones newer than a duration, e.g. 7d.`,
+	Run: func(c *cobra.Command, args []string) {
+		path := logPath()
+
+		var since time.Time
+		if showSince != "" {
+			d, err := vault.ParseDuration(showSince)
+			if err != nil {
+				fmt.Printf("Error: %v\n", err)
+				os.Exit(1)
+			}
+			since = time.Now().Add(-d)
+		}
+
+		entries, err := audit.Read(path)
+		if err != nil {
+			fmt.Printf("Error reading audit log '%s': %v\n", path, err)
+			os.Exit(1)
+		}
+
+		for _, entry := range entries {
+			if showKey != "" && !entry.HasKey(showKey) {
+				continue
+			}
+			if entry.Time.Before(since) {
+				continue
+			}
+
+			line := fmt.Sprintf("%s %s@%s %q %s", entry.Time.Format(time.RFC3339), entry.User, entry.Host, entry.Command, entry.Operation)
+			if len(entry.Keys) > 0 {
+				line += " " + strings.Join(entry.Keys, ",")
+			}
+			if entry.Error != "" {
+				line += fmt.Sprintf(" error: %s", entry.Error)
+			}
+			fmt.Println(line)
+		}
+		os.Exit(0)
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(showCmd)
+	showCmd.Flags().StringVar(&showKey, "key", "", "Only show the entries involving the key")
+	showCmd.Flags().StringVar(&showSince, "since", "", "Only show the entries newer than the duration, e.g. 7d")
+}
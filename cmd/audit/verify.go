@@ -0,0 +1,64 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package audit
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/internal/audit"
+	"github.com/spf13/cobra"
+)
+
+// verifyCmd represents the audit verify command
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check the chain of the audit log.",
+	Long: `Check the chain of the audit log with its key and print the hash of the last
+entry. Removing the last entries keeps the chain valid, compare the head with
+the hash of the last event sent to syslog.`,
+	Run: func(c *cobra.Command, args []string) {
+		path, keyPath := logPath(), keyPath()
+
+		key, err := audit.ReadKey(keyPath, false)
+		if err != nil {
+			fmt.Printf("Error reading audit key '%s': %v\n", keyPath, err)
+			os.Exit(1)
+		}
+
+		count, head, err := audit.Verify(path, key)
+		if err != nil {
+			fmt.Printf("Error verifying audit log '%s': %v\n", path, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Audit log '%s' verified, %d entries.\n", path, count)
+		if head != "" {
+			fmt.Printf("Head: %s\n", head)
+		}
+		os.Exit(0)
+	},
+}
+
+func init() {
+	auditCmd.AddCommand(verifyCmd)
+}
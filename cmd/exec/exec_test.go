@@ -25,7 +25,9 @@ import (
 	"fmt"
 	osExec "os/exec"
 	"sort"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/a13labs/sectool/cmd/exec"
 	"github.com/a13labs/sectool/internal/crypto"
@@ -146,3 +148,83 @@ func TestExec(t *testing.T) {
 		t.Errorf("Expected %q, but got %q", expectedOutput, string(output))
 	}
 }
+
+func TestExpiredSecrets(t *testing.T) {
+	envFile := "test.env"
+	km := crypto.NewKeyManager()
+	vaultProvider := vault.NewDummyVault()
+	vaultProvider.VaultSetValueWithMetadata("SECRET1", "secret_value1", vault.SecretMetadata{Expires: time.Now().Add(-time.Hour)})
+	vaultProvider.VaultSetValueWithMetadata("SECRET2", "secret_value2", vault.SecretMetadata{Expires: time.Now().Add(time.Hour)})
+	vaultProvider.VaultSetValue("SECRET3", "This is a\nmultiline secret")
+	vaultProvider.VaultSetValueWithMetadata("UNUSED", "unused", vault.SecretMetadata{Expires: time.Now().Add(-time.Hour)})
+	env, _, err := exec.ParseEnvFile(envFile, vaultProvider, km)
+	if err != nil {
+		t.Errorf("Error parsing env file: %v", err)
+	}
+	expired, err := exec.ExpiredSecrets(env, vaultProvider, time.Now())
+	if err != nil {
+		t.Errorf("Error checking expiry: %v", err)
+	}
+	if len(expired) != 1 || expired[0] != "SECRET1" {
+		t.Errorf("Expected only SECRET1 to be expired, but got %v", expired)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	envFile := "namespace.env"
+	km := crypto.NewKeyManager()
+	vaultProvider := vault.NewDummyVault()
+	vaultProvider.VaultSetValue("DB_PASSWORD", "root_password")
+	vaultProvider.VaultSetValue("payments/prod/DB_PASSWORD", "payments_password")
+	vaultProvider.VaultSetValue("API_KEY", "api_key")
+	vaultProvider.VaultSetValue("billing/DB_PASSWORD", "billing_password")
+	expectedEnv := []string{"DB=payments_password", "OTHER=billing_password", "SHARED=api_key"}
+	env, kv, err := exec.ParseEnvFile(envFile, vaultProvider, km)
+	if err != nil {
+		t.Errorf("Error parsing env file: %v", err)
+	}
+	composedEnv, err := exec.ComposeEnv(env, kv)
+	if err != nil {
+		t.Errorf("Error composing env: %v", err)
+	}
+	sort.Strings(composedEnv)
+	if len(composedEnv) != len(expectedEnv) {
+		t.Fatalf("Expected %v, but got %v", expectedEnv, composedEnv)
+	}
+	for i := range composedEnv {
+		if composedEnv[i] != expectedEnv[i] {
+			t.Errorf("Expected composedEnv[%d] %q, but got %q", i, expectedEnv[i], composedEnv[i])
+		}
+	}
+}
+
+func TestReferencedKeys(t *testing.T) {
+	km := crypto.NewKeyManager()
+	vaultProvider := vault.NewDummyVault()
+	vaultProvider.VaultSetValue("DB_PASSWORD", "root_password")
+	vaultProvider.VaultSetValue("payments/prod/DB_PASSWORD", "payments_password")
+	vaultProvider.VaultSetValue("API_KEY", "api_key")
+	vaultProvider.VaultSetValue("billing/DB_PASSWORD", "billing_password")
+	env, _, err := exec.ParseEnvFile("namespace.env", vaultProvider, km)
+	if err != nil {
+		t.Fatalf("Error parsing env file: %v", err)
+	}
+	expected := []string{"API_KEY", "billing/DB_PASSWORD", "payments/prod/DB_PASSWORD"}
+	keys := exec.ReferencedKeys(env)
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, but got %v", expected, keys)
+	}
+}
+
+func TestEnvFileKeys(t *testing.T) {
+	// The keys are known without reading the vault, for the audit log
+	expected := []string{"API_KEY", "DB_PASSWORD", "billing/DB_PASSWORD", "payments/prod/API_KEY", "payments/prod/DB_PASSWORD"}
+	keys := exec.EnvFileKeys("namespace.env")
+	if strings.Join(keys, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v, but got %v", expected, keys)
+	}
+
+	if keys := exec.EnvFileKeys("missing.env"); len(keys) != 0 {
+		t.Errorf("Expected no keys for a missing file, got %v", keys)
+	}
+}
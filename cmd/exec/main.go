@@ -27,9 +27,14 @@ import (
 	"os"
 	"os/exec"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	sectoolCrypto "github.com/a13labs/sectool/internal/crypto"
 	"github.com/a13labs/sectool/internal/vault"
@@ -38,12 +43,14 @@ import (
 
 var config_file = ""
 var no_output = false
+var strict = false
 var cfg *config.Config
 
 var execCmd = &cobra.Command{
 	Use:   "exec",
 	Short: "Execute a command",
-	Long:  `Execute a command with the environment variables from the .env file and the vault file.`,
+	Long: `Execute a command with the environment variables from the .env file and the vault file.
+Expired secrets are reported, with --strict the command is not run if any is used.`,
 	Run: func(c *cobra.Command, args []string) {
 		if len(args) == 0 {
 			fmt.Println("Usage: sectool exec <cmd> <args>")
@@ -52,6 +59,11 @@ var execCmd = &cobra.Command{
 
 		cmdToRun, cmdArgs := ProcessArgs(args)
 
+		// The secrets stay in memory while the command runs
+		if err := sectoolCrypto.DisableDumps(); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: core dumps could not be disabled: %v\n", err)
+		}
+
 		var err error
 		cfg, err = config.ReadConfig(cmd.ConfigFile)
 		if err != nil {
@@ -59,7 +71,7 @@ var execCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		vaultProvider, err := vault.NewVaultProvider(*cfg)
+		vaultProvider, err := agent.OpenVaultProvider(*cfg)
 		if err != nil {
 			fmt.Println("Error initializing vault provider.")
 			os.Exit(1)
@@ -69,10 +81,29 @@ var execCmd = &cobra.Command{
 		cmdExec := exec.Command(cmdToRun, cmdArgs...)
 		cmdExec.Env = append(os.Environ(), "SECTOOL_ENV=1")
 		envMap, kv, err := ParseEnvFile("sectool.env", vaultProvider, km)
+		keys := ReferencedKeys(envMap)
+		if err != nil {
+			// The keys asked for are recorded even if they couldn't be read
+			keys = EnvFileKeys("sectool.env")
+		}
+		err = audit.Record(cfg, "exec", keys, err)
 		if err != nil {
 			fmt.Printf("Error parsing env file: %v\n", err)
 			os.Exit(1)
 		}
+
+		expired, err := ExpiredSecrets(envMap, vaultProvider, time.Now())
+		if err != nil {
+			fmt.Printf("Error checking secret expiry: %v\n", err)
+			os.Exit(1)
+		}
+		if len(expired) > 0 {
+			if strict {
+				fmt.Printf("Refusing to run, expired secrets: %s\n", strings.Join(expired, ", "))
+				os.Exit(1)
+			}
+			fmt.Printf("Warning: expired secrets: %s\n", strings.Join(expired, ", "))
+		}
 		if no_output {
 			fmt.Println("Command started.")
 		} else {
@@ -96,7 +127,8 @@ var execCmd = &cobra.Command{
 			}()
 		}
 
-		// Append the environment variables from the env file
+		// Append the environment variables from the env file, the environment
+		// is made of strings, these copies of the secrets can't be wiped
 		envVars, err := ComposeEnv(envMap, kv)
 		if err != nil {
 			fmt.Printf("Error composing environment variables: %v\n", err)
@@ -135,8 +167,20 @@ func HideSensitiveInfo(input string, kv *sectoolCrypto.SecureKVStore) string {
 	return result
 }
 
-// Define a regular expression pattern to match environment variables
-const pattern = `\s*\$([a-zA-Z_][a-zA-Z0-9_]*)`
+// Define a regular expression pattern to match environment variables, keys
+// inside a namespace are referenced with braces, e.g. ${payments/prod/DB_PASSWORD}
+const pattern = `\s*\$(?:\{([a-zA-Z0-9_./\-]+)\}|([a-zA-Z_][a-zA-Z0-9_]*))`
+
+// namespaceVariable sets the namespace $KEY references are resolved in
+const namespaceVariable = "SECTOOL_NAMESPACE"
+
+// secretKey returns the vault key referenced by a match of pattern
+func secretKey(match []string) string {
+	if match[1] != "" {
+		return match[1]
+	}
+	return match[2]
+}
 
 // override the default behavior of the flag package to allow for arguments after the first argument
 func ProcessArgs(args []string) (string, []string) {
@@ -163,9 +207,20 @@ func ProcessArgs(args []string) (string, []string) {
 						os.Exit(1)
 					}
 				}
+				if arg == "--profile" || arg == "-p" {
+					i++
+					if i == len(args) {
+						fmt.Println("Missing profile name.")
+						os.Exit(1)
+					}
+					config.Profile = args[i]
+				}
 				if arg == "--no-output" || arg == "-n" {
 					no_output = true
 				}
+				if arg == "--strict" {
+					strict = true
+				}
 			} else {
 				// The first non-option argument is encountered
 				foundFirstArg = true
@@ -183,11 +238,74 @@ func ProcessArgs(args []string) (string, []string) {
 }
 
 func ParseEnvFile(envFile string, v vault.VaultProvider, km *sectoolCrypto.KeyManager) (map[string]string, *sectoolCrypto.SecureKVStore, error) {
+	env, err := ReadEnvFile(envFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The namespace is not part of the environment
+	namespace := env[namespaceVariable]
+	delete(env, namespaceVariable)
+
+	// Extract the sensitive strings from the environment variables
+	usedKeys := []string{}
+	kv := sectoolCrypto.NewSecureKVStore(km)
+
+	regex := regexp.MustCompile(pattern)
+	for _, value := range env {
+
+		matches := regex.FindAllStringSubmatch(value, -1)
+		if len(matches) == 0 {
+			err := kv.Put("SECTOOL_SENSITIVE_VALUE_"+value, value)
+			if err != nil {
+				fmt.Printf("Error putting value in vault: %v\n", err)
+				return nil, nil, err
+			}
+			continue
+		}
+
+		// Iterate over the matches
+		for _, match := range matches {
+			keyName := secretKey(match)
+			usedKeys = append(usedKeys, keyName)
+			if namespace != "" && match[2] != "" {
+				usedKeys = append(usedKeys, vault.JoinKey(namespace, keyName))
+			}
+		}
+	}
+
+	err = v.VaultGetMultipleValues(usedKeys, kv)
+	if err != nil {
+		fmt.Printf("Error getting multiple values from vault: %v\n", err)
+		return nil, nil, err
+	}
+
+	// Point $KEY references to the namespace if the key exists in it,
+	// otherwise they resolve to the key outside of any namespace
+	if namespace != "" {
+		for envName, value := range env {
+			env[envName] = regex.ReplaceAllStringFunc(value, func(m string) string {
+				match := regex.FindStringSubmatch(m)
+				key := vault.JoinKey(namespace, match[2])
+				if match[2] == "" || !kv.Has(key) {
+					return m
+				}
+				return m[:strings.Index(m, "$")] + "${" + key + "}"
+			})
+		}
+	}
+
+	// Return the environment map
+	return env, kv, nil
+}
+
+// ReadEnvFile returns the variables of the env file, those before the first
+// invalid line if it fails to parse.
+func ReadEnvFile(envFile string) (map[string]string, error) {
 	// Read the contents of the file
 	contents, err := os.ReadFile(envFile)
 	if err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
-		return nil, nil, err
+		return nil, err
 	}
 
 	// Split the contents of the file into lines
@@ -208,7 +326,7 @@ func ParseEnvFile(envFile string, v vault.VaultProvider, km *sectoolCrypto.KeyMa
 		// Split the line into key and value
 		parts := strings.SplitN(line, "=", 2)
 		if len(parts) != 2 {
-			return nil, nil, fmt.Errorf("invalid line %d: %s", lineNr, line)
+			return env, fmt.Errorf("invalid line %d: %s", lineNr, line)
 		}
 
 		// Extract the environment variable name
@@ -218,38 +336,82 @@ func ParseEnvFile(envFile string, v vault.VaultProvider, km *sectoolCrypto.KeyMa
 		env[envName] = envValue
 	}
 
-	// Extract the sensitive strings from the environment variables
-	usedKeys := []string{}
-	kv := sectoolCrypto.NewSecureKVStore(km)
+	return env, nil
+}
+
+// EnvFileKeys returns the sorted vault keys referenced by the env file
+// without reading the vault, $KEY references count for the key in the
+// namespace as well. Lines after an invalid one are ignored.
+func EnvFileKeys(envFile string) []string {
+	env, _ := ReadEnvFile(envFile)
+	namespace := env[namespaceVariable]
+	delete(env, namespaceVariable)
+
+	keys := ReferencedKeys(env)
+	if namespace == "" {
+		return keys
+	}
 
 	regex := regexp.MustCompile(pattern)
 	for _, value := range env {
-
-		matches := regex.FindAllStringSubmatch(value, -1)
-		if len(matches) == 0 {
-			err := kv.Put("SECTOOL_SENSITIVE_VALUE_"+value, value)
-			if err != nil {
-				fmt.Printf("Error putting value in vault: %v\n", err)
-				return nil, nil, err
+		for _, match := range regex.FindAllStringSubmatch(value, -1) {
+			if match[2] != "" {
+				keys = append(keys, vault.JoinKey(namespace, match[2]))
 			}
-			continue
 		}
+	}
+	slices.Sort(keys)
+	return slices.Compact(keys)
+}
 
-		// Iterate over the matches
-		for _, match := range matches {
-			keyName := match[1]
-			usedKeys = append(usedKeys, keyName)
+// ReferencedKeys returns the sorted vault keys referenced by the environment.
+func ReferencedKeys(e map[string]string) []string {
+	seen := map[string]bool{}
+	keys := []string{}
+	regex := regexp.MustCompile(pattern)
+	for _, value := range e {
+		for _, match := range regex.FindAllStringSubmatch(value, -1) {
+			key := secretKey(match)
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
 		}
 	}
 
-	err = v.VaultGetMultipleValues(usedKeys, kv)
+	sort.Strings(keys)
+	return keys
+}
+
+// ExpiredSecrets returns the vault keys referenced by the environment that
+// have expired, providers without metadata support have no expired keys.
+func ExpiredSecrets(e map[string]string, v vault.VaultProvider, now time.Time) ([]string, error) {
+	metadataProvider, ok := v.(vault.MetadataProvider)
+	if !ok {
+		return nil, nil
+	}
+
+	metadata, err := metadataProvider.VaultListMetadata()
 	if err != nil {
-		fmt.Printf("Error getting multiple values from vault: %v\n", err)
-		return nil, nil, err
+		return nil, err
 	}
 
-	// Return the environment map
-	return env, kv, nil
+	expired := []string{}
+	regex := regexp.MustCompile(pattern)
+	for _, value := range e {
+		for _, match := range regex.FindAllStringSubmatch(value, -1) {
+			key := secretKey(match)
+			meta, ok := metadata[key]
+			if !ok || !meta.Expired(now) {
+				continue
+			}
+			delete(metadata, key)
+			expired = append(expired, key)
+		}
+	}
+
+	sort.Strings(expired)
+	return expired, nil
 }
 
 // ComposeEnv reads the environment variables from the file and replaces the keys with the values from the vault
@@ -262,18 +424,17 @@ func ComposeEnv(e map[string]string, kv *sectoolCrypto.SecureKVStore) ([]string,
 	regex := regexp.MustCompile(pattern)
 	for key, value := range e {
 
-		composedValue := value
-		matches := regex.FindAllStringSubmatch(value, -1)
-		if len(matches) > 0 {
-			// Iterate over the matches
-			for _, match := range matches {
-				secretKey := match[1]
-				secretValue, err := kv.Get(secretKey)
-				if err != nil {
-					return nil, fmt.Errorf("error getting value from vault: %v", err)
-				}
-				composedValue = strings.Replace(composedValue, "$"+secretKey, secretValue, -1)
+		var err error
+		composedValue := regex.ReplaceAllStringFunc(value, func(m string) string {
+			secretValue, getErr := kv.Get(secretKey(regex.FindStringSubmatch(m)))
+			if getErr != nil {
+				err = getErr
+				return m
 			}
+			return m[:strings.Index(m, "$")] + secretValue
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error getting value from vault: %v", err)
 		}
 
 		env = append(env, fmt.Sprintf("%s=%s", key, composedValue))
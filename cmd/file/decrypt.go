@@ -0,0 +1,80 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package file
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/spf13/cobra"
+)
+
+// decryptCmd represents the file decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt <path>",
+	Short: "Decrypt a file or a directory.",
+	Long: `Decrypt a file produced by "sectool file encrypt" to the path without the .enc
+extension or the path given by --output. Encrypted directories are restored
+as a whole, the output directory must not exist.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool file decrypt <path> [-o <output>]")
+			os.Exit(1)
+		}
+
+		source := args[0]
+		target := output
+		if target == "" {
+			var found bool
+			target, found = strings.CutSuffix(source, ".enc")
+			if !found {
+				fmt.Println("Error: the input has no .enc extension, use --output.")
+				os.Exit(1)
+			}
+		}
+
+		if err := checkOutput(target); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		key, err := fileKey(false)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		if err := crypto.DecryptPath(source, target, key); err != nil {
+			fmt.Printf("Error decrypting '%s': %v\n", source, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Decrypted '%s' to '%s'\n", source, target)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	fileCmd.AddCommand(decryptCmd)
+}
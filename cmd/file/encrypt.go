@@ -0,0 +1,74 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package file
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/spf13/cobra"
+)
+
+// encryptCmd represents the file encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt <path>",
+	Short: "Encrypt a file or a directory.",
+	Long: `Encrypt a file, or a directory packed as a tar archive, to <path>.enc or the
+path given by --output. The file modes are restored on decrypt.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool file encrypt <path> [-o <output>]")
+			os.Exit(1)
+		}
+
+		source := filepath.Clean(args[0])
+		target := output
+		if target == "" {
+			target = source + ".enc"
+		}
+
+		if err := checkOutput(target); err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		key, err := fileKey(true)
+		if err != nil {
+			fmt.Println("Error:", err)
+			os.Exit(1)
+		}
+
+		if err := crypto.EncryptPath(source, target, key); err != nil {
+			fmt.Printf("Error encrypting '%s': %v\n", source, err)
+			os.Exit(1)
+		}
+
+		fmt.Printf("Encrypted '%s' to '%s'\n", source, target)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	fileCmd.AddCommand(encryptCmd)
+}
@@ -0,0 +1,136 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// fileCmd represents the file command
+var fileCmd = &cobra.Command{
+	Use:   "file",
+	Short: "Encrypt and decrypt files and directories",
+	Long: `Encrypt and decrypt files and directories with the key of the active vault
+profile, or with a passphrase prompted with --ask, read from an environment
+variable with --passphrase-env or from a file descriptor with --passphrase-fd.
+The passphrase is never taken on the command line, where ps and the shell
+history would expose it.`,
+}
+
+var passphraseEnv string
+var passphraseFd int
+var askPassphrase bool
+var output string
+var force bool
+
+// fileKey returns the passphrase to use, the vault key by default.
+func fileKey(confirm bool) ([]byte, error) {
+	if askPassphrase {
+		fmt.Print("Enter passphrase: ")
+		key, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return nil, err
+		}
+		if confirm {
+			fmt.Print("Repeat passphrase: ")
+			keyRepeat, err := term.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Println()
+			if err != nil {
+				return nil, err
+			}
+			if string(key) != string(keyRepeat) {
+				return nil, errors.New("passphrases do not match")
+			}
+		}
+		if len(key) == 0 {
+			return nil, errors.New("passphrase is empty")
+		}
+		return key, nil
+	}
+
+	if passphraseEnv != "" {
+		key := os.Getenv(passphraseEnv)
+		if key == "" {
+			return nil, fmt.Errorf("%s is not set", passphraseEnv)
+		}
+		return []byte(key), nil
+	}
+
+	if passphraseFd >= 0 {
+		return readPassphrase(passphraseFd)
+	}
+
+	key, err := vault.VaultKey(cmd.ConfigFile)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(key), nil
+}
+
+// readPassphrase reads the first line of a file descriptor, e.g. 3 with
+// 3< passphrase.txt or 3<<< "$PASSPHRASE".
+func readPassphrase(fd int) ([]byte, error) {
+	f := os.NewFile(uintptr(fd), "passphrase")
+	if f == nil {
+		return nil, fmt.Errorf("invalid file descriptor %d", fd)
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil && err != io.EOF {
+		return nil, fmt.Errorf("failed to read the passphrase: %w", err)
+	}
+	key := bytes.TrimRight(line, "\r\n")
+	if len(key) == 0 {
+		return nil, errors.New("passphrase is empty")
+	}
+	return key, nil
+}
+
+// checkOutput fails if the output exists, unless --force is given.
+func checkOutput(path string) error {
+	if _, err := os.Stat(path); err == nil && !force {
+		return fmt.Errorf("'%s' already exists, use --force to replace it", path)
+	}
+	return nil
+}
+
+func init() {
+	cmd.RootCmd.AddCommand(fileCmd)
+	fileCmd.PersistentFlags().StringVar(&passphraseEnv, "passphrase-env", "", "Environment variable holding the passphrase, default: the vault key")
+	fileCmd.PersistentFlags().IntVar(&passphraseFd, "passphrase-fd", -1, "File descriptor to read the passphrase from, default: the vault key")
+	fileCmd.PersistentFlags().BoolVar(&askPassphrase, "ask", false, "Prompt for the passphrase")
+	fileCmd.MarkFlagsMutuallyExclusive("passphrase-env", "passphrase-fd", "ask")
+	fileCmd.PersistentFlags().StringVarP(&output, "output", "o", "", "Output path")
+	fileCmd.PersistentFlags().BoolVar(&force, "force", false, "Replace an existing output file")
+}
@@ -0,0 +1,56 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package git
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// diffDriverCmd represents the git diff-driver command
+var diffDriverCmd = &cobra.Command{
+	Use:   "diff-driver <file>",
+	Short: "Print a vault file as text for git diff.",
+	Long:  `Print the keys of a vault file, one per line, with a fingerprint of their value. Values are never printed, a changed fingerprint shows the value changed. Used by git as the textconv of vault files.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: sectool git diff-driver <file>")
+			os.Exit(1)
+		}
+
+		text, err := vault.TextConvVaultFile(cmd.ConfigFile, args[0])
+		if err != nil {
+			os.Exit(1)
+		}
+
+		fmt.Print(text)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	gitCmd.AddCommand(diffDriverCmd)
+}
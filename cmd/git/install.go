@@ -0,0 +1,161 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package git
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/fsutil"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// installCmd represents the git install command
+var installCmd = &cobra.Command{
+	Use:   "install",
+	Short: "Enable the git drivers for the vault file.",
+	Long:  `Enable the diff and merge drivers for the vault file of the active profile. The vault file is added to .gitattributes at the root of the repository, its unlocked working copy to .gitignore, and the drivers are configured in the repository git config.`,
+	Run: func(c *cobra.Command, args []string) {
+
+		vaultPath, err := vault.VaultPath(cmd.ConfigFile)
+		if err != nil {
+			fmt.Println("Error reading the vault path.")
+			os.Exit(1)
+		}
+
+		if err := install(vaultPath); err != nil {
+			fmt.Printf("Error installing git drivers: %v\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Println("Git drivers installed")
+		os.Exit(0)
+	},
+}
+
+// git runs a git command returning its trimmed output.
+func git(args ...string) (string, error) {
+	var stderr bytes.Buffer
+	command := exec.Command("git", args...)
+	command.Stderr = &stderr
+	out, err := command.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %s", args[0], strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// driverCommand returns the sectool command line run by git, the drivers run
+// from the root of the repository so the configuration file is made absolute.
+func driverCommand(subcommand string) (string, error) {
+	command := "sectool"
+	if cmd.ConfigFile != "" {
+		configFile, err := filepath.Abs(cmd.ConfigFile)
+		if err != nil {
+			return "", err
+		}
+		command += fmt.Sprintf(" -f '%s'", configFile)
+	}
+	if config.Profile != "" {
+		command += fmt.Sprintf(" -p '%s'", config.Profile)
+	}
+	return command + " git " + subcommand, nil
+}
+
+// appendLine adds a line to a file unless it is already there.
+func appendLine(path, line string) error {
+	contents, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	if strings.Contains("\n"+string(contents)+"\n", "\n"+line+"\n") {
+		return nil
+	}
+	if len(contents) > 0 && !bytes.HasSuffix(contents, []byte("\n")) {
+		contents = append(contents, '\n')
+	}
+	contents = append(contents, line+"\n"...)
+	return fsutil.WriteFileAtomic(path, contents, 0644)
+}
+
+// install adds the vault file to .gitattributes, its working copy to
+// .gitignore and configures the drivers.
+func install(vaultPath string) error {
+	root, err := git("rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	absPath, err := filepath.Abs(vaultPath)
+	if err != nil {
+		return err
+	}
+	relPath, err := filepath.Rel(root, absPath)
+	if err != nil || !filepath.IsLocal(relPath) {
+		return fmt.Errorf("'%s' is outside of the repository", vaultPath)
+	}
+
+	line := "/" + filepath.ToSlash(relPath) + " diff=sectool merge=sectool"
+	if err := appendLine(filepath.Join(root, ".gitattributes"), line); err != nil {
+		return err
+	}
+
+	// The working copy of an unlocked vault is plain text
+	line = "/" + filepath.ToSlash(relPath) + ".unlocked"
+	if err := appendLine(filepath.Join(root, ".gitignore"), line); err != nil {
+		return err
+	}
+
+	diffDriver, err := driverCommand("diff-driver")
+	if err != nil {
+		return err
+	}
+	mergeDriver, err := driverCommand("merge-driver %O %A %B")
+	if err != nil {
+		return err
+	}
+
+	settings := [][2]string{
+		{"diff.sectool.textconv", diffDriver},
+		{"merge.sectool.name", "sectool vault merge driver"},
+		{"merge.sectool.driver", mergeDriver},
+	}
+	for _, setting := range settings {
+		if _, err := git("config", "--local", setting[0], setting[1]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	gitCmd.AddCommand(installCmd)
+}
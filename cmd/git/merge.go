@@ -0,0 +1,62 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package git
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// mergeDriverCmd represents the git merge-driver command
+var mergeDriverCmd = &cobra.Command{
+	Use:   "merge-driver <base> <ours> <theirs>",
+	Short: "Merge vault files for git.",
+	Long:  `Three-way merge of vault files by key, called by git with %O %A %B. Keys changed on one side are taken from it, keys changed differently on both sides are reported as conflicts and the merge fails leaving ours untouched. The result is encrypted again and written to ours.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: sectool git merge-driver <base> <ours> <theirs>")
+			os.Exit(1)
+		}
+
+		conflicts, err := vault.MergeVaultFiles(cmd.ConfigFile, args[0], args[1], args[2])
+		if err != nil {
+			os.Exit(1)
+		}
+
+		if len(conflicts) > 0 {
+			for _, key := range conflicts {
+				fmt.Fprintf(os.Stderr, "CONFLICT (vault): '%s' changed on both sides\n", key)
+			}
+			os.Exit(1)
+		}
+
+		os.Exit(0)
+	},
+}
+
+func init() {
+	gitCmd.AddCommand(mergeDriverCmd)
+}
@@ -24,6 +24,8 @@ package cmd
 import (
 	"os"
 
+	"github.com/a13labs/sectool/internal/audit"
+	"github.com/a13labs/sectool/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -33,6 +35,10 @@ var RootCmd = &cobra.Command{
 	Short: "A tool for hardened security",
 	Long: `sectool is a command-line tool that provides a secure and user-friendly 
 way to manage SSH key pairs and secrets stored in a local vault.`,
+	// The command is recorded with every access to the vault
+	PersistentPreRun: func(c *cobra.Command, args []string) {
+		audit.Command = c.CommandPath()
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -48,4 +54,5 @@ var ConfigFile string
 
 func init() {
 	RootCmd.PersistentFlags().StringVarP(&ConfigFile, "config", "f", "", "Configuration file")
+	RootCmd.PersistentFlags().StringVarP(&config.Profile, "profile", "p", "", "Configuration profile (default from SECTOOL_PROFILE or default_profile)")
 }
@@ -26,6 +26,7 @@ import (
 	"os"
 	"path/filepath"
 
+	"github.com/a13labs/sectool/internal/fsutil"
 	"github.com/a13labs/sectool/internal/ssh"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -105,8 +106,16 @@ var addCmd = &cobra.Command{
 			return
 		}
 
-		os.WriteFile(key_full_path_priv, []byte(priv), 0600)
-		os.WriteFile(key_full_path_pub, []byte(pub), 0644)
+		err = fsutil.WriteFileAtomic(key_full_path_priv, []byte(priv), fsutil.DefaultFileMode)
+		if err != nil {
+			fmt.Println("Error writing private key:", err)
+			os.Exit(1)
+		}
+		err = fsutil.WriteFileAtomic(key_full_path_pub, []byte(pub), 0644)
+		if err != nil {
+			fmt.Println("Error writing public key:", err)
+			os.Exit(1)
+		}
 
 		fmt.Println("Key pair successfully generated.")
 		os.Exit(0)
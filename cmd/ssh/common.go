@@ -5,6 +5,8 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/a13labs/sectool/internal/crypto"
 )
 
 func listKeys(parentPath string) ([]string, error) {
@@ -36,3 +38,10 @@ func listKeys(parentPath string) ([]string, error) {
 
 	return subfoldersWithIDFiles, nil
 }
+
+// keyContext returns the associated data an encrypted private key is bound
+// to, so a key file moved to another key directory or algorithm fails to
+// decrypt.
+func keyContext(key, prefix string) []byte {
+	return crypto.AssociatedData("sectool-ssh-key", key, "id_"+prefix)
+}
@@ -26,6 +26,7 @@ import (
 	"os"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/vault"
 	"github.com/spf13/cobra"
@@ -59,12 +60,22 @@ var initCmd = &cobra.Command{
 			key = defaultSSHPasswordKey
 		}
 
-		if vaultProvider.VaultHasKey(key) {
+		exists := vaultProvider.VaultHasKey(key)
+		if err := audit.Record(cfg, "has", []string{key}, nil); err != nil {
+			fmt.Printf("Error recording the operation: %v\n", err)
+			os.Exit(1)
+		}
+		if exists {
 			fmt.Printf("'%s' already defined, aborting.", key)
 			os.Exit(1)
 		}
 
-		vaultProvider.VaultSetValue("key", args[0])
+		err = vaultProvider.VaultSetValue(key, args[0])
+		err = audit.Record(cfg, "ssh-init", []string{key}, err)
+		if err != nil {
+			fmt.Printf("Error setting the SSH password: %v\n", err)
+			os.Exit(1)
+		}
 		fmt.Println("SSH key management successfully initialized.")
 	},
 }
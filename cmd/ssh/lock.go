@@ -27,6 +27,7 @@ import (
 	"path/filepath"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
 	"github.com/a13labs/sectool/internal/ssh"
@@ -71,6 +72,7 @@ var lockCmd = &cobra.Command{
 		}
 
 		ssh_master_password, err := vaultProvider.VaultGetValue(key)
+		err = audit.Record(cfg, "ssh-lock", []string{key}, err)
 		if err != nil {
 			fmt.Printf("Error reading '%s', aborting.", key)
 			os.Exit(1)
@@ -90,7 +92,7 @@ var lockCmd = &cobra.Command{
 					continue
 				}
 
-				err = crypto.EncryptFile(key_path, key_path+".key", []byte(ssh_master_password))
+				err = crypto.EncryptFileWithContext(key_path, key_path+".key", []byte(ssh_master_password), keyContext(key, prefix))
 				if err != nil {
 					fmt.Printf("Error encrypting private key (%s) in '%s', skipping.\n", prefix, key)
 					continue
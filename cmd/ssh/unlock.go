@@ -22,11 +22,13 @@ THE SOFTWARE.
 package ssh
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
 	"github.com/a13labs/sectool/internal/ssh"
@@ -34,11 +36,15 @@ import (
 	"github.com/spf13/cobra"
 )
 
+var migrateKeys bool
+
 // unlockCmd represents the list command
 var unlockCmd = &cobra.Command{
 	Use:   "unlock",
 	Short: "A Unlock SSH key pairs",
-	Long:  ``,
+	Long: `Decrypt the private keys of the key pairs. Private keys encrypted before they
+were bound to their key directory and algorithm are skipped, unless --migrate
+is given to decrypt them and encrypt them again bound to it.`,
 	Run: func(c *cobra.Command, args []string) {
 
 		_, err := os.Stat("ssh-keys")
@@ -71,6 +77,7 @@ var unlockCmd = &cobra.Command{
 		}
 
 		ssh_master_password, err := vaultProvider.VaultGetValue(key)
+		err = audit.Record(cfg, "ssh-unlock", []string{key}, err)
 		if err != nil {
 			fmt.Printf("Error reading '%s', aborting.", key)
 			os.Exit(1)
@@ -89,7 +96,14 @@ var unlockCmd = &cobra.Command{
 					continue
 				}
 
-				err = crypto.DecryptFile(key_path+".key", key_path, []byte(ssh_master_password))
+				err = crypto.DecryptFileWithContext(key_path+".key", key_path, []byte(ssh_master_password), keyContext(key, prefix))
+				if errors.Is(err, crypto.ErrNoContext) && !migrateKeys {
+					fmt.Printf("Private key (%s) in '%s' is not bound to its location, run with --migrate to bind it, skipping.\n", prefix, key)
+					continue
+				}
+				if errors.Is(err, crypto.ErrNoContext) {
+					err = migrateKey(key_path, []byte(ssh_master_password), keyContext(key, prefix))
+				}
 				if err != nil {
 					fmt.Printf("Error decrypting private (%s) data in '%s', skipping.\n", prefix, key)
 					continue
@@ -101,6 +115,16 @@ var unlockCmd = &cobra.Command{
 	},
 }
 
+// migrateKey decrypts a private key encrypted before it was bound to its
+// location, and encrypts it again bound to it.
+func migrateKey(keyPath string, password []byte, ad []byte) error {
+	if err := crypto.DecryptFile(keyPath+".key", keyPath, password); err != nil {
+		return err
+	}
+	return crypto.EncryptFileWithContext(keyPath, keyPath+".key", password, ad)
+}
+
 func init() {
 	sshCmd.AddCommand(unlockCmd)
+	unlockCmd.Flags().BoolVar(&migrateKeys, "migrate", false, "Bind private keys encrypted by an older version to their location")
 }
@@ -0,0 +1,61 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package values
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/values"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// decryptValuesCmd represents the decrypt-values command
+var decryptValuesCmd = &cobra.Command{
+	Use:   "decrypt-values <file>",
+	Short: "Decrypt the values of a file encrypted with encrypt-values.",
+	Long:  `Decrypt the values of a YAML, JSON or dotenv file encrypted with encrypt-values and remove the encryption metadata. The decryption fails if any value was changed, added or removed since it was encrypted. The file is printed to stdout unless --in-place or --output is given.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: sectool decrypt-values <file> [-i | -o <output>]")
+			os.Exit(1)
+		}
+
+		data, fileFormat, err := readInput(args[0])
+		exitOnError("Error reading file", err)
+
+		key, err := vault.VaultKey(cmd.ConfigFile)
+		exitOnError("Error reading the vault key", err)
+
+		decrypted, err := values.Decrypt(data, fileFormat, []byte(key))
+		exitOnError("Error decrypting values", err)
+
+		exitOnError("Error writing file", writeOutput(args[0], decrypted))
+		os.Exit(0)
+	},
+}
+
+func init() {
+	addFlags(decryptValuesCmd)
+}
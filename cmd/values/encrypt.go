@@ -0,0 +1,65 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package values
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/values"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+var encryptedRegex string
+
+// encryptValuesCmd represents the encrypt-values command
+var encryptValuesCmd = &cobra.Command{
+	Use:   "encrypt-values <file>",
+	Short: "Encrypt the values of a YAML, JSON or dotenv file.",
+	Long:  `Encrypt the values of a YAML, JSON or dotenv file with the key of the active vault profile, keys and comments are left readable. With --regex only the values whose key, or the key of a parent, matches are encrypted. A MAC over all values is stored with the encryption metadata under the "sectool" key. Encrypting an encrypted file encrypts the values added since, the values already there must match the MAC.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) != 1 {
+			fmt.Fprintln(os.Stderr, "Usage: sectool encrypt-values <file> [--regex <regex>] [-i | -o <output>]")
+			os.Exit(1)
+		}
+
+		data, fileFormat, err := readInput(args[0])
+		exitOnError("Error reading file", err)
+
+		key, err := vault.VaultKey(cmd.ConfigFile)
+		exitOnError("Error reading the vault key", err)
+
+		encrypted, err := values.Encrypt(data, fileFormat, []byte(key), encryptedRegex, crypto.DefaultKDFParams)
+		exitOnError("Error encrypting values", err)
+
+		exitOnError("Error writing file", writeOutput(args[0], encrypted))
+		os.Exit(0)
+	},
+}
+
+func init() {
+	encryptValuesCmd.Flags().StringVar(&encryptedRegex, "regex", "", "Encrypt only the values of matching keys, default: all values")
+	addFlags(encryptValuesCmd)
+}
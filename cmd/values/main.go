@@ -0,0 +1,90 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package values
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/fsutil"
+	"github.com/a13labs/sectool/internal/values"
+	"github.com/spf13/cobra"
+)
+
+var format string
+var inPlace bool
+var output string
+
+// readInput reads the file and its format, from --format or its name.
+func readInput(path string) ([]byte, values.Format, error) {
+	var fileFormat values.Format
+	var err error
+	if format != "" {
+		fileFormat, err = values.ParseFormat(format)
+	} else {
+		fileFormat, err = values.FormatFromPath(path)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, fileFormat, nil
+}
+
+// writeOutput writes the result to the file with --in-place, to --output or
+// to stdout.
+func writeOutput(path string, data []byte) error {
+	switch {
+	case inPlace:
+		info, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		return fsutil.WriteFileAtomic(path, data, info.Mode().Perm())
+	case output != "":
+		return fsutil.WriteFileAtomic(output, data, fsutil.DefaultFileMode)
+	}
+	_, err := os.Stdout.Write(data)
+	return err
+}
+
+// addFlags adds the flags shared by the values commands.
+func addFlags(c *cobra.Command) {
+	c.Flags().StringVar(&format, "format", "", "File format: yaml, json or dotenv, default: from the file name")
+	c.Flags().BoolVarP(&inPlace, "in-place", "i", false, "Replace the file")
+	c.Flags().StringVarP(&output, "output", "o", "", "Output path, default: stdout")
+	c.MarkFlagsMutuallyExclusive("in-place", "output")
+	cmd.RootCmd.AddCommand(c)
+}
+
+// exitOnError prints the error and exits.
+func exitOnError(message string, err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", message, err)
+		os.Exit(1)
+	}
+}
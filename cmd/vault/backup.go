@@ -0,0 +1,119 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/a13labs/sectool/cmd"
+	internalVault "github.com/a13labs/sectool/internal/vault"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// backupCmd represents the backup command
+var backupCmd = &cobra.Command{
+	Use:   "backup",
+	Short: "Manage vault backups.",
+	Long:  ``,
+}
+
+// backupListCmd represents the backup list command
+var backupListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List vault backups, newest first.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+
+		backups, err := vault.ListBackups(cmd.ConfigFile)
+		if err != nil {
+			fmt.Println("Error listing backups.")
+			os.Exit(1)
+		}
+
+		for _, backup := range backups {
+			fmt.Printf("%s\t%s\n", backup.Name, backup.Created.Format(time.RFC3339))
+		}
+		os.Exit(0)
+	},
+}
+
+// backupRestoreCmd represents the backup restore command
+var backupRestoreCmd = &cobra.Command{
+	Use:   "restore",
+	Short: "Replace the vault with a backup.",
+	Long:  `Replace the vault with a backup, identified by its name or timestamp. The backup must decrypt with the current vault key.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool vault backup restore <backup>")
+			os.Exit(1)
+		}
+
+		err := vault.RestoreBackup(cmd.ConfigFile, args[0])
+		if err != nil {
+			fmt.Println("Error restoring backup.")
+			os.Exit(1)
+		}
+
+		fmt.Println("Backup restored")
+		os.Exit(0)
+	},
+}
+
+// backupPruneCmd represents the backup prune command
+var backupPruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove backups according to the retention policy.",
+	Long:  `Remove backups according to the configured retention policy, or the one given by --keep-last and --keep-days.`,
+	Run: func(c *cobra.Command, args []string) {
+
+		var policy *internalVault.RetentionPolicy
+		if c.Flags().Changed("keep-last") || c.Flags().Changed("keep-days") {
+			keepLast, _ := c.Flags().GetInt("keep-last")
+			keepDays, _ := c.Flags().GetInt("keep-days")
+			policy = &internalVault.RetentionPolicy{KeepLast: keepLast, KeepDays: keepDays}
+		}
+
+		removed, err := vault.PruneBackups(cmd.ConfigFile, policy)
+		for _, name := range removed {
+			fmt.Printf("Removed: %s\n", name)
+		}
+		if err != nil {
+			fmt.Println("Error pruning backups.")
+			os.Exit(1)
+		}
+
+		fmt.Printf("%d backup(s) removed\n", len(removed))
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(backupCmd)
+	backupCmd.AddCommand(backupListCmd)
+	backupCmd.AddCommand(backupRestoreCmd)
+	backupCmd.AddCommand(backupPruneCmd)
+	backupPruneCmd.Flags().Int("keep-last", 0, "Keep the last N backups")
+	backupPruneCmd.Flags().Int("keep-days", 0, "Keep backups younger than N days")
+}
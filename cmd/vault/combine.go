@@ -0,0 +1,82 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// combineCmd represents the combine command
+var combineCmd = &cobra.Command{
+	Use:   "combine",
+	Short: "Rebuild the vault key from Shamir shares.",
+	Long: `Rebuild the vault key from the shares given as arguments, or read from the
+standard input one per line, and print it. For example:
+
+  FILE_VAULT_KEY=$(sectool vault combine < shares.txt) sectool vault rekey`,
+	Run: func(c *cobra.Command, args []string) {
+
+		shares := args
+		if len(shares) == 0 {
+			scanner := bufio.NewScanner(os.Stdin)
+			for scanner.Scan() {
+				line := strings.TrimSpace(scanner.Text())
+				// Accept the lines printed by split as they are
+				if i := strings.LastIndex(line, ": "); i >= 0 {
+					line = line[i+2:]
+				}
+				if line != "" {
+					shares = append(shares, line)
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				fmt.Fprintln(os.Stderr, "Error reading shares:", err)
+				os.Exit(1)
+			}
+		}
+
+		if len(shares) == 0 {
+			fmt.Fprintln(os.Stderr, "Usage: sectool vault combine [share...]")
+			os.Exit(1)
+		}
+
+		// Errors go to stderr so they are never captured as the key
+		key, err := vault.CombineKey(cmd.ConfigFile, shares)
+		if err != nil {
+			os.Exit(1)
+		}
+
+		fmt.Println(key)
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(combineCmd)
+}
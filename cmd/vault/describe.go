@@ -0,0 +1,85 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/a13labs/sectool/cmd"
+	internalVault "github.com/a13labs/sectool/internal/vault"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// describeCmd represents the describe command
+var describeCmd = &cobra.Command{
+	Use:   "describe",
+	Short: "Show the metadata of a key in the vault.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool vault describe <key>")
+			os.Exit(1)
+		}
+
+		meta, err := vault.DescribeSecret(cmd.ConfigFile, args[0])
+		if err != nil {
+			fmt.Println("Error describing key.")
+			os.Exit(1)
+		}
+
+		fmt.Printf("Key:         %s\n", args[0])
+		fmt.Printf("Description: %s\n", meta.Description)
+		fmt.Printf("Tags:        %s\n", strings.Join(meta.Tags, ", "))
+		fmt.Printf("Owner:       %s\n", meta.Owner)
+		fmt.Printf("Expires:     %s\n", formatExpiry(meta))
+		fmt.Printf("Created:     %s\n", formatTime(meta.Created))
+		fmt.Printf("Updated:     %s\n", formatTime(meta.Updated))
+		os.Exit(0)
+	},
+}
+
+// formatTime formats a timestamp in local time, or "unknown" if not set.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "unknown"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatExpiry formats the expiry of a secret, flagging expired secrets.
+func formatExpiry(meta internalVault.SecretMetadata) string {
+	if meta.Expires.IsZero() {
+		return "never"
+	}
+	if meta.Expired(time.Now()) {
+		return formatTime(meta.Expires) + " (expired)"
+	}
+	return formatTime(meta.Expires)
+}
+
+func init() {
+	vaultCmd.AddCommand(describeCmd)
+}
@@ -24,30 +24,115 @@ package vault
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/a13labs/sectool/cmd"
+	internalVault "github.com/a13labs/sectool/internal/vault"
 	"github.com/a13labs/sectool/vault"
 	"github.com/spf13/cobra"
 )
 
 // listCmd represents the list command
 var listCmd = &cobra.Command{
-	Use:   "list",
+	Use:   "list [namespace]",
 	Short: "List keys in the vault.",
 	Long:  ``,
 	Run: func(c *cobra.Command, args []string) {
 
+		namespace := ""
+		if len(args) > 0 {
+			namespace = args[0]
+		}
+
+		expired, _ := c.Flags().GetBool("expired")
+		expiring, _ := c.Flags().GetString("expiring")
+		if expired || expiring != "" {
+			listExpiring(namespace, expiring)
+			return
+		}
+
 		keys, err := vault.ListSecrets(cmd.ConfigFile)
 		if err != nil {
 			fmt.Println("Error listing keys.")
 			os.Exit(1)
 		}
+
+		keys = internalVault.FilterKeys(keys, namespace)
+		if tree, _ := c.Flags().GetBool("tree"); tree {
+			printTree(keys)
+			return
+		}
+
 		for _, key := range keys {
 			fmt.Println(key)
 		}
 	},
 }
 
+// listExpiring lists the keys that have expired or, if within is set, that
+// expire within that duration.
+func listExpiring(namespace, within string) {
+	var d time.Duration
+	if within != "" {
+		var err error
+		d, err = internalVault.ParseDuration(within)
+		if err != nil {
+			fmt.Printf("Error parsing duration: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	metadata, err := vault.ListSecretsMetadata(cmd.ConfigFile)
+	if err != nil {
+		fmt.Println("Error listing keys.")
+		os.Exit(1)
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		keys = append(keys, key)
+	}
+	keys = internalVault.FilterKeys(keys, namespace)
+
+	now := time.Now()
+	for _, key := range keys {
+		meta := metadata[key]
+		if !meta.ExpiresWithin(now, d) {
+			continue
+		}
+
+		line := fmt.Sprintf("%s\t%s", key, formatTime(meta.Expires))
+		if meta.Expired(now) {
+			line += "\t(expired)"
+		}
+		fmt.Println(line)
+	}
+}
+
+// printTree prints sorted keys as a tree of namespaces.
+func printTree(keys []string) {
+	var previous []string
+	for _, key := range keys {
+		parts := strings.Split(key, internalVault.NamespaceSeparator)
+
+		// Skip the namespaces shared with the previous key
+		common := 0
+		for common < len(parts)-1 && common < len(previous)-1 && parts[common] == previous[common] {
+			common++
+		}
+
+		for i := common; i < len(parts)-1; i++ {
+			fmt.Printf("%s%s%s\n", strings.Repeat("  ", i), parts[i], internalVault.NamespaceSeparator)
+		}
+		fmt.Printf("%s%s\n", strings.Repeat("  ", len(parts)-1), parts[len(parts)-1])
+		previous = parts
+	}
+}
+
 func init() {
 	vaultCmd.AddCommand(listCmd)
+	listCmd.Flags().Bool("tree", false, "Show the keys as a tree of namespaces")
+	listCmd.Flags().Bool("expired", false, "List only expired keys")
+	listCmd.Flags().String("expiring", "", "List only keys expiring within a duration (e.g. 14d)")
 }
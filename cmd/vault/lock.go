@@ -22,19 +22,27 @@ THE SOFTWARE.
 package vault
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/vault"
 	"github.com/spf13/cobra"
 )
 
+var lockForce bool
+var lockDiscard bool
+
 var lockCmd = &cobra.Command{
 	Use:   "lock",
 	Short: "If the provider supports it, lock the vault.",
-	Long:  ``,
+	Long: `Lock an unlocked vault, writing the changes of the working copy to the vault
+and removing it. If the vault changed since it was unlocked the working copy
+is stale and the lock fails, --force overwrites those changes with the
+working copy and --discard drops the working copy instead.`,
 	Run: func(c *cobra.Command, args []string) {
 
 		cfg, err := config.ReadConfig(cmd.ConfigFile)
@@ -49,9 +57,24 @@ var lockCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = vaultProvider.Lock()
+		workingCopyProvider, ok := vaultProvider.(vault.WorkingCopyProvider)
+		switch {
+		case !ok:
+			err = vaultProvider.Lock()
+		case lockDiscard:
+			err = workingCopyProvider.VaultDiscardWorkingCopy()
+		default:
+			err = workingCopyProvider.VaultLockWorkingCopy(lockForce)
+		}
+		err = audit.Record(cfg, "lock", nil, err)
+
+		if errors.Is(err, vault.ErrStaleWorkingCopy) {
+			fmt.Println("Warning: the vault changed since it was unlocked, the working copy is stale.")
+			fmt.Println("Run with --force to overwrite the vault with it, or --discard to drop it.")
+			os.Exit(1)
+		}
 		if err != nil {
-			fmt.Println("Error locking vault.")
+			fmt.Printf("Error locking vault: %v\n", err)
 			os.Exit(1)
 		}
 	},
@@ -59,4 +82,7 @@ var lockCmd = &cobra.Command{
 
 func init() {
 	vaultCmd.AddCommand(lockCmd)
+	lockCmd.Flags().BoolVar(&lockForce, "force", false, "Lock a stale working copy, overwriting the changes made to the vault since it was unlocked")
+	lockCmd.Flags().BoolVar(&lockDiscard, "discard", false, "Drop the working copy and its changes")
+	lockCmd.MarkFlagsMutuallyExclusive("force", "discard")
 }
@@ -0,0 +1,109 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// membersCmd represents the members command
+var membersCmd = &cobra.Command{
+	Use:   "members",
+	Short: "Manage the members of a team vault.",
+	Long:  `Manage the members of a team vault. A vault with members is encrypted to their age X25519 public keys, each member unlocks it with their own identity file, set with "identity" in the vault configuration or SECTOOL_IDENTITY.`,
+}
+
+// membersListCmd represents the members list command
+var membersListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the members of the vault.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+
+		members, err := vault.ListMembers(cmd.ConfigFile)
+		if err != nil {
+			fmt.Println("Error listing members.")
+			os.Exit(1)
+		}
+
+		for _, member := range members {
+			fmt.Println(member)
+		}
+		os.Exit(0)
+	},
+}
+
+// membersAddCmd represents the members add command
+var membersAddCmd = &cobra.Command{
+	Use:   "add",
+	Short: "Add members to the vault.",
+	Long:  `Add members, given by their age public keys, to the vault. When the first members are added the vault stops using the vault key and your own identity is added as a member.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool vault members add <recipient> [recipient...]")
+			os.Exit(1)
+		}
+
+		err := vault.AddMembers(cmd.ConfigFile, args)
+		if err != nil {
+			fmt.Println("Error adding members.")
+			os.Exit(1)
+		}
+
+		fmt.Println("Members added")
+		os.Exit(0)
+	},
+}
+
+// membersRemoveCmd represents the members remove command
+var membersRemoveCmd = &cobra.Command{
+	Use:   "remove",
+	Short: "Remove members from the vault.",
+	Long:  `Remove members from the vault. The key-encryption key is rotated, removed members can't read secrets written afterwards, use rekey to switch back to a vault key.`,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 1 {
+			fmt.Println("Usage: sectool vault members remove <recipient> [recipient...]")
+			os.Exit(1)
+		}
+
+		err := vault.RemoveMembers(cmd.ConfigFile, args)
+		if err != nil {
+			fmt.Println("Error removing members.")
+			os.Exit(1)
+		}
+
+		fmt.Println("Members removed")
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(membersCmd)
+	membersCmd.AddCommand(membersListCmd)
+	membersCmd.AddCommand(membersAddCmd)
+	membersCmd.AddCommand(membersRemoveCmd)
+}
@@ -0,0 +1,61 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// migrateCmd represents the migrate command
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Bind a vault written by an older version to its location.",
+	Long: `Re-encrypt the vault and its backups written before they were bound to the
+vault location, with the same key or members. Until then they fail to open, so
+a vault copied over another one with the same key can't be passed off as it.
+Only migrate a vault you know is the one configured.`,
+	Run: func(c *cobra.Command, args []string) {
+
+		report, err := vault.MigrateVault(cmd.ConfigFile)
+		for _, artifact := range report {
+			fmt.Printf("Rewritten: %s\n", artifact)
+		}
+		if err != nil {
+			fmt.Printf("Error migrating vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		if len(report) == 0 {
+			fmt.Println("Nothing to migrate.")
+		}
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(migrateCmd)
+}
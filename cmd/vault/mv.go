@@ -0,0 +1,67 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+// mvCmd represents the mv command
+var mvCmd = &cobra.Command{
+	Use:   "mv",
+	Short: "Rename a key or move a namespace in the vault.",
+	Long:  ``,
+	Run: func(c *cobra.Command, args []string) {
+		if len(args) < 2 {
+			fmt.Println("Usage: sectool vault mv <key or namespace> <destination>")
+			os.Exit(1)
+		}
+
+		backup, _ := c.Flags().GetBool("backup")
+		renames, err := vault.MoveSecrets(cmd.ConfigFile, args[0], args[1], backup)
+		if err != nil {
+			fmt.Println("Error moving keys.")
+			os.Exit(1)
+		}
+
+		keys := make([]string, 0, len(renames))
+		for key := range renames {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			fmt.Printf("%s -> %s\n", key, renames[key])
+		}
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(mvCmd)
+	mvCmd.Flags().BoolP("backup", "b", false, "Backup vault, even if disabled in the configuration.")
+}
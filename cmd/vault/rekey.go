@@ -0,0 +1,113 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var oldKey string
+var newKey string
+var purgeBackups bool
+var rekeySSHAgent bool
+var dropMembers bool
+
+// rekeyCmd represents the rekey command
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Rotate the key of the vault and its backups.",
+	Long: `Re-encrypt the vault and its backups with a new key. The current key is read
+from the configuration, unless --old-key is given. If --new-key is not given
+the new key is prompted. With --ssh-agent the new key is derived from the
+ssh-agent key configured for the vault, run it with --old-key when moving an
+existing vault to the ssh-agent. A vault with members is refused, members
+remove rotates its key, unless --drop-members switches it back to a key.`,
+	Run: func(c *cobra.Command, args []string) {
+
+		if rekeySSHAgent {
+			key, err := vault.SSHAgentKey(cmd.ConfigFile)
+			if err != nil {
+				os.Exit(1)
+			}
+			newKey = key
+		}
+
+		if newKey == "" {
+			fmt.Print("Enter new key: ")
+			key, err := term.ReadPassword(int(os.Stdin.Fd()))
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			fmt.Println()
+			fmt.Print("Repeat new key: ")
+			keyRepeat, err := term.ReadPassword(int(os.Stdin.Fd()))
+			if err != nil {
+				fmt.Println("Error:", err)
+				os.Exit(1)
+			}
+			fmt.Println()
+			if string(key) != string(keyRepeat) {
+				fmt.Println("Keys do not match.")
+				os.Exit(1)
+			}
+			newKey = string(key)
+		}
+
+		if newKey == "" {
+			fmt.Println("Missing new key.")
+			os.Exit(1)
+		}
+
+		report, err := vault.RekeyVault(cmd.ConfigFile, oldKey, newKey, purgeBackups, dropMembers)
+		for _, artifact := range report {
+			fmt.Printf("Rewritten: %s\n", artifact)
+		}
+		if err != nil {
+			fmt.Printf("Error rekeying vault: %v\n", err)
+			os.Exit(1)
+		}
+
+		if rekeySSHAgent {
+			fmt.Println("Vault rekeyed, it is now unlocked with the ssh-agent key.")
+			os.Exit(0)
+		}
+
+		fmt.Println("Vault rekeyed, update the configured key before the next use.")
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(rekeyCmd)
+	rekeyCmd.Flags().StringVar(&oldKey, "old-key", "", "Current vault key, default: configured key")
+	rekeyCmd.Flags().StringVar(&newKey, "new-key", "", "New vault key, prompted if not given")
+	rekeyCmd.Flags().BoolVar(&rekeySSHAgent, "ssh-agent", false, "Derive the new key from the configured ssh-agent key")
+	rekeyCmd.Flags().BoolVar(&purgeBackups, "purge-backups", false, "Remove backups instead of re-encrypting them")
+	rekeyCmd.Flags().BoolVar(&dropMembers, "drop-members", false, "Switch a vault with members back to a key, removing every member")
+}
@@ -24,8 +24,10 @@ package vault
 import (
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/a13labs/sectool/cmd"
+	internalVault "github.com/a13labs/sectool/internal/vault"
 	"github.com/a13labs/sectool/vault"
 	"github.com/spf13/cobra"
 )
@@ -42,7 +44,27 @@ var setCmd = &cobra.Command{
 		}
 
 		backup, _ := c.Flags().GetBool("backup")
-		err := vault.SetSecret(cmd.ConfigFile, args[0], args[1], backup)
+		description, _ := c.Flags().GetString("description")
+		tags, _ := c.Flags().GetStringSlice("tag")
+		owner, _ := c.Flags().GetString("owner")
+		noExpiry, _ := c.Flags().GetBool("no-expiry")
+		meta := internalVault.SecretMetadata{
+			Description: description,
+			Tags:        tags,
+			Owner:       owner,
+			NoExpiry:    noExpiry,
+		}
+
+		if expires, _ := c.Flags().GetString("expires"); expires != "" {
+			var err error
+			meta.Expires, err = internalVault.ParseExpiry(expires, time.Now())
+			if err != nil {
+				fmt.Printf("Error parsing expiry: %v\n", err)
+				os.Exit(1)
+			}
+		}
+
+		err := vault.SetSecretWithMetadata(cmd.ConfigFile, args[0], args[1], meta, backup)
 		if err != nil {
 			fmt.Println("Error setting key/value.")
 			os.Exit(1)
@@ -54,5 +76,11 @@ var setCmd = &cobra.Command{
 
 func init() {
 	vaultCmd.AddCommand(setCmd)
-	setCmd.Flags().BoolP("backup", "b", false, "Backup vault.")
+	setCmd.Flags().BoolP("backup", "b", false, "Backup vault, even if disabled in the configuration.")
+	setCmd.Flags().StringP("description", "d", "", "Description of the secret")
+	setCmd.Flags().StringSliceP("tag", "t", nil, "Tag of the secret, can be repeated")
+	setCmd.Flags().StringP("owner", "o", "", "Owner of the secret, defaults to the current user")
+	setCmd.Flags().StringP("expires", "e", "", "Expiry of the secret, as a duration (e.g. 90d) or a date, changing the value resets it")
+	setCmd.Flags().Bool("no-expiry", false, "Clear the expiry of the secret")
+	setCmd.MarkFlagsMutuallyExclusive("expires", "no-expiry")
 }
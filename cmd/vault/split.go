@@ -0,0 +1,65 @@
+/*
+Copyright © 2026 Alexandre Pires
+
+Permission is hereby granted, free of charge, to any person obtaining a copy
+of this software and associated documentation files (the "Software"), to deal
+in the Software without restriction, including without limitation the rights
+to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+copies of the Software, and to permit persons to whom the Software is
+furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in
+all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+THE SOFTWARE.
+*/
+package vault
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/vault"
+	"github.com/spf13/cobra"
+)
+
+var splitKey string
+var splitShares int
+var splitThreshold int
+
+// splitCmd represents the split command
+var splitCmd = &cobra.Command{
+	Use:   "split",
+	Short: "Split the vault key into Shamir shares.",
+	Long: `Split the vault key into --shares shares, any --threshold of which rebuild it
+with "sectool vault combine". The key is read from the configuration unless
+--key is given, and must open the vault. Hand each share to a different person,
+fewer than threshold shares reveal nothing about the key.`,
+	Run: func(c *cobra.Command, args []string) {
+
+		shares, err := vault.SplitKey(cmd.ConfigFile, splitKey, splitShares, splitThreshold)
+		if err != nil {
+			fmt.Println("Error splitting key.")
+			os.Exit(1)
+		}
+
+		for i, share := range shares {
+			fmt.Printf("Share %d of %d (%d needed): %s\n", i+1, len(shares), splitThreshold, share)
+		}
+		os.Exit(0)
+	},
+}
+
+func init() {
+	vaultCmd.AddCommand(splitCmd)
+	splitCmd.Flags().StringVar(&splitKey, "key", "", "Vault key, default: configured key")
+	splitCmd.Flags().IntVar(&splitShares, "shares", 5, "Number of shares")
+	splitCmd.Flags().IntVar(&splitThreshold, "threshold", 3, "Number of shares needed to rebuild the key")
+}
@@ -22,19 +22,31 @@ THE SOFTWARE.
 package vault
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"os/exec"
+	"time"
 
 	"github.com/a13labs/sectool/cmd"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/vault"
 	"github.com/spf13/cobra"
 )
 
+var unlockFor string
+
 var unlockCmd = &cobra.Command{
 	Use:   "unlock",
 	Short: "If the provider supports it, unlock the vault.",
-	Long:  ``,
+	Long: `Unlock the vault for editing until it is locked again or the duration given
+with --for expires. While unlocked, reads and writes go to a working copy:
+a plain text file next to a file vault, or a local encrypted cache of an
+object storage vault. The vault is not locked when --for expires: nothing
+runs in the background, the first sectool command run on the vault after the
+expiry locks it again. Until then the plain text working copy stays on disk,
+run "sectool vault lock" when done.`,
 	Run: func(c *cobra.Command, args []string) {
 
 		cfg, err := config.ReadConfig(cmd.ConfigFile)
@@ -49,14 +61,49 @@ var unlockCmd = &cobra.Command{
 			os.Exit(1)
 		}
 
-		err = vaultProvider.Unlock()
+		workingCopyProvider, ok := vaultProvider.(vault.WorkingCopyProvider)
+		if !ok {
+			err = vaultProvider.Unlock()
+			err = audit.Record(cfg, "unlock", nil, err)
+			if err != nil {
+				fmt.Println("Error unlocking vault.")
+				os.Exit(1)
+			}
+			os.Exit(0)
+		}
+
+		d, err := vault.ParseDuration(unlockFor)
+		if err != nil || d == 0 {
+			fmt.Println("Error: --for must be a duration, e.g. 15m.")
+			os.Exit(1)
+		}
+
+		wc, err := workingCopyProvider.VaultUnlockFor(d)
+		err = audit.Record(cfg, "unlock", nil, err)
 		if err != nil {
-			fmt.Println("Error unlocking vault.")
+			fmt.Printf("Error unlocking vault: %v\n", err)
 			os.Exit(1)
 		}
+
+		fmt.Printf("Vault unlocked until %s, working copy: %s\n", wc.Expires.Format(time.RFC3339), wc.Path)
+		warnIfNotIgnored(wc.Path)
+		os.Exit(0)
 	},
 }
 
+// warnIfNotIgnored warns if a plain text working copy is in a git repository
+// and could be committed.
+func warnIfNotIgnored(path string) {
+	// git check-ignore exits with 1 if the path is not ignored
+	err := exec.Command("git", "check-ignore", "-q", path).Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		fmt.Fprintf(os.Stderr, "Warning: '%s' is not ignored by git, add it to .gitignore so it is never committed.\n", path)
+	}
+}
+
 func init() {
 	vaultCmd.AddCommand(unlockCmd)
+	unlockCmd.Flags().StringVar(&unlockFor, "for", "", "How long the vault stays unlocked, e.g. 15m or 1h")
+	unlockCmd.MarkFlagRequired("for")
 }
@@ -0,0 +1,145 @@
+package agent
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/vault"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startServer runs an agent for a file vault in a temporary directory.
+func startServer(t *testing.T, idleTimeout time.Duration) (config.Config, *Client, chan error) {
+	dir := t.TempDir()
+	t.Setenv("SECTOOL_COUNTER_FILE", filepath.Join(dir, "counters.json"))
+	cfg := config.Config{
+		Provider: config.FileProvider,
+		FileVault: &config.FileConfig{
+			Path: filepath.Join(dir, "agent.vault"),
+			Key:  "mysecretkey",
+			KDF:  &config.KDFConfig{Algorithm: "scrypt", LogN: 10},
+		},
+	}
+
+	server, err := NewServer(cfg, idleTimeout)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "agent.sock")
+	done := make(chan error, 1)
+	go func() { done <- server.ListenAndServe(path) }()
+
+	var client *Client
+	require.Eventually(t, func() bool {
+		client, err = Connect(path)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return cfg, client, done
+}
+
+func TestAgent_Client(t *testing.T) {
+	cfg, client, done := startServer(t, 0)
+
+	require.NoError(t, client.VaultSetValue("KEY1", "VALUE1"))
+	require.NoError(t, client.VaultSetValueWithMetadata("KEY2", "VALUE2", vault.SecretMetadata{Owner: "ops"}))
+
+	value, err := client.VaultGetValue("KEY1")
+	require.NoError(t, err)
+	assert.Equal(t, "VALUE1", value)
+	assert.Equal(t, []string{"KEY1", "KEY2"}, client.VaultListKeys())
+	assert.True(t, client.VaultHasKey("KEY2"))
+	assert.False(t, client.VaultHasKey("KEY3"))
+
+	meta, err := client.VaultGetMetadata("KEY2")
+	require.NoError(t, err)
+	assert.Equal(t, "ops", meta.Owner)
+
+	status, err := client.Status()
+	require.NoError(t, err)
+	assert.True(t, status.Cached)
+
+	// Changes made without the agent are seen on the next request
+	fileVault, err := vault.NewFileVault(cfg.FileVault)
+	require.NoError(t, err)
+	require.NoError(t, fileVault.VaultSetValue("KEY1", "CHANGED"))
+	value, err = client.VaultGetValue("KEY1")
+	require.NoError(t, err)
+	assert.Equal(t, "CHANGED", value)
+
+	require.NoError(t, client.VaultDelKey("KEY2"))
+	assert.Equal(t, []string{"KEY1"}, client.VaultListKeys())
+	_, err = client.VaultGetValue("KEY2")
+	assert.Error(t, err)
+
+	require.NoError(t, client.LockAgent())
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the agent to stop once locked")
+	}
+	_, err = client.Status()
+	assert.Error(t, err)
+}
+
+func TestAgent_IdleTimeout(t *testing.T) {
+	_, client, done := startServer(t, 200*time.Millisecond)
+
+	_, err := client.Status()
+	require.NoError(t, err)
+
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the agent to stop when idle")
+	}
+}
+
+func TestAgent_SlowClient(t *testing.T) {
+	cfg, client, _ := startServer(t, 0)
+
+	// A client that never sends its request doesn't hold the others
+	conn, err := net.Dial("unix", filepath.Join(filepath.Dir(cfg.FileVault.Path), "agent.sock"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	answered := make(chan error, 1)
+	go func() {
+		_, err := client.Status()
+		answered <- err
+	}()
+	select {
+	case err := <-answered:
+		assert.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the agent to answer while another client is connected")
+	}
+
+	require.NoError(t, client.LockAgent())
+}
+
+func TestAgent_History(t *testing.T) {
+	_, client, _ := startServer(t, 0)
+
+	require.NoError(t, client.VaultSetValue("KEY1", "v1"))
+	require.NoError(t, client.VaultSetValue("KEY1", "v2"))
+
+	versions, err := client.VaultKeyHistory("KEY1")
+	require.NoError(t, err)
+	require.Len(t, versions, 2)
+
+	require.NoError(t, client.VaultRollback("KEY1", versions[1].Version))
+	value, err := client.VaultGetValue("KEY1")
+	require.NoError(t, err)
+	assert.Equal(t, "v1", value)
+
+	require.NoError(t, client.VaultRenameKeys(map[string]string{"KEY1": "app/KEY1"}))
+	assert.Equal(t, []string{"app/KEY1"}, client.VaultListKeys())
+
+	require.NoError(t, client.LockAgent())
+}
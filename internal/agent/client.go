@@ -0,0 +1,242 @@
+package agent
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/vault"
+)
+
+// dialTimeout bounds how long connecting to the agent may take.
+const dialTimeout = 2 * time.Second
+
+// Client is a vault provider forwarding to a running agent.
+type Client struct {
+	vault.VaultProvider
+	path   string
+	backup bool
+}
+
+// Connect returns a client of the agent listening on the socket, if it is
+// running.
+func Connect(path string) (*Client, error) {
+	c := &Client{path: path}
+	if _, err := c.Status(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// OpenVaultProvider returns a client of the agent holding the vault of the
+// configuration when it is running, or the vault provider otherwise.
+func OpenVaultProvider(cfg config.Config) (vault.VaultProvider, error) {
+	if path, err := SocketPath(cfg); err == nil {
+		if client, err := Connect(path); err == nil {
+			return client, nil
+		}
+	}
+	return vault.NewVaultProvider(cfg)
+}
+
+// Stop locks the agent holding the vault of the configuration, if running.
+// It is used when the vault key changes.
+func Stop(cfg config.Config) error {
+	path, err := SocketPath(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := Connect(path)
+	if err != nil {
+		return nil
+	}
+	return client.LockAgent()
+}
+
+// Start runs an agent in the background for the vault of the configuration,
+// running the executable with args, and waits for it to listen on the socket.
+func Start(path string, args []string) (int, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+
+	cmd := exec.Command(executable, args...)
+	detach(cmd)
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	// The vault is opened before listening, deriving its key may take a while
+	deadline := time.After(time.Minute)
+	for {
+		select {
+		case err := <-exited:
+			return 0, fmt.Errorf("agent exited (%v), run it with --foreground to see why", err)
+		case <-deadline:
+			cmd.Process.Kill()
+			return 0, fmt.Errorf("agent did not start listening on '%s'", path)
+		case <-time.After(100 * time.Millisecond):
+			if _, err := Connect(path); err == nil {
+				return cmd.Process.Pid, nil
+			}
+		}
+	}
+}
+
+// call sends a request to the agent.
+func (c *Client) call(req *request) (*response, error) {
+	conn, err := net.DialTimeout("unix", c.path, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	// A socket replaced by another user must not receive secrets
+	if err := checkPeer(conn); err != nil {
+		return nil, err
+	}
+
+	resp, err := roundTrip(conn, req)
+	if err != nil {
+		return nil, fmt.Errorf("agent request failed: %w", err)
+	}
+	return resp, responseError(resp)
+}
+
+// Status returns the status of the agent.
+func (c *Client) Status() (*Status, error) {
+	resp, err := c.call(&request{Op: opStatus})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Status, nil
+}
+
+// VaultListKeys returns the keys of the vault.
+func (c *Client) VaultListKeys() []string {
+	resp, err := c.call(&request{Op: opList})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing keys: %v\n", err)
+		return nil
+	}
+	return resp.Keys
+}
+
+// VaultHasKey checks if the vault contains the key.
+func (c *Client) VaultHasKey(key string) bool {
+	resp, err := c.call(&request{Op: opHas, Key: key})
+	return err == nil && resp.Found
+}
+
+// VaultGetValue returns the value of a key.
+func (c *Client) VaultGetValue(key string) (string, error) {
+	resp, err := c.call(&request{Op: opGet, Key: key})
+	if err != nil {
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+// VaultGetMultipleValues stores the values of the keys present in the vault.
+func (c *Client) VaultGetMultipleValues(keys []string, kv *crypto.SecureKVStore) error {
+	resp, err := c.call(&request{Op: opGetMultiple, Keys: keys})
+	if err != nil {
+		return err
+	}
+	for key, value := range resp.Values {
+		if err := kv.Put(key, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// VaultSetValue sets the value of a key.
+func (c *Client) VaultSetValue(key, value string) error {
+	_, err := c.call(&request{Op: opSet, Key: key, Value: value, Backup: c.backup})
+	return err
+}
+
+// VaultSetValueWithMetadata sets the value and metadata of a key.
+func (c *Client) VaultSetValueWithMetadata(key, value string, meta vault.SecretMetadata) error {
+	_, err := c.call(&request{Op: opSet, Key: key, Value: value, Metadata: &meta, Backup: c.backup})
+	return err
+}
+
+// VaultDelKey deletes a key.
+func (c *Client) VaultDelKey(key string) error {
+	_, err := c.call(&request{Op: opDel, Key: key, Backup: c.backup})
+	return err
+}
+
+// VaultGetMetadata returns the metadata of a key.
+func (c *Client) VaultGetMetadata(key string) (vault.SecretMetadata, error) {
+	resp, err := c.call(&request{Op: opMetadata, Key: key})
+	if err != nil {
+		return vault.SecretMetadata{}, err
+	}
+	return *resp.Metadata, nil
+}
+
+// VaultListMetadata returns the metadata of every key.
+func (c *Client) VaultListMetadata() (map[string]vault.SecretMetadata, error) {
+	resp, err := c.call(&request{Op: opListMetadata})
+	if err != nil {
+		return nil, err
+	}
+	return resp.MetadataMap, nil
+}
+
+// VaultRenameKeys renames keys, it fails with vault.ErrNotSupported if the
+// vault of the agent can't rename them.
+func (c *Client) VaultRenameKeys(renames map[string]string) error {
+	_, err := c.call(&request{Op: opRename, Renames: renames, Backup: c.backup})
+	return err
+}
+
+// VaultKeyHistory returns the current and previous versions of a key.
+func (c *Client) VaultKeyHistory(key string) ([]vault.SecretVersion, error) {
+	resp, err := c.call(&request{Op: opHistory, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Versions, nil
+}
+
+// VaultRollback makes a previous version of a key the current one.
+func (c *Client) VaultRollback(key string, version int) error {
+	_, err := c.call(&request{Op: opRollback, Key: key, Version: version, Backup: c.backup})
+	return err
+}
+
+// VaultEnableBackup requests a backup before the writes of this client.
+func (c *Client) VaultEnableBackup(value bool) {
+	c.backup = value
+}
+
+// SetSensitiveStrings does nothing, the key of the vault stays in the agent.
+func (c *Client) SetSensitiveStrings(kv *crypto.SecureKVStore) {}
+
+// LockAgent wipes the vault from the agent memory and stops it.
+func (c *Client) LockAgent() error {
+	_, err := c.call(&request{Op: opLock})
+	return err
+}
+
+// Lock is not supported through the agent.
+func (c *Client) Lock() error {
+	return vault.ErrNotSupported
+}
+
+// Unlock is not supported through the agent.
+func (c *Client) Unlock() error {
+	return vault.ErrNotSupported
+}
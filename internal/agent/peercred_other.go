@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd
+
+package agent
+
+import "net"
+
+// peerCredentials reports whether peerUID is supported.
+const peerCredentials = false
+
+// peerUID is not supported, the agent refuses to start.
+func peerUID(conn *net.UnixConn) (int, error) {
+	return 0, errPeerCredentials
+}
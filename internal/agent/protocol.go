@@ -0,0 +1,100 @@
+// Package agent implements a daemon holding an unlocked vault for a session,
+// and the client the commands use to reach it. The daemon listens on a Unix
+// socket private to the user, each connection carries a single JSON request
+// and its response.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"time"
+
+	"github.com/a13labs/sectool/internal/vault"
+)
+
+// Operations understood by the agent.
+const (
+	opStatus       = "status"
+	opList         = "list"
+	opHas          = "has"
+	opGet          = "get"
+	opGetMultiple  = "get_multiple"
+	opSet          = "set"
+	opDel          = "del"
+	opMetadata     = "metadata"
+	opListMetadata = "list_metadata"
+	opRename       = "rename"
+	opHistory      = "history"
+	opRollback     = "rollback"
+	opLock         = "lock"
+)
+
+// requestTimeout bounds how long a connection may take to send its request
+// and to read the response.
+const requestTimeout = 10 * time.Second
+
+// request is sent by the client.
+type request struct {
+	Op       string                `json:"op"`
+	Key      string                `json:"key,omitempty"`
+	Keys     []string              `json:"keys,omitempty"`
+	Value    string                `json:"value,omitempty"`
+	Metadata *vault.SecretMetadata `json:"metadata,omitempty"`
+	Renames  map[string]string     `json:"renames,omitempty"`
+	Version  int                   `json:"version,omitempty"`
+	Backup   bool                  `json:"backup,omitempty"`
+}
+
+// response is returned by the agent, Error is set if the request failed.
+type response struct {
+	Error       string                          `json:"error,omitempty"`
+	Value       string                          `json:"value,omitempty"`
+	Values      map[string]string               `json:"values,omitempty"`
+	Keys        []string                        `json:"keys,omitempty"`
+	Found       bool                            `json:"found,omitempty"`
+	Metadata    *vault.SecretMetadata           `json:"metadata,omitempty"`
+	MetadataMap map[string]vault.SecretMetadata `json:"metadata_map,omitempty"`
+	Versions    []vault.SecretVersion           `json:"versions,omitempty"`
+	Status      *Status                         `json:"status,omitempty"`
+}
+
+// Status describes a running agent.
+type Status struct {
+	PID         int           `json:"pid"`
+	Provider    string        `json:"provider"`
+	Started     time.Time     `json:"started"`
+	IdleTimeout time.Duration `json:"idle_timeout"`
+	LastUsed    time.Time     `json:"last_used"`
+	Cached      bool          `json:"cached"`
+}
+
+// errorResponse returns the response of a failed request, errors the client
+// knows are sent as is so it can return them.
+func errorResponse(err error) *response {
+	return &response{Error: err.Error()}
+}
+
+// responseError returns the error of a response.
+func responseError(resp *response) error {
+	switch resp.Error {
+	case "":
+		return nil
+	case vault.ErrNotSupported.Error():
+		return vault.ErrNotSupported
+	}
+	return errors.New(resp.Error)
+}
+
+// roundTrip sends a request on a connection and reads the response.
+func roundTrip(conn net.Conn, req *request) (*response, error) {
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
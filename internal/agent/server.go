@@ -0,0 +1,380 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/vault"
+)
+
+// Server holds an unlocked vault and answers the requests of the clients. The
+// values are cached in memory for providers reporting a version, the cache is
+// dropped whenever the vault changes.
+type Server struct {
+	provider     vault.VaultProvider
+	providerName string
+	backup       bool
+	idleTimeout  time.Duration
+	started      time.Time
+
+	mu       sync.Mutex
+	lastUsed time.Time
+	cache    *crypto.SecureKVStore
+	metadata map[string]vault.SecretMetadata
+	version  string
+	listener net.Listener
+	idle     *time.Timer
+	stopped  bool
+}
+
+// NewServer creates an agent for the vault described by the configuration,
+// the vault is opened once so a wrong key is reported before it starts. An
+// idle timeout of zero keeps the agent running until it is locked.
+func NewServer(cfg config.Config, idleTimeout time.Duration) (*Server, error) {
+	provider, err := vault.NewVaultProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := provider.VaultGetMultipleValues(nil, crypto.NewSecureKVStore(crypto.NewKeyManager())); err != nil {
+		return nil, err
+	}
+
+	return &Server{
+		provider:     provider,
+		providerName: string(cfg.Provider),
+		backup:       configBackup(cfg),
+		idleTimeout:  idleTimeout,
+		started:      time.Now(),
+		lastUsed:     time.Now(),
+	}, nil
+}
+
+// configBackup returns whether the configuration enables backups, providers
+// are switched back to it after a request enabling them.
+func configBackup(cfg config.Config) bool {
+	var backup *bool
+	switch {
+	case cfg.FileVault != nil && cfg.Provider == config.FileProvider:
+		backup = cfg.FileVault.Backup
+	case cfg.ObjectStorageVault != nil && cfg.Provider == config.ObjectStorageProvider:
+		backup = cfg.ObjectStorageVault.Backup
+	}
+	return backup == nil || *backup
+}
+
+// ListenAndServe serves requests on the socket until the agent is locked or
+// idle for too long.
+func (s *Server) ListenAndServe(path string) error {
+	l, err := listen(path)
+	if err != nil {
+		return err
+	}
+	return s.Serve(l)
+}
+
+// Serve serves requests on the listener until the agent is locked or idle
+// for too long, it closes the listener.
+func (s *Server) Serve(l net.Listener) error {
+	s.mu.Lock()
+	if s.stopped {
+		s.mu.Unlock()
+		l.Close()
+		return nil
+	}
+	s.listener = l
+	if s.idleTimeout > 0 {
+		s.idle = time.AfterFunc(s.idleTimeout, s.Lock)
+	}
+	s.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			s.mu.Lock()
+			stopped := s.stopped
+			s.mu.Unlock()
+			if stopped {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Timeout() {
+				continue
+			}
+			s.Lock()
+			return err
+		}
+
+		go s.handle(conn)
+	}
+}
+
+// Lock wipes the vault from memory and stops the agent.
+func (s *Server) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return
+	}
+	s.stopped = true
+
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	s.dropCache()
+	s.provider = nil
+	if s.listener != nil {
+		s.listener.Close()
+	}
+}
+
+// handle answers the request of a connection in its own goroutine, a slow
+// client doesn't hold the others. Requests are dispatched one at a time.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+
+	if err := checkPeer(conn); err != nil {
+		fmt.Fprintf(os.Stderr, "Agent: %v\n", err)
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(requestTimeout))
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	s.mu.Lock()
+	resp := s.dispatch(&req)
+	s.mu.Unlock()
+
+	conn.SetWriteDeadline(time.Now().Add(requestTimeout))
+	json.NewEncoder(conn).Encode(resp)
+
+	if req.Op == opLock {
+		s.Lock()
+	}
+}
+
+// dispatch runs a request, the caller holds the lock.
+func (s *Server) dispatch(req *request) *response {
+	if s.stopped {
+		return errorResponse(errors.New("agent is locked"))
+	}
+
+	s.lastUsed = time.Now()
+	if s.idle != nil {
+		s.idle.Reset(s.idleTimeout)
+	}
+
+	switch req.Op {
+	case opStatus:
+		return &response{Status: &Status{
+			PID:         os.Getpid(),
+			Provider:    s.providerName,
+			Started:     s.started,
+			IdleTimeout: s.idleTimeout,
+			LastUsed:    s.lastUsed,
+			Cached:      s.cache != nil,
+		}}
+	case opLock:
+		return &response{}
+	case opList:
+		if s.loadCache() {
+			keys := s.cache.ListKeys()
+			sort.Strings(keys)
+			return &response{Keys: keys}
+		}
+		return &response{Keys: s.provider.VaultListKeys()}
+	case opHas:
+		if s.loadCache() {
+			return &response{Found: s.cache.Has(req.Key)}
+		}
+		return &response{Found: s.provider.VaultHasKey(req.Key)}
+	case opGet:
+		if s.loadCache() && s.cache.Has(req.Key) {
+			value, err := s.cache.Get(req.Key)
+			if err == nil {
+				return &response{Value: value}
+			}
+		}
+		value, err := s.provider.VaultGetValue(req.Key)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &response{Value: value}
+	case opGetMultiple:
+		return s.getMultiple(req.Keys)
+	case opSet, opDel, opRename, opRollback:
+		return s.write(req)
+	case opMetadata, opListMetadata:
+		return s.readMetadata(req)
+	case opHistory:
+		historyProvider, ok := s.provider.(vault.HistoryProvider)
+		if !ok {
+			return errorResponse(vault.ErrNotSupported)
+		}
+		versions, err := historyProvider.VaultKeyHistory(req.Key)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &response{Versions: versions}
+	}
+	return errorResponse(fmt.Errorf("unknown agent operation '%s'", req.Op))
+}
+
+// getMultiple returns the values of the keys present in the vault.
+func (s *Server) getMultiple(keys []string) *response {
+	var kv *crypto.SecureKVStore
+	if s.loadCache() {
+		kv = s.cache
+	} else {
+		kv = crypto.NewSecureKVStore(crypto.NewKeyManager())
+		defer kv.Clear()
+		if err := s.provider.VaultGetMultipleValues(keys, kv); err != nil {
+			return errorResponse(err)
+		}
+	}
+
+	values := map[string]string{}
+	for _, key := range keys {
+		if !kv.Has(key) {
+			continue
+		}
+		value, err := kv.Get(key)
+		if err != nil {
+			return errorResponse(err)
+		}
+		values[key] = value
+	}
+	return &response{Values: values}
+}
+
+// write sets, deletes, renames or rolls back keys, the cache is dropped as
+// the vault changed.
+func (s *Server) write(req *request) *response {
+	defer s.dropCache()
+
+	if req.Backup {
+		s.provider.VaultEnableBackup(true)
+		defer s.provider.VaultEnableBackup(s.backup)
+	}
+
+	var err error
+	switch {
+	case req.Op == opDel:
+		err = s.provider.VaultDelKey(req.Key)
+	case req.Op == opRename:
+		err = vault.ErrNotSupported
+		if renameProvider, ok := s.provider.(vault.RenameProvider); ok {
+			err = renameProvider.VaultRenameKeys(req.Renames)
+		}
+	case req.Op == opRollback:
+		err = vault.ErrNotSupported
+		if historyProvider, ok := s.provider.(vault.HistoryProvider); ok {
+			err = historyProvider.VaultRollback(req.Key, req.Version)
+		}
+	case req.Metadata != nil:
+		metadataProvider, ok := s.provider.(vault.MetadataProvider)
+		switch {
+		case ok:
+			err = metadataProvider.VaultSetValueWithMetadata(req.Key, req.Value, *req.Metadata)
+		case !req.Metadata.IsEmpty():
+			err = vault.ErrNotSupported
+		default:
+			err = s.provider.VaultSetValue(req.Key, req.Value)
+		}
+	default:
+		err = s.provider.VaultSetValue(req.Key, req.Value)
+	}
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &response{}
+}
+
+// readMetadata returns the metadata of a key or of all keys.
+func (s *Server) readMetadata(req *request) *response {
+	metadataProvider, ok := s.provider.(vault.MetadataProvider)
+	if !ok {
+		return errorResponse(vault.ErrNotSupported)
+	}
+
+	if req.Op == opListMetadata {
+		if s.loadCache() {
+			return &response{MetadataMap: s.metadata}
+		}
+		metadata, err := metadataProvider.VaultListMetadata()
+		if err != nil {
+			return errorResponse(err)
+		}
+		return &response{MetadataMap: metadata}
+	}
+
+	if s.loadCache() {
+		if meta, found := s.metadata[req.Key]; found {
+			return &response{Metadata: &meta}
+		}
+	}
+	meta, err := metadataProvider.VaultGetMetadata(req.Key)
+	if err != nil {
+		return errorResponse(err)
+	}
+	return &response{Metadata: &meta}
+}
+
+// loadCache makes sure the cache holds the current contents of the vault, it
+// returns false if the provider can't tell when the vault changes.
+func (s *Server) loadCache() bool {
+	versionProvider, ok := s.provider.(vault.VersionProvider)
+	if !ok {
+		return false
+	}
+
+	// The version is read first, a write racing with the load only causes
+	// the next request to load the vault again
+	version, err := versionProvider.VaultVersion()
+	if err != nil {
+		s.dropCache()
+		return false
+	}
+	if s.cache != nil && version == s.version {
+		return true
+	}
+	s.dropCache()
+
+	kv := crypto.NewSecureKVStore(crypto.NewKeyManager())
+	if err := s.provider.VaultGetMultipleValues(s.provider.VaultListKeys(), kv); err != nil {
+		kv.Clear()
+		return false
+	}
+
+	metadata := map[string]vault.SecretMetadata{}
+	if metadataProvider, ok := s.provider.(vault.MetadataProvider); ok {
+		if metadata, err = metadataProvider.VaultListMetadata(); err != nil {
+			kv.Clear()
+			return false
+		}
+	}
+
+	s.cache, s.metadata, s.version = kv, metadata, version
+	return true
+}
+
+// dropCache wipes the cached values, the provider already closed the
+// envelope they were read with.
+func (s *Server) dropCache() {
+	if s.cache != nil {
+		s.cache.Clear()
+	}
+	s.cache, s.metadata, s.version = nil, nil, ""
+}
@@ -0,0 +1,117 @@
+package agent
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/a13labs/sectool/internal/config"
+)
+
+// errPeerCredentials is returned where the uid of a peer can't be checked.
+var errPeerCredentials = errors.New("the agent is not supported on this platform, peer credentials are not available")
+
+// SocketPath returns the socket of the agent holding the vault described by
+// the configuration, SECTOOL_AGENT_SOCK overrides it. Relative vault paths
+// depend on the working directory, so it is part of the name too.
+func SocketPath(cfg config.Config) (string, error) {
+	if path := os.Getenv("SECTOOL_AGENT_SOCK"); path != "" {
+		return path, nil
+	}
+
+	dir, err := socketDir()
+	if err != nil {
+		return "", err
+	}
+
+	contents, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(contents)
+	h.Write([]byte("\x00" + cwd + "\x00" + os.Getenv("FILE_VAULT_PATH")))
+
+	return filepath.Join(dir, "agent-"+hex.EncodeToString(h.Sum(nil)[:8])+".sock"), nil
+}
+
+// socketDir returns the directory holding the agent sockets, only accessible
+// by the user.
+func socketDir() (string, error) {
+	dir := filepath.Join(os.TempDir(), fmt.Sprintf("sectool-%d", os.Getuid()))
+	if runtime := os.Getenv("XDG_RUNTIME_DIR"); runtime != "" {
+		dir = filepath.Join(runtime, "sectool")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	// A directory created by someone else could expose the socket
+	info, err := os.Lstat(dir)
+	if err != nil {
+		return "", err
+	}
+	if !info.IsDir() || info.Mode().Perm() != 0700 || !ownedByUser(info) {
+		return "", fmt.Errorf("'%s' must be a directory only accessible by the user", dir)
+	}
+	return dir, nil
+}
+
+// listen creates the agent socket, a socket left by an agent that is no
+// longer running is replaced.
+func listen(path string) (*net.UnixListener, error) {
+	if !peerCredentials {
+		return nil, errPeerCredentials
+	}
+
+	if conn, err := net.Dial("unix", path); err == nil {
+		conn.Close()
+		return nil, fmt.Errorf("an agent is already running on '%s'", path)
+	}
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("'%s' exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// checkPeer fails unless the other end of the connection runs as the user.
+func checkPeer(conn net.Conn) error {
+	unixConn, ok := conn.(*net.UnixConn)
+	if !ok {
+		return errors.New("not a unix socket connection")
+	}
+
+	uid, err := peerUID(unixConn)
+	if err != nil {
+		return err
+	}
+	if uid != os.Getuid() {
+		return fmt.Errorf("connection from uid %d refused", uid)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package agent
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// ownedByUser reports whether the file belongs to the user.
+func ownedByUser(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	return ok && int(stat.Uid) == os.Getuid()
+}
+
+// detach runs the command in its own session so it outlives the terminal.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setsid: true}
+}
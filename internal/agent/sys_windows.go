@@ -0,0 +1,16 @@
+//go:build windows
+
+package agent
+
+import (
+	"os"
+	"os/exec"
+)
+
+// ownedByUser is not checked, the agent is not supported on Windows.
+func ownedByUser(info os.FileInfo) bool {
+	return true
+}
+
+// detach does nothing, the agent is not supported on Windows.
+func detach(cmd *exec.Cmd) {}
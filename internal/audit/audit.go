@@ -0,0 +1,382 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"time"
+
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/fsutil"
+)
+
+// lockTimeout is how long recording waits for another process writing the
+// log.
+const lockTimeout = 10 * time.Second
+
+// maxEntrySize bounds the size of a line of the log.
+const maxEntrySize = 1024 * 1024
+
+// keySize is the size of the key the entries are authenticated with.
+const keySize = 32
+
+// Command is the sectool command being run, recorded with every entry.
+var Command string
+
+// ErrChainBroken is returned when the log was modified after being written.
+var ErrChainBroken = errors.New("the audit log chain is broken")
+
+// ErrNotRecorded is returned when the audit is required and an operation
+// couldn't be recorded.
+var ErrNotRecorded = errors.New("the operation could not be recorded in the audit log")
+
+// Entry is an operation on the vault. The values of the keys are never
+// recorded. Each entry holds the hash of the previous one, so changing or
+// removing an entry breaks the chain. The hashes are keyed with a key stored
+// in its own file, without it the chain can't be rebuilt.
+type Entry struct {
+	Time      time.Time `json:"time"`
+	User      string    `json:"user"`
+	Host      string    `json:"host"`
+	Command   string    `json:"command"`
+	Operation string    `json:"op"`
+	Provider  string    `json:"provider,omitempty"`
+	Keys      []string  `json:"keys,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	Prev      string    `json:"prev"`
+	Hash      string    `json:"hash,omitempty"`
+}
+
+// digest returns the HMAC of the entry with the log key, computed without
+// its hash field.
+func (e Entry) digest(key []byte) (string, error) {
+	e.Hash = ""
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// HasKey reports whether the entry involves the key.
+func (e Entry) HasKey(key string) bool {
+	for _, k := range e.Keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// LogPath returns the audit log of the configuration, the audit path, the
+// SECTOOL_AUDIT_LOG environment variable or audit.log in the user
+// configuration, in this order. It is empty if the log is disabled.
+func LogPath(cfg *config.Config) string {
+	if cfg.Audit != nil && cfg.Audit.Disabled {
+		return ""
+	}
+	if cfg.Audit != nil && cfg.Audit.Path != "" {
+		return cfg.Audit.Path
+	}
+	if path := os.Getenv("SECTOOL_AUDIT_LOG"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sectool", "audit.log")
+}
+
+// KeyPath returns the key file of the audit log, the audit key_file, the
+// SECTOOL_AUDIT_KEY_FILE environment variable or audit.key in the user
+// configuration, in this order. The default one sits next to the log and is
+// readable by the same user, so the chain only detects changes by other users
+// or by accident, not by the user running sectool. Against them, key_file must
+// point where they can't read or the head must be compared with syslog.
+func KeyPath(cfg *config.Config) string {
+	if cfg.Audit != nil && cfg.Audit.KeyFile != "" {
+		return cfg.Audit.KeyFile
+	}
+	if path := os.Getenv("SECTOOL_AUDIT_KEY_FILE"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sectool", "audit.key")
+}
+
+// ReadKey returns the key of the audit log, a new one is created if the
+// file doesn't exist and create is set.
+func ReadKey(path string, create bool) ([]byte, error) {
+	if path == "" {
+		return nil, errors.New("the audit key file is not defined")
+	}
+
+	key, err := os.ReadFile(path)
+	if os.IsNotExist(err) && create {
+		return createKey(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != keySize {
+		return nil, fmt.Errorf("invalid audit key '%s'", path)
+	}
+	return key, nil
+}
+
+// createKey writes a new random key, unless another process created one
+// first.
+func createKey(path string) ([]byte, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if os.IsExist(err) {
+		return ReadKey(path, false)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(key); err != nil {
+		f.Close()
+		os.Remove(path)
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(path)
+		return nil, err
+	}
+	return key, nil
+}
+
+// Record appends an operation on the keys to the audit log of the
+// configuration and sends it to syslog if configured, with its hash and the
+// previous one so the head of the log is kept outside of it. It returns the
+// error of the operation. Failing to record is reported but doesn't fail the
+// operation, unless the audit is required, then ErrNotRecorded is returned.
+func Record(cfg *config.Config, op string, keys []string, opErr error) error {
+	entry := newEntry(cfg, op, keys, opErr)
+	required := cfg.Audit != nil && cfg.Audit.Required
+	level := "Warning"
+	if required {
+		level = "Error"
+	}
+
+	var recordErr error
+	if path := LogPath(cfg); path != "" {
+		key, err := ReadKey(KeyPath(cfg), true)
+		if err == nil {
+			err = Append(path, key, entry)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: the audit log could not be written: %v\n", level, err)
+			recordErr = err
+		}
+	}
+
+	if cfg.Audit != nil && cfg.Audit.Syslog != nil {
+		if err := sendSyslog(cfg.Audit.Syslog, entry); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: the audit event could not be sent to syslog: %v\n", level, err)
+			recordErr = err
+		}
+	}
+
+	if opErr == nil && required && recordErr != nil {
+		return fmt.Errorf("%w: %v", ErrNotRecorded, recordErr)
+	}
+	return opErr
+}
+
+// newEntry describes an operation run by the current user.
+func newEntry(cfg *config.Config, op string, keys []string, opErr error) *Entry {
+	entry := &Entry{
+		Time:      time.Now().UTC(),
+		User:      currentUser(),
+		Command:   Command,
+		Operation: op,
+		Provider:  string(cfg.Provider),
+		Keys:      keys,
+	}
+	entry.Host, _ = os.Hostname()
+	if opErr != nil {
+		entry.Error = opErr.Error()
+	}
+	return entry
+}
+
+// currentUser returns the name of the user running sectool.
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return os.Getenv("USER")
+}
+
+// Append chains the entry to the last one of the log, authenticated with the
+// key, and appends it.
+func Append(path string, key []byte, entry *Entry) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+
+	l, err := fsutil.LockFile(path+".lock", true, lockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, fsutil.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	last, err := lastLine(f)
+	if err != nil {
+		return err
+	}
+	entry.Prev = ""
+	if last != nil {
+		var previous Entry
+		if err := json.Unmarshal(last, &previous); err != nil {
+			return fmt.Errorf("%w: invalid last entry", ErrChainBroken)
+		}
+		entry.Prev = previous.Hash
+	}
+
+	if entry.Hash, err = entry.digest(key); err != nil {
+		return err
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+// lastLine returns the last line of the file, read backwards from its end,
+// or nil if the file is empty.
+func lastLine(f *os.File) ([]byte, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	end := info.Size()
+	var line []byte
+	chunk := make([]byte, 4096)
+	for offset := end; offset > 0; {
+		n := int64(len(chunk))
+		if offset < n {
+			n = offset
+		}
+		offset -= n
+		if _, err := f.ReadAt(chunk[:n], offset); err != nil {
+			return nil, err
+		}
+		line = append(append([]byte{}, chunk[:n]...), line...)
+
+		// The file ends with a newline, the line starts after the one before
+		trimmed := bytes.TrimSuffix(line, []byte("\n"))
+		if i := bytes.LastIndexByte(trimmed, '\n'); i >= 0 {
+			return trimmed[i+1:], nil
+		}
+		if offset == 0 {
+			return trimmed, nil
+		}
+		if int64(len(line)) > maxEntrySize {
+			return nil, fmt.Errorf("%w: last entry too long", ErrChainBroken)
+		}
+	}
+	return nil, nil
+}
+
+// Read returns the entries of the log, without checking the chain.
+func Read(path string) ([]Entry, error) {
+	entries := []Entry{}
+	err := scan(path, func(n int, entry *Entry) error {
+		entries = append(entries, *entry)
+		return nil
+	})
+	return entries, err
+}
+
+// Verify checks the chain of the log with its key and returns the number of
+// entries and the hash of the last one, it fails with ErrChainBroken on the
+// first entry that was changed, removed or inserted. Removing the last
+// entries keeps the chain valid, the head must be compared with one kept
+// elsewhere, such as the last event sent to syslog.
+func Verify(path string, key []byte) (int, string, error) {
+	count, prev := 0, ""
+	err := scan(path, func(n int, entry *Entry) error {
+		digest, err := entry.digest(key)
+		if err != nil {
+			return err
+		}
+		if entry.Prev != prev || !hmac.Equal([]byte(entry.Hash), []byte(digest)) {
+			return fmt.Errorf("%w at line %d", ErrChainBroken, n)
+		}
+		count, prev = count+1, entry.Hash
+		return nil
+	})
+	return count, prev, err
+}
+
+// scan calls fn with every entry of the log and its line number.
+func scan(path string, fn func(n int, entry *Entry) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			return nil
+		}
+		if err == io.EOF {
+			return fmt.Errorf("%w: incomplete line %d", ErrChainBroken, n)
+		}
+		if err != nil {
+			return err
+		}
+
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("%w: invalid line %d", ErrChainBroken, n)
+		}
+		if err := fn(n, &entry); err != nil {
+			return err
+		}
+	}
+}
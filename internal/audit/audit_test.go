@@ -0,0 +1,123 @@
+package audit
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/a13labs/sectool/internal/config"
+)
+
+func TestAudit_Chain(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "audit", "audit.log")
+	keyPath := filepath.Join(dir, "keys", "audit.key")
+	cfg := &config.Config{Provider: config.FileProvider, Audit: &config.AuditConfig{Path: path, KeyFile: keyPath}}
+
+	Command = "sectool vault get"
+	Record(cfg, "get", []string{"PROD_DB_PASSWORD"}, nil)
+	Record(cfg, "set", []string{"OTHER"}, nil)
+	Record(cfg, "get", []string{"PROD_DB_PASSWORD"}, errors.New("key not found"))
+
+	info, err := os.Stat(keyPath)
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Fatalf("Expected a private audit key, got %v (%v)", info, err)
+	}
+	key, err := ReadKey(keyPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	count, head, err := Verify(path, key)
+	if err != nil || count != 3 {
+		t.Fatalf("Expected 3 valid entries, got %d (%v)", count, err)
+	}
+
+	entries, err := Read(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head != entries[2].Hash {
+		t.Errorf("Expected the head to be the hash of the last entry, got %s", head)
+	}
+
+	// Without the key the chain can't be rebuilt
+	if _, _, err := Verify(path, make([]byte, keySize)); !errors.Is(err, ErrChainBroken) {
+		t.Errorf("Expected ErrChainBroken with another key, got %v", err)
+	}
+	matching := 0
+	for _, entry := range entries {
+		if entry.HasKey("PROD_DB_PASSWORD") {
+			matching++
+		}
+	}
+	if matching != 2 {
+		t.Errorf("Expected 2 entries for PROD_DB_PASSWORD, got %d", matching)
+	}
+	if entries[0].Command != "sectool vault get" || entries[0].User == "" || entries[2].Error != "key not found" {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.SplitAfter(string(contents), "\n")
+	tampered := map[string]string{
+		"changed":   lines[0] + strings.Replace(lines[1], "OTHER", "ANOTHER", 1) + lines[2],
+		"removed":   lines[0] + lines[2],
+		"truncated": lines[0] + lines[1] + lines[2][:10],
+	}
+	for name, data := range tampered {
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err := Verify(path, key); !errors.Is(err, ErrChainBroken) {
+			t.Errorf("Expected ErrChainBroken for a %s entry, got %v", name, err)
+		}
+	}
+}
+
+func TestAudit_Disabled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	t.Setenv("SECTOOL_AUDIT_LOG", path)
+	t.Setenv("SECTOOL_AUDIT_KEY_FILE", path+".key")
+
+	if got := LogPath(&config.Config{}); got != path {
+		t.Errorf("Expected '%s', got '%s'", path, got)
+	}
+	if got := KeyPath(&config.Config{}); got != path+".key" {
+		t.Errorf("Expected '%s', got '%s'", path+".key", got)
+	}
+
+	cfg := &config.Config{Audit: &config.AuditConfig{Disabled: true}}
+	Record(cfg, "get", []string{"KEY"}, nil)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Expected no audit log when disabled")
+	}
+}
+
+func TestAudit_Required(t *testing.T) {
+	dir := t.TempDir()
+	// The key can't be created under a regular file
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &config.Config{Audit: &config.AuditConfig{Path: filepath.Join(dir, "audit.log"), KeyFile: filepath.Join(blocker, "audit.key")}}
+
+	if err := Record(cfg, "get", []string{"KEY"}, nil); err != nil {
+		t.Errorf("Expected the failure to be ignored, got %v", err)
+	}
+
+	cfg.Audit.Required = true
+	if err := Record(cfg, "get", []string{"KEY"}, nil); !errors.Is(err, ErrNotRecorded) {
+		t.Errorf("Expected ErrNotRecorded, got %v", err)
+	}
+	opErr := errors.New("key not found")
+	if err := Record(cfg, "get", []string{"KEY"}, opErr); err != opErr {
+		t.Errorf("Expected the error of the operation, got %v", err)
+	}
+}
@@ -0,0 +1,14 @@
+//go:build windows || plan9
+
+package audit
+
+import (
+	"errors"
+
+	"github.com/a13labs/sectool/internal/config"
+)
+
+// sendSyslog is not supported, syslog is not available on this platform.
+func sendSyslog(c *config.SyslogConfig, entry *Entry) error {
+	return errors.New("syslog is not supported on this platform")
+}
@@ -0,0 +1,31 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"encoding/json"
+	"log/syslog"
+
+	"github.com/a13labs/sectool/internal/config"
+)
+
+// sendSyslog sends the entry as JSON to the configured syslog server, with
+// the auth facility.
+func sendSyslog(c *config.SyslogConfig, entry *Entry) error {
+	tag := c.Tag
+	if tag == "" {
+		tag = "sectool"
+	}
+
+	w, err := syslog.Dial(c.Network, c.Address, syslog.LOG_AUTH|syslog.LOG_INFO, tag)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return w.Info(string(data))
+}
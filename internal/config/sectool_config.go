@@ -23,12 +23,71 @@ type Config struct {
 	BitwardenVault     *BitwardenConfig     `json:"bitwarden,omitempty"`
 	ObjectStorageVault *ObjectStorageConfig `json:"object_storage,omitempty"`
 	SSHPasswordKey     string               `json:"ssh_password_key,omitempty"`
+	Audit              *AuditConfig         `json:"audit,omitempty"`
+	DefaultProfile     string               `json:"default_profile,omitempty"`
+	Profiles           map[string]*Config   `json:"profiles,omitempty"`
 }
 
+const (
+	// VaultFormatBinary stores the file vault as a single encrypted blob.
+	VaultFormatBinary = "binary"
+	// VaultFormatGit stores the file vault with a line per secret.
+	VaultFormatGit = "git"
+)
+
 // FileConfig represents the configuration for the file provider
 type FileConfig struct {
-	Key  string `json:"key,omitempty"`
-	Path string `json:"path"`
+	ID          string           `json:"id,omitempty"`
+	Key         string           `json:"key,omitempty"`
+	Identity    string           `json:"identity,omitempty"`
+	SSHAgent    *SSHAgentConfig  `json:"ssh_agent,omitempty"`
+	Path        string           `json:"path"`
+	Format      string           `json:"format,omitempty"`
+	KDF         *KDFConfig       `json:"kdf,omitempty"`
+	LockTimeout string           `json:"lock_timeout,omitempty"`
+	History     int              `json:"history,omitempty"`
+	Backup      *bool            `json:"backup,omitempty"`
+	Retention   *RetentionConfig `json:"retention,omitempty"`
+}
+
+// SSHAgentConfig represents an ssh-agent key the vault key is derived from
+type SSHAgentConfig struct {
+	Key       string `json:"key"`
+	Challenge string `json:"challenge,omitempty"`
+}
+
+// RetentionConfig represents the retention policy of vault backups
+type RetentionConfig struct {
+	KeepLast int `json:"keep_last,omitempty"`
+	KeepDays int `json:"keep_days,omitempty"`
+}
+
+// KDFConfig represents the key derivation parameters used to encrypt a vault
+type KDFConfig struct {
+	Algorithm string `json:"algorithm,omitempty"`
+	Time      uint32 `json:"time,omitempty"`
+	Memory    uint32 `json:"memory,omitempty"`
+	Threads   uint8  `json:"threads,omitempty"`
+	LogN      uint8  `json:"log_n,omitempty"`
+	R         uint32 `json:"r,omitempty"`
+	P         uint32 `json:"p,omitempty"`
+}
+
+// AuditConfig represents where the vault operations are recorded
+type AuditConfig struct {
+	Path     string        `json:"path,omitempty"`
+	KeyFile  string        `json:"key_file,omitempty"`
+	Disabled bool          `json:"disabled,omitempty"`
+	Required bool          `json:"required,omitempty"`
+	Syslog   *SyslogConfig `json:"syslog,omitempty"`
+}
+
+// SyslogConfig represents the syslog server audit events are sent to, the
+// local one if no address is set
+type SyslogConfig struct {
+	Network string `json:"network,omitempty"`
+	Address string `json:"address,omitempty"`
+	Tag     string `json:"tag,omitempty"`
 }
 
 // BitwardenConfig represents the configuration for the Bitwarden provider
@@ -41,13 +100,23 @@ type BitwardenConfig struct {
 }
 
 type ObjectStorageConfig struct {
-	Region   string `json:"region"`
-	Endpoint string `json:"endpoint"`
-	Bucket   string `json:"bucket"`
-	Key      string `json:"key"`
-	Backup   bool   `json:"backup"`
+	ID        string           `json:"id,omitempty"`
+	Region    string           `json:"region"`
+	Endpoint  string           `json:"endpoint"`
+	Bucket    string           `json:"bucket"`
+	Key       string           `json:"key"`
+	Identity  string           `json:"identity,omitempty"`
+	SSHAgent  *SSHAgentConfig  `json:"ssh_agent,omitempty"`
+	Backup    *bool            `json:"backup,omitempty"`
+	Retention *RetentionConfig `json:"retention,omitempty"`
+	KDF       *KDFConfig       `json:"kdf,omitempty"`
+	History   int              `json:"history,omitempty"`
 }
 
+// Profile is the profile selected on the command line, if empty the
+// SECTOOL_PROFILE environment variable or the default profile is used.
+var Profile string
+
 var (
 	// DefaultConfigFile is the default configuration file
 	defaultConfig = Config{
@@ -70,7 +139,7 @@ func ReadConfig(config_file string) (*Config, error) {
 	}
 
 	if _, err := os.Stat(config_file); os.IsNotExist(err) {
-		return &defaultConfig, nil
+		return defaultConfig.SelectProfile(Profile)
 	}
 
 	file, err := os.Open(config_file)
@@ -89,5 +158,40 @@ func ReadConfig(config_file string) (*Config, error) {
 		return nil, fmt.Errorf("failed to unmarshal config file: %w", err)
 	}
 
-	return &config, nil
+	return config.SelectProfile(Profile)
+}
+
+// SelectProfile returns the configuration of a profile. If name is empty the
+// SECTOOL_PROFILE environment variable or the default profile is used, and
+// without any of them the top level configuration is returned. Settings not
+// specific to a provider are inherited from the top level configuration.
+func (c *Config) SelectProfile(name string) (*Config, error) {
+	if name == "" {
+		name = os.Getenv("SECTOOL_PROFILE")
+	}
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return c, nil
+	}
+
+	profile, ok := c.Profiles[name]
+	if !ok || profile == nil {
+		return nil, fmt.Errorf("profile '%s' not found", name)
+	}
+
+	selected := *profile
+	selected.DefaultProfile = ""
+	selected.Profiles = nil
+	if selected.Provider == "" {
+		selected.Provider = FileProvider
+	}
+	if selected.SSHPasswordKey == "" {
+		selected.SSHPasswordKey = c.SSHPasswordKey
+	}
+	if selected.Audit == nil {
+		selected.Audit = c.Audit
+	}
+	return &selected, nil
 }
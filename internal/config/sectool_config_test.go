@@ -0,0 +1,78 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const profilesConfig = `{
+	"provider": "file",
+	"file": {"path": "root.vault"},
+	"ssh_password_key": "ssh_key",
+	"audit": {"path": "audit.log"},
+	"default_profile": "dev",
+	"profiles": {
+		"dev": {"file": {"path": "dev.vault"}},
+		"prod": {
+			"provider": "object_storage",
+			"object_storage": {"bucket": "prod"},
+			"ssh_password_key": "prod_ssh_key"
+		}
+	}
+}`
+
+func TestReadConfig_Profiles(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sectool.json")
+	if err := os.WriteFile(path, []byte(profilesConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("SECTOOL_PROFILE", "")
+	defer func() { Profile = "" }()
+
+	cfg, err := ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Provider != FileProvider || cfg.FileVault.Path != "dev.vault" || cfg.SSHPasswordKey != "ssh_key" {
+		t.Errorf("Expected the default profile, got %+v", cfg)
+	}
+	if cfg.Audit == nil || cfg.Audit.Path != "audit.log" {
+		t.Errorf("Expected the audit log to be inherited, got %+v", cfg.Audit)
+	}
+
+	t.Setenv("SECTOOL_PROFILE", "prod")
+	cfg, err = ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Provider != ObjectStorageProvider || cfg.ObjectStorageVault.Bucket != "prod" || cfg.SSHPasswordKey != "prod_ssh_key" {
+		t.Errorf("Expected the prod profile, got %+v", cfg)
+	}
+
+	// The command line takes precedence over the environment
+	Profile = "dev"
+	cfg, err = ReadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.FileVault == nil || cfg.FileVault.Path != "dev.vault" {
+		t.Errorf("Expected the dev profile, got %+v", cfg)
+	}
+
+	Profile = "missing"
+	if _, err := ReadConfig(path); err == nil {
+		t.Error("Expected error for a missing profile")
+	}
+}
+
+func TestSelectProfile_NoProfiles(t *testing.T) {
+	t.Setenv("SECTOOL_PROFILE", "")
+
+	cfg := &Config{Provider: FileProvider, FileVault: &FileConfig{Path: "repository.vault"}}
+	selected, err := cfg.SelectProfile("")
+	if err != nil || selected != cfg {
+		t.Errorf("Expected the top level configuration, got %+v (%v)", selected, err)
+	}
+}
@@ -0,0 +1,26 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// ErrNoContext is returned when data expected to be bound to a context was
+// encrypted without one, by a version predating the binding. Such data is
+// only accepted by an explicit migration, which decrypts it without the
+// context and encrypts it again bound to it.
+var ErrNoContext = errors.New("ciphertext is not bound to its context")
+
+// AssociatedData encodes the context a ciphertext belongs to, such as a vault
+// ID or a key name, as associated data: it is authenticated along with the
+// ciphertext but not stored in it, so decrypting requires the same context
+// and a ciphertext moved elsewhere fails to open. Fields are length prefixed
+// so they can't run into each other.
+func AssociatedData(fields ...string) []byte {
+	var ad []byte
+	for _, field := range fields {
+		ad = binary.BigEndian.AppendUint32(ad, uint32(len(field)))
+		ad = append(ad, field...)
+	}
+	return ad
+}
@@ -1,188 +1,209 @@
 package crypto
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
+	"errors"
 	"io"
 	"os"
-)
 
-// DecryptFromReader decrypts data read from an io.Reader.
-func DecryptFromReader(reader io.Reader, key []byte) (string, error) {
-	// Create a new SHA-256 hash of the key
-	hash := sha256.Sum256(key)
+	"github.com/a13labs/sectool/internal/fsutil"
+)
 
-	// Read the encrypted data from the reader
-	encryptedData, err := io.ReadAll(reader)
+// newGCM creates an AES-GCM cipher instance for a 256-bit key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	// Create a new AES cipher block using the key
+	block, err := aes.NewCipher(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Decode the base64-encoded data
-	decodedData, err := base64.StdEncoding.DecodeString(string(encryptedData))
-	if err != nil {
-		return "", err
-	}
+	// Create a new GCM cipher instance
+	return cipher.NewGCMWithNonceSize(block, nonceSize)
+}
 
-	// Extract nonce and cipherText from the decoded data
-	nonce := decodedData[:12]
-	cipherText := decodedData[12:]
+// newLegacyGCM creates an AES-GCM cipher instance for the SHA-256 hash of
+// the key.
+func newLegacyGCM(key []byte) (cipher.AEAD, error) {
+	hashedKey := legacyKey(key)
+	defer Wipe(hashedKey)
+	return newGCM(hashedKey)
+}
 
-	// Create a new AES cipher block using the hashed key
-	block, err := aes.NewCipher(hash[:])
+// seal encrypts data using a key derived from the password, the result is
+// header | nonce | cipherText where the header is authenticated.
+func seal(plainData []byte, password []byte, params KDFParams) ([]byte, error) {
+	header, err := newKDFHeader(params)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Create a new GCM cipher instance
-	aesGCM, err := cipher.NewGCMWithNonceSize(block, 12)
+	derivedKey, err := deriveKey(password, header.salt, header.params)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Decrypt the cipherText using AES-GCM and nonce
-	plainText, err := aesGCM.Open(nil, nonce, cipherText, nil)
+	aesGCM, err := newGCM(derivedKey)
+	Wipe(derivedKey)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return string(plainText), nil
+	// Generate a random nonce
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	headerData := header.marshal()
+	encryptedData := append(headerData, nonce...)
+	return aesGCM.Seal(encryptedData, nonce, plainData, headerData), nil
 }
 
-// EncryptFromReader encrypts data read from an io.Reader.
-func EncryptFromReader(reader io.Reader, key []byte) (string, error) {
-	// Create a new SHA-256 hash of the key
-	hash := sha256.Sum256(key)
+// open decrypts data produced by seal, falling back to the legacy
+// headerless format.
+func open(encryptedData []byte, password []byte) ([]byte, error) {
+	if !hasKDFHeader(encryptedData) {
+		return openLegacy(encryptedData, password)
+	}
 
-	// Read the plain data from the reader
-	plainData, err := io.ReadAll(reader)
-	if err != nil {
-		return "", err
+	header, n, err := parseKDFHeader(encryptedData)
+	if err == nil {
+		var plainData []byte
+		plainData, err = openWithHeader(encryptedData, n, header, password)
+		if err == nil {
+			return plainData, nil
+		}
 	}
 
-	// Create a new AES cipher block using the hashed key
-	block, err := aes.NewCipher(hash[:])
-	if err != nil {
-		return "", err
+	// A legacy nonce may start with the header magic by chance
+	if plainData, legacyErr := openLegacy(encryptedData, password); legacyErr == nil {
+		return plainData, nil
 	}
 
-	// Generate a random nonce
-	nonce := make([]byte, 12)
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
+	return nil, err
+}
+
+// openWithHeader decrypts the payload following a parsed header.
+func openWithHeader(encryptedData []byte, n int, header *kdfHeader, password []byte) ([]byte, error) {
+	if len(encryptedData) < n+nonceSize {
+		return nil, errors.New("ciphertext too short")
 	}
 
-	// Create a new GCM cipher instance
-	aesGCM, err := cipher.NewGCMWithNonceSize(block, 12)
+	derivedKey, err := deriveKey(password, header.salt, header.params)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	// Encrypt the plain data using AES-GCM
-	cipherText := aesGCM.Seal(nil, nonce, plainData, nil)
-
-	// Combine nonce and cipherText and base64 encode the result
-	encryptedData := append(nonce, cipherText...)
-	encodedData := base64.StdEncoding.EncodeToString(encryptedData)
+	aesGCM, err := newGCM(derivedKey)
+	Wipe(derivedKey)
+	if err != nil {
+		return nil, err
+	}
 
-	return encodedData, nil
+	nonce := encryptedData[n : n+nonceSize]
+	cipherText := encryptedData[n+nonceSize:]
+	return aesGCM.Open(nil, nonce, cipherText, encryptedData[:n])
 }
 
-func EncryptToBytes(input string, key []byte) ([]byte, error) {
-	// Create a new SHA-256 hash of the key
-	hash := sha256.Sum256(key)
-
-	// Create a new AES cipher block using the hashed key
-	block, err := aes.NewCipher(hash[:])
+// sealLegacy encrypts data using the SHA-256 hash of the key and no header,
+// it is only suitable for random keys.
+func sealLegacy(plainData []byte, key []byte) ([]byte, error) {
+	aesGCM, err := newLegacyGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
 	// Generate a random nonce
-	nonce := make([]byte, 12)
+	nonce := make([]byte, nonceSize)
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 
-	// Create a new GCM cipher instance
-	aesGCM, err := cipher.NewGCMWithNonceSize(block, 12)
+	// Combine nonce and cipherText
+	return aesGCM.Seal(nonce, nonce, plainData, nil), nil
+}
+
+// openLegacy decrypts headerless data produced by sealLegacy.
+func openLegacy(encryptedData []byte, key []byte) ([]byte, error) {
+	if len(encryptedData) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	aesGCM, err := newLegacyGCM(key)
 	if err != nil {
 		return nil, err
 	}
 
-	// Encrypt the input using AES-GCM
-	cipherText := aesGCM.Seal(nil, nonce, []byte(input), nil)
-
-	// Combine nonce and cipherText and base64 encode the result
-	encryptedData := append(nonce, cipherText...)
-	encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-	return []byte(encodedData), nil
+	// Extract nonce and cipherText from the data
+	nonce := encryptedData[:nonceSize]
+	cipherText := encryptedData[nonceSize:]
+	return aesGCM.Open(nil, nonce, cipherText, nil)
 }
 
-func Encrypt(input string, key []byte) (string, error) {
-	// Create a new SHA-256 hash of the key
-	hash := sha256.Sum256(key)
-
-	// Create a new AES cipher block using the hashed key
-	block, err := aes.NewCipher(hash[:])
-	if err != nil {
+// DecryptFromReader decrypts a stream or base64 data read from an io.Reader.
+func DecryptFromReader(reader io.Reader, key []byte) (string, error) {
+	var plainData bytes.Buffer
+	if err := DecryptStream(&plainData, reader, key); err != nil {
 		return "", err
 	}
 
-	// Generate a random nonce
-	nonce := make([]byte, 12)
-	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
-		return "", err
-	}
+	return plainData.String(), nil
+}
 
-	// Create a new GCM cipher instance
-	aesGCM, err := cipher.NewGCMWithNonceSize(block, 12)
-	if err != nil {
+// EncryptFromReader encrypts data read from an io.Reader in the stream
+// format, read back with DecryptFromReader. The input is streamed, use
+// EncryptStream to write the ciphertext to an io.Writer as well.
+func EncryptFromReader(reader io.Reader, key []byte) (string, error) {
+	var encryptedData bytes.Buffer
+	if err := EncryptStream(&encryptedData, reader, key); err != nil {
 		return "", err
 	}
 
-	// Encrypt the input using AES-GCM
-	cipherText := aesGCM.Seal(nil, nonce, []byte(input), nil)
-
-	// Combine nonce and cipherText and base64 encode the result
-	encryptedData := append(nonce, cipherText...)
-	encodedData := base64.StdEncoding.EncodeToString(encryptedData)
-
-	return encodedData, nil
+	return encryptedData.String(), nil
 }
 
-func Decrypt(encryptedBase64 string, key []byte) (string, error) {
-	// Create a new SHA-256 hash of the key
-	hash := sha256.Sum256(key)
+// EncryptToBytes encrypts the input and returns the base64 encoded result.
+func EncryptToBytes(input string, key []byte) ([]byte, error) {
+	return EncryptToBytesWithParams(input, key, DefaultKDFParams)
+}
 
-	// Decode the base64-encoded data
-	encryptedData, err := base64.StdEncoding.DecodeString(encryptedBase64)
+// EncryptToBytesWithParams encrypts the input using the given KDF parameters.
+func EncryptToBytesWithParams(input string, key []byte, params KDFParams) ([]byte, error) {
+	encodedData, err := EncryptWithParams(input, key, params)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
+	return []byte(encodedData), nil
+}
 
-	// Extract nonce and cipherText from the encrypted data
-	nonce := encryptedData[:12]
-	cipherText := encryptedData[12:]
+// Encrypt encrypts the input and returns the base64 encoded result.
+func Encrypt(input string, key []byte) (string, error) {
+	return EncryptWithParams(input, key, DefaultKDFParams)
+}
 
-	// Create a new AES cipher block using the hashed key
-	block, err := aes.NewCipher(hash[:])
+// EncryptWithParams encrypts the input using the given KDF parameters.
+func EncryptWithParams(input string, key []byte, params KDFParams) (string, error) {
+	encryptedData, err := seal([]byte(input), key, params)
 	if err != nil {
 		return "", err
 	}
 
-	// Create a new GCM cipher instance
-	aesGCM, err := cipher.NewGCMWithNonceSize(block, 12)
+	return base64.StdEncoding.EncodeToString(encryptedData), nil
+}
+
+// Decrypt decrypts base64 encoded data, with or without a KDF header.
+func Decrypt(encryptedBase64 string, key []byte) (string, error) {
+	// Decode the base64-encoded data
+	encryptedData, err := base64.StdEncoding.DecodeString(encryptedBase64)
 	if err != nil {
 		return "", err
 	}
 
-	// Decrypt the cipherText using AES-GCM and nonce
-	plainText, err := aesGCM.Open(nil, nonce, cipherText, nil)
+	plainText, err := open(encryptedData, key)
 	if err != nil {
 		return "", err
 	}
@@ -236,80 +257,43 @@ func EncryptToFile(input string, outputFilePath string, key []byte, append bool)
 		return err
 	}
 
-	// Open the output file in append or overwrite mode
-	var file *os.File
-	if append {
-		file, err = os.OpenFile(outputFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	} else {
-		file, err = os.Create(outputFilePath)
-	}
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
 	// Write the encrypted data to the file
-	_, err = file.WriteString(encryptedData)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return writeOutput(outputFilePath, []byte(encryptedData), append)
 }
 
-// Read from a source text file, encrypt, and write to a target file
+// Read from a source file, encrypt it as a stream, and write to a target file
 func EncryptFile(sourceFilePath string, targetFilePath string, key []byte) error {
-	encryptedData, err := EncryptFromFile(sourceFilePath, key)
-	if err != nil {
-		return err
-	}
+	return EncryptFileWithContext(sourceFilePath, targetFilePath, key, nil)
+}
 
-	// Write the encrypted data to the target file
-	err = os.WriteFile(targetFilePath, []byte(encryptedData), 0644)
+// EncryptFileWithContext encrypts a file like EncryptFile, binding it to the
+// associated data so it only decrypts in the same context.
+func EncryptFileWithContext(sourceFilePath string, targetFilePath string, key []byte, ad []byte) error {
+	source, err := os.Open(sourceFilePath)
 	if err != nil {
 		return err
 	}
+	defer source.Close()
 
-	return nil
+	return fsutil.WriteAtomic(targetFilePath, fsutil.DefaultFileMode, func(w io.Writer) error {
+		return EncryptStreamWithContext(w, source, key, ad)
+	})
 }
 
 // Decrypt data from the stdin pipe
 func DecryptStdin(key []byte) (string, error) {
-	// Read data from stdin
-	data, err := io.ReadAll(os.Stdin)
-	if err != nil {
-		return "", err
-	}
-
-	// Decrypt the data
-	decryptedData, err := Decrypt(string(data), key)
-	if err != nil {
-		return "", err
-	}
-
-	// Print the decrypted data to stdout
-	return decryptedData, nil
+	return DecryptFromReader(os.Stdin, key)
 }
 
-// Decrypt data read from a text file
+// Decrypt data read from a file, either a stream or base64 text
 func DecryptFromFile(inputFilePath string, key []byte) (string, error) {
-	// Read data from the input file
-	data, err := os.ReadFile(inputFilePath)
-	if err != nil {
-		return "", err
-	}
-
-	if len(data) == 0 {
-		return "", nil
-	}
-
-	// Decrypt the data
-	decryptedData, err := Decrypt(string(data), key)
+	file, err := os.Open(inputFilePath)
 	if err != nil {
 		return "", err
 	}
+	defer file.Close()
 
-	return decryptedData, nil
+	return DecryptFromReader(file, key)
 }
 
 // Decrypt an input string to a file, with an option to append or overwrite
@@ -319,39 +303,47 @@ func DecryptToFile(input string, outputFilePath string, key []byte, append bool)
 		return err
 	}
 
-	// Open the output file in append or overwrite mode
-	var file *os.File
-	if append {
-		file, err = os.OpenFile(outputFilePath, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	} else {
-		file, err = os.Create(outputFilePath)
-	}
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
 	// Write the decrypted data to the file
-	_, err = file.WriteString(decryptedData)
-	if err != nil {
-		return err
-	}
-
-	return nil
+	return writeOutput(outputFilePath, []byte(decryptedData), append)
 }
 
-// Read from a source text file, decrypt, and write to a target file
+// Read from a source file, decrypt it, and write to a target file. The
+// target is only replaced once the whole source is authenticated.
 func DecryptFile(sourceFilePath string, targetFilePath string, key []byte) error {
-	decryptedData, err := DecryptFromFile(sourceFilePath, key)
+	return DecryptFileWithContext(sourceFilePath, targetFilePath, key, nil)
+}
+
+// DecryptFileWithContext decrypts a file encrypted by EncryptFileWithContext
+// with the same associated data.
+func DecryptFileWithContext(sourceFilePath string, targetFilePath string, key []byte, ad []byte) error {
+	source, err := os.Open(sourceFilePath)
 	if err != nil {
 		return err
 	}
+	defer source.Close()
 
-	// Write the decrypted data to the target file
-	err = os.WriteFile(targetFilePath, []byte(decryptedData), 0644)
-	if err != nil {
-		return err
+	return fsutil.WriteAtomic(targetFilePath, fsutil.DefaultFileMode, func(w io.Writer) error {
+		return DecryptStreamWithContext(w, source, key, ad)
+	})
+}
+
+// writeOutput atomically writes data to a file, appending it to the existing
+// contents if requested.
+func writeOutput(outputFilePath string, data []byte, append bool) error {
+	if append {
+		existing, err := os.ReadFile(outputFilePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		data = concat(existing, data)
 	}
 
-	return nil
+	return fsutil.WriteFileAtomic(outputFilePath, data, fsutil.DefaultFileMode)
+}
+
+// concat returns a new slice holding a followed by b.
+func concat(a, b []byte) []byte {
+	result := make([]byte, 0, len(a)+len(b))
+	result = append(result, a...)
+	return append(result, b...)
 }
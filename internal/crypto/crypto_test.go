@@ -65,8 +65,13 @@ func TestEncryptFromReader(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	// The stream format is used, not base64 text
+	if !strings.HasPrefix(encrypted, string(streamMagic)) {
+		t.Errorf("Expected a stream, got %q", encrypted[:8])
+	}
+
 	// Decrypt the encrypted data and compare with the original content
-	decrypted, err := Decrypt(encrypted, key)
+	decrypted, err := DecryptFromReader(strings.NewReader(encrypted), key)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -196,6 +201,49 @@ func TestEncryptDecryptFile(t *testing.T) {
 	}
 }
 
+func TestEncryptFileMode(t *testing.T) {
+	key := []byte("mysecretkey")
+	sourceFilePath := "testdata/source.txt"
+	encryptedFilePath := "testdata/encrypted_mode.txt"
+
+	defer func() {
+		_ = os.Remove(encryptedFilePath)
+	}()
+
+	err := EncryptFile(sourceFilePath, encryptedFilePath, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(encryptedFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected encrypted file mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// Existing permissions are preserved when the file is rewritten
+	if err := os.Chmod(encryptedFilePath, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	err = EncryptFile(sourceFilePath, encryptedFilePath, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err = os.Stat(encryptedFilePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected encrypted file mode 0640, got %v", info.Mode().Perm())
+	}
+}
+
 // Add more tests for other functions...
 func TestEncryptAndDecryptStdin(t *testing.T) {
 	key := []byte("mysecretkey")
@@ -0,0 +1,460 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"filippo.io/age"
+	"golang.org/x/crypto/hkdf"
+)
+
+// envelopeMagic identifies data sealed with a key-encryption key.
+var envelopeMagic = []byte("SECE")
+
+// ageMagic identifies a KEK encrypted to age recipients.
+var ageMagic = []byte("age-encryption.org/v1")
+
+// envelopeVersion is the current envelope format version, version 2 added
+// the counter and the associated data.
+const envelopeVersion byte = 2
+
+// envelopeVersionNoContext is the first envelope format version, it has no
+// counter and no associated data.
+const envelopeVersionNoContext byte = 1
+
+// Envelope implements envelope encryption. Values are sealed with random data
+// keys, the data keys are wrapped by a key-encryption key (KEK) and the KEK
+// is wrapped with the password. Changing the password only re-wraps the KEK
+// and rotating the KEK only re-wraps the data keys, sealed values are left
+// untouched.
+type Envelope struct {
+	kek  []byte
+	keys *KeyManager
+}
+
+// NewEnvelope creates an envelope with a random key-encryption key.
+func NewEnvelope() (*Envelope, error) {
+	kek := LockedBytes(keySize)
+	if _, err := io.ReadFull(rand.Reader, kek); err != nil {
+		Release(kek)
+		return nil, err
+	}
+	return &Envelope{kek: kek, keys: NewKeyManager()}, nil
+}
+
+// Close wipes and unlocks the KEK and the data keys, the envelope can't be
+// used afterwards. Closing it again does nothing.
+func (e *Envelope) Close() {
+	if e == nil || e.kek == nil {
+		return
+	}
+	Release(e.kek)
+	e.kek = nil
+	e.keys.Clear()
+}
+
+// Seal encrypts a value with a new data key identified by id, it returns the
+// wrapped data key and the sealed value.
+func (e *Envelope) Seal(id string, plainData []byte) ([]byte, []byte, error) {
+	dataKey, err := e.keys.GenerateKey(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The data key is only kept wrapped
+	defer e.keys.DeleteKey(id)
+
+	sealedData, err := sealWithKey(plainData, dataKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrappedKey, err := sealWithKey(dataKey, e.kek)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return wrappedKey, sealedData, nil
+}
+
+// Open decrypts a value sealed by Seal.
+func (e *Envelope) Open(wrappedKey, sealedData []byte) ([]byte, error) {
+	dataKey, err := openWithKey(wrappedKey, e.kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer Wipe(dataKey)
+	return openWithKey(sealedData, dataKey)
+}
+
+// Rewrap wraps a data key of this envelope with the KEK of another one.
+func (e *Envelope) Rewrap(wrappedKey []byte, to *Envelope) ([]byte, error) {
+	dataKey, err := openWithKey(wrappedKey, e.kek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer Wipe(dataKey)
+	return sealWithKey(dataKey, to.kek)
+}
+
+// Encrypt seals the input with a key derived from the KEK and returns the
+// base64 encoded result, prefixed with the KEK wrapped with the password.
+// The layout is magic | version | counter | length | wrapped KEK | nonce |
+// cipherText where everything before the nonce is authenticated.
+func (e *Envelope) Encrypt(input string, password []byte, params KDFParams) ([]byte, error) {
+	return e.EncryptWithContext(input, password, params, nil, 0)
+}
+
+// EncryptWithContext seals the input like Encrypt, binding it to the
+// associated data and storing the counter in the header. Writers increase
+// the counter so an older copy can be told apart.
+func (e *Envelope) EncryptWithContext(input string, password []byte, params KDFParams, ad []byte, counter uint64) ([]byte, error) {
+	wrappedKEK, err := e.WrapKEK(password, params)
+	if err != nil {
+		return nil, err
+	}
+	return e.encrypt(input, wrappedKEK, ad, counter)
+}
+
+// EncryptForRecipients seals the input like Encrypt, with the KEK encrypted
+// to a list of age recipients instead of a password.
+func (e *Envelope) EncryptForRecipients(input string, recipients []age.Recipient) ([]byte, error) {
+	return e.EncryptForRecipientsWithContext(input, recipients, nil, 0)
+}
+
+// EncryptForRecipientsWithContext seals the input like EncryptForRecipients,
+// binding it to the associated data and storing the counter in the header.
+func (e *Envelope) EncryptForRecipientsWithContext(input string, recipients []age.Recipient, ad []byte, counter uint64) ([]byte, error) {
+	wrappedKEK, err := e.WrapKEKForRecipients(recipients)
+	if err != nil {
+		return nil, err
+	}
+	return e.encrypt(input, wrappedKEK, ad, counter)
+}
+
+// WrapKEK returns the KEK sealed with a key derived from the password.
+func (e *Envelope) WrapKEK(password []byte, params KDFParams) ([]byte, error) {
+	return seal(e.kek, password, params)
+}
+
+// WrapKEKForRecipients returns the KEK encrypted to a list of age recipients.
+func (e *Envelope) WrapKEKForRecipients(recipients []age.Recipient) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	var wrappedKEK bytes.Buffer
+	w, err := age.Encrypt(&wrappedKEK, recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(e.kek); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return wrappedKEK.Bytes(), nil
+}
+
+// OpenEnvelope returns the envelope of a KEK wrapped by WrapKEK, or by
+// WrapKEKForRecipients if identities are given.
+func OpenEnvelope(wrappedKEK []byte, password []byte, identities ...age.Identity) (*Envelope, error) {
+	kek, err := unwrapKEK(wrappedKEK, password, identities)
+	if err != nil {
+		return nil, err
+	}
+	return &Envelope{kek: lockedCopy(kek), keys: NewKeyManager()}, nil
+}
+
+// SealRecord encrypts a record with a key derived from the KEK, the record
+// is bound to its id so records can't be swapped.
+func (e *Envelope) SealRecord(id string, plainData []byte) ([]byte, error) {
+	contentKey, err := e.contentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aesGCM, err := newGCM(contentKey)
+	Wipe(contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aesGCM.Seal(nonce, nonce, plainData, []byte(id)), nil
+}
+
+// OpenRecord decrypts a record sealed by SealRecord with the same id.
+func (e *Envelope) OpenRecord(id string, sealedData []byte) ([]byte, error) {
+	if len(sealedData) < nonceSize {
+		return nil, errors.New("record too short")
+	}
+
+	contentKey, err := e.contentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aesGCM, err := newGCM(contentKey)
+	Wipe(contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesGCM.Open(nil, sealedData[:nonceSize], sealedData[nonceSize:], []byte(id))
+}
+
+// MAC returns the HMAC-SHA256 of data with a key derived from the KEK.
+func (e *Envelope) MAC(data []byte) ([]byte, error) {
+	macKey := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.kek, nil, []byte("sectool envelope mac")), macKey); err != nil {
+		return nil, err
+	}
+	defer Wipe(macKey)
+
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// encrypt seals the input, prefixed with the counter and the wrapped KEK,
+// authenticating the header and the associated data.
+func (e *Envelope) encrypt(input string, wrappedKEK []byte, ad []byte, counter uint64) ([]byte, error) {
+	header := make([]byte, 0, len(envelopeMagic)+13+len(wrappedKEK))
+	header = append(header, envelopeMagic...)
+	header = append(header, envelopeVersion)
+	header = binary.BigEndian.AppendUint64(header, counter)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(wrappedKEK)))
+	header = append(header, wrappedKEK...)
+
+	contentKey, err := e.contentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	aesGCM, err := newGCM(contentKey)
+	Wipe(contentKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	encryptedData := append(header, nonce...)
+	encryptedData = aesGCM.Seal(encryptedData, nonce, []byte(input), concat(header, ad))
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(encryptedData)))
+	base64.StdEncoding.Encode(encoded, encryptedData)
+	return encoded, nil
+}
+
+// contentKey derives the key sealing the envelope contents from the KEK, so
+// the KEK itself is only used to wrap data keys.
+func (e *Envelope) contentKey() ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, e.kek, nil, []byte("sectool envelope content")), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// DecryptEnvelope decrypts data produced by Envelope.Encrypt, or by
+// Envelope.EncryptForRecipients if identities are given, returning the
+// contents and the envelope. Data encrypted directly with the password is
+// accepted as well, the returned envelope is nil in that case.
+func DecryptEnvelope(encryptedBase64 string, password []byte, identities ...age.Identity) (string, *Envelope, error) {
+	contents, envelope, _, err := DecryptEnvelopeWithContext(encryptedBase64, password, nil, identities...)
+	return contents, envelope, err
+}
+
+// DecryptEnvelopeWithContext decrypts data like DecryptEnvelope, it must have
+// been encrypted with the same associated data. It also returns the counter
+// stored in the header. Data written before the counter was added has none
+// and is not bound to associated data, it fails with ErrNoContext unless ad
+// is empty.
+func DecryptEnvelopeWithContext(encryptedBase64 string, password []byte, ad []byte, identities ...age.Identity) (string, *Envelope, uint64, error) {
+	if encryptedBase64 == "" {
+		return "", nil, 0, nil
+	}
+
+	encryptedData, err := base64.StdEncoding.DecodeString(encryptedBase64)
+	if err != nil {
+		return "", nil, 0, err
+	}
+
+	if !bytes.HasPrefix(encryptedData, envelopeMagic) {
+		plainText, err := Decrypt(encryptedBase64, password)
+		if err == nil && len(ad) > 0 {
+			return "", nil, 0, ErrNoContext
+		}
+		return plainText, nil, 0, err
+	}
+
+	plainText, envelope, counter, err := openEnvelope(encryptedData, password, ad, identities)
+	if err != nil {
+		// A legacy nonce may start with the envelope magic by chance
+		if legacyText, legacyErr := Decrypt(encryptedBase64, password); legacyErr == nil {
+			if len(ad) > 0 {
+				return "", nil, 0, ErrNoContext
+			}
+			return legacyText, nil, 0, nil
+		}
+		return "", nil, 0, err
+	}
+
+	return string(plainText), envelope, counter, nil
+}
+
+// IsRecipientsEnvelope reports whether the data was produced by
+// Envelope.EncryptForRecipients.
+func IsRecipientsEnvelope(encryptedBase64 string) bool {
+	encryptedData, err := base64.StdEncoding.DecodeString(encryptedBase64)
+	if err != nil || !bytes.HasPrefix(encryptedData, envelopeMagic) {
+		return false
+	}
+	fixed, _, _, err := parseEnvelopeHeader(encryptedData)
+	return err == nil && bytes.HasPrefix(encryptedData[fixed:], ageMagic)
+}
+
+// parseEnvelopeHeader returns the size of the fixed part of the header, the
+// counter and the length of the wrapped KEK following it.
+func parseEnvelopeHeader(encryptedData []byte) (int, uint64, int, error) {
+	if len(encryptedData) <= len(envelopeMagic) {
+		return 0, 0, 0, errors.New("invalid envelope header")
+	}
+
+	var counter uint64
+	fixed := len(envelopeMagic) + 1
+	switch version := encryptedData[len(envelopeMagic)]; version {
+	case envelopeVersionNoContext:
+	case envelopeVersion:
+		if len(encryptedData) < fixed+8 {
+			return 0, 0, 0, errors.New("invalid envelope header")
+		}
+		counter = binary.BigEndian.Uint64(encryptedData[fixed:])
+		fixed += 8
+	default:
+		return 0, 0, 0, fmt.Errorf("unsupported envelope version %d", version)
+	}
+
+	if len(encryptedData) < fixed+4 {
+		return 0, 0, 0, errors.New("invalid envelope header")
+	}
+	length := int(binary.BigEndian.Uint32(encryptedData[fixed:]))
+	return fixed + 4, counter, length, nil
+}
+
+// openEnvelope unwraps the KEK and decrypts the envelope contents, the first
+// version is not bound to associated data and fails with ErrNoContext if ad
+// is given.
+func openEnvelope(encryptedData []byte, password []byte, ad []byte, identities []age.Identity) ([]byte, *Envelope, uint64, error) {
+	fixed, counter, length, err := parseEnvelopeHeader(encryptedData)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	if len(encryptedData) < fixed+length+nonceSize {
+		return nil, nil, 0, errors.New("invalid envelope header")
+	}
+	if encryptedData[len(envelopeMagic)] == envelopeVersionNoContext && len(ad) > 0 {
+		return nil, nil, 0, ErrNoContext
+	}
+
+	e, err := OpenEnvelope(encryptedData[fixed:fixed+length], password, identities...)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	contentKey, err := e.contentKey()
+	if err != nil {
+		e.Close()
+		return nil, nil, 0, err
+	}
+
+	aesGCM, err := newGCM(contentKey)
+	Wipe(contentKey)
+	if err != nil {
+		e.Close()
+		return nil, nil, 0, err
+	}
+
+	n := fixed + length
+	nonce := encryptedData[n : n+nonceSize]
+	plainData, err := aesGCM.Open(nil, nonce, encryptedData[n+nonceSize:], concat(encryptedData[:n], ad))
+	if err != nil {
+		e.Close()
+		return nil, nil, 0, err
+	}
+
+	return plainData, e, counter, nil
+}
+
+// unwrapKEK decrypts the KEK with the identities if it was encrypted to age
+// recipients, or with the password otherwise.
+func unwrapKEK(wrappedKEK []byte, password []byte, identities []age.Identity) ([]byte, error) {
+	if !bytes.HasPrefix(wrappedKEK, ageMagic) {
+		return open(wrappedKEK, password)
+	}
+
+	if len(identities) == 0 {
+		return nil, errors.New("vault is encrypted to recipients, an identity is required")
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(wrappedKEK), identities...)
+	if err != nil {
+		return nil, err
+	}
+
+	kek, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if len(kek) != keySize {
+		return nil, errors.New("invalid key-encryption key")
+	}
+	return kek, nil
+}
+
+// sealWithKey encrypts data with a random 256-bit key, the result is
+// nonce | cipherText.
+func sealWithKey(plainData []byte, key []byte) ([]byte, error) {
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, nonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	return aesGCM.Seal(nonce, nonce, plainData, nil), nil
+}
+
+// openWithKey decrypts data produced by sealWithKey.
+func openWithKey(encryptedData []byte, key []byte) ([]byte, error) {
+	if len(encryptedData) < nonceSize {
+		return nil, errors.New("ciphertext too short")
+	}
+
+	aesGCM, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return aesGCM.Open(nil, encryptedData[:nonceSize], encryptedData[nonceSize:], nil)
+}
@@ -0,0 +1,232 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestEnvelope_SealOpen(t *testing.T) {
+	envelope, err := NewEnvelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	wrappedKey, sealed, err := envelope.Seal("KEY", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bytes.Contains(sealed, []byte("secret")) {
+		t.Error("Expected the value to be sealed")
+	}
+
+	plainData, err := envelope.Open(wrappedKey, sealed)
+	if err != nil || string(plainData) != "secret" {
+		t.Errorf("Expected 'secret', got %q (%v)", plainData, err)
+	}
+
+	// Each value gets its own data key
+	otherKey, _, err := envelope.Seal("KEY", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := envelope.Open(otherKey, sealed); err == nil {
+		t.Error("Expected a different data key to fail")
+	}
+
+	other, err := NewEnvelope()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Open(wrappedKey, sealed); err == nil {
+		t.Error("Expected a different KEK to fail")
+	}
+}
+
+func TestEnvelope_Rewrap(t *testing.T) {
+	envelope, _ := NewEnvelope()
+	rotated, _ := NewEnvelope()
+
+	wrappedKey, sealed, err := envelope.Seal("KEY", []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewrapped, err := envelope.Rewrap(wrappedKey, rotated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sealed value is unchanged, only the data key is re-wrapped
+	plainData, err := rotated.Open(rewrapped, sealed)
+	if err != nil || string(plainData) != "secret" {
+		t.Errorf("Expected 'secret', got %q (%v)", plainData, err)
+	}
+}
+
+func TestEnvelope_Close(t *testing.T) {
+	password := []byte("mysecretkey")
+	envelope, _ := NewEnvelope()
+	wrappedKEK, err := envelope.WrapKEK(password, DefaultKDFParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opened, err := OpenEnvelope(wrappedKEK, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []*Envelope{envelope, opened} {
+		kek := e.kek[:cap(e.kek)]
+		e.keys.GenerateKey("KEY")
+
+		e.Close()
+		if !bytes.Equal(kek, make([]byte, len(kek))) {
+			t.Error("Expected the KEK to be wiped")
+		}
+		if e.kek != nil {
+			t.Error("Expected the KEK to be dropped")
+		}
+		if _, err := e.keys.GetKey("KEY"); err == nil {
+			t.Error("Expected the data keys to be cleared")
+		}
+
+		// Closing again does nothing
+		e.Close()
+	}
+}
+
+func TestEnvelope_EncryptDecrypt(t *testing.T) {
+	password := []byte("mysecretkey")
+	envelope, _ := NewEnvelope()
+	wrappedKey, sealed, _ := envelope.Seal("KEY", []byte("secret"))
+
+	encrypted, err := envelope.Encrypt("contents", password, DefaultKDFParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, opened, err := DecryptEnvelope(string(encrypted), password)
+	if err != nil || contents != "contents" {
+		t.Fatalf("Expected 'contents', got %q (%v)", contents, err)
+	}
+
+	if plainData, err := opened.Open(wrappedKey, sealed); err != nil || string(plainData) != "secret" {
+		t.Errorf("Expected the KEK to be recovered, got %q (%v)", plainData, err)
+	}
+
+	if _, _, err := DecryptEnvelope(string(encrypted), []byte("wrongkey")); err == nil {
+		t.Error("Expected error for a wrong password")
+	}
+
+	// Data encrypted with the password is still accepted
+	legacy, err := Encrypt("legacy", password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	contents, opened, err = DecryptEnvelope(legacy, password)
+	if err != nil || contents != "legacy" || opened != nil {
+		t.Errorf("Expected legacy contents without an envelope, got %q, %v (%v)", contents, opened, err)
+	}
+
+	if contents, opened, err := DecryptEnvelope("", password); err != nil || contents != "" || opened != nil {
+		t.Errorf("Expected empty contents, got %q, %v (%v)", contents, opened, err)
+	}
+}
+
+func TestEnvelope_EncryptForRecipients(t *testing.T) {
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+	eve, _ := age.GenerateX25519Identity()
+
+	envelope, _ := NewEnvelope()
+	wrappedKey, sealed, _ := envelope.Seal("KEY", []byte("secret"))
+
+	encrypted, err := envelope.EncryptForRecipients("contents", []age.Recipient{alice.Recipient(), bob.Recipient()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsRecipientsEnvelope(string(encrypted)) {
+		t.Error("Expected the KEK to be encrypted to recipients")
+	}
+
+	// Every member opens the vault with their own identity
+	for _, identity := range []age.Identity{alice, bob} {
+		contents, opened, err := DecryptEnvelope(string(encrypted), nil, identity)
+		if err != nil || contents != "contents" {
+			t.Fatalf("Expected 'contents', got %q (%v)", contents, err)
+		}
+		if plainData, err := opened.Open(wrappedKey, sealed); err != nil || string(plainData) != "secret" {
+			t.Errorf("Expected the KEK to be recovered, got %q (%v)", plainData, err)
+		}
+	}
+
+	if _, _, err := DecryptEnvelope(string(encrypted), nil, eve); err == nil {
+		t.Error("Expected error for an identity that is not a recipient")
+	}
+
+	if _, _, err := DecryptEnvelope(string(encrypted), []byte("mysecretkey")); err == nil {
+		t.Error("Expected error without an identity")
+	}
+}
+
+func TestEnvelope_Context(t *testing.T) {
+	password := []byte("mysecretkey")
+	envelope, _ := NewEnvelope()
+	ad := AssociatedData("vault", "repository.vault")
+
+	encrypted, err := envelope.EncryptWithContext("contents", password, DefaultKDFParams, ad, 42)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, _, counter, err := DecryptEnvelopeWithContext(string(encrypted), password, ad)
+	if err != nil || contents != "contents" || counter != 42 {
+		t.Fatalf("Expected 'contents' and counter 42, got %q, %d (%v)", contents, counter, err)
+	}
+
+	for _, other := range [][]byte{nil, AssociatedData("vault", "other.vault"), AssociatedData("vaultrepository.vault")} {
+		if _, _, _, err := DecryptEnvelopeWithContext(string(encrypted), password, other); err == nil {
+			t.Errorf("Expected error for associated data %q", other)
+		}
+	}
+
+	// The counter is authenticated
+	data, _ := base64.StdEncoding.DecodeString(string(encrypted))
+	data[len(envelopeMagic)+8]++
+	if _, _, _, err := DecryptEnvelopeWithContext(base64.StdEncoding.EncodeToString(data), password, ad); err == nil {
+		t.Error("Expected error for a modified counter")
+	}
+
+	// The first version has no counter and is not bound to associated data
+	e, _ := NewEnvelope()
+	wrappedKEK, _ := e.WrapKEK(password, DefaultKDFParams)
+	header := append(append([]byte{}, envelopeMagic...), envelopeVersionNoContext)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(wrappedKEK)))
+	header = append(header, wrappedKEK...)
+	contentKey, _ := e.contentKey()
+	aesGCM, _ := newGCM(contentKey)
+	nonce := make([]byte, nonceSize)
+	legacy := aesGCM.Seal(append(header, nonce...), nonce, []byte("legacy"), header)
+
+	if _, _, _, err := DecryptEnvelopeWithContext(base64.StdEncoding.EncodeToString(legacy), password, ad); !errors.Is(err, ErrNoContext) {
+		t.Errorf("Expected ErrNoContext for the first version, got %v", err)
+	}
+	contents, _, counter, err = DecryptEnvelopeWithContext(base64.StdEncoding.EncodeToString(legacy), password, nil)
+	if err != nil || contents != "legacy" || counter != 0 {
+		t.Errorf("Expected the first version to open without context, got %q, %d (%v)", contents, counter, err)
+	}
+
+	// Base64 ciphertexts are not bound to associated data either
+	encrypted64, _ := Encrypt("legacy", password)
+	if _, _, _, err := DecryptEnvelopeWithContext(encrypted64, password, ad); !errors.Is(err, ErrNoContext) {
+		t.Errorf("Expected ErrNoContext for a base64 ciphertext, got %v", err)
+	}
+}
@@ -0,0 +1,220 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDFAlgorithm identifies the password based key derivation function.
+type KDFAlgorithm byte
+
+const (
+	KDFArgon2id KDFAlgorithm = 1
+	KDFScrypt   KDFAlgorithm = 2
+)
+
+const (
+	saltSize  = 16
+	keySize   = 32
+	nonceSize = 12
+
+	// The parameters are read from the header before the ciphertext is
+	// authenticated, the caps bound what a crafted file can make us allocate.
+
+	// maxArgon2Memory caps the memory cost accepted from a header (in KiB).
+	maxArgon2Memory = 1024 * 1024
+	// maxArgon2Time caps the number of passes accepted from a header.
+	maxArgon2Time = 64
+	// maxScryptLogN caps the scrypt cost accepted from a header.
+	maxScryptLogN = 24
+	// maxScryptMemory caps the memory used by scrypt (128*r*N bytes).
+	maxScryptMemory = 1024 * 1024 * 1024
+	// maxScryptP caps the scrypt parallelization accepted from a header.
+	maxScryptP = 16
+)
+
+// headerMagic identifies ciphertexts carrying a KDF header.
+var headerMagic = []byte("SECT")
+
+// headerVersion is the current ciphertext header version.
+const headerVersion byte = 1
+
+// KDFParams holds the tunable parameters of the key derivation function.
+type KDFParams struct {
+	Algorithm KDFAlgorithm
+	// Time is the number of Argon2id passes.
+	Time uint32
+	// Memory is the Argon2id memory cost in KiB.
+	Memory uint32
+	// Threads is the Argon2id degree of parallelism.
+	Threads uint8
+	// LogN is the scrypt CPU/memory cost as a power of two.
+	LogN uint8
+	// R is the scrypt block size.
+	R uint32
+	// P is the scrypt parallelization parameter.
+	P uint32
+}
+
+// DefaultKDFParams are the parameters used when none are specified, they
+// follow the second recommended option of RFC 9106.
+var DefaultKDFParams = KDFParams{
+	Algorithm: KDFArgon2id,
+	Time:      3,
+	Memory:    64 * 1024,
+	Threads:   4,
+}
+
+// DefaultScryptParams are the recommended parameters for scrypt.
+var DefaultScryptParams = KDFParams{
+	Algorithm: KDFScrypt,
+	LogN:      15,
+	R:         8,
+	P:         1,
+}
+
+// Validate checks the parameters are usable and within safe bounds.
+func (p KDFParams) Validate() error {
+	switch p.Algorithm {
+	case KDFArgon2id:
+		if p.Time == 0 || p.Time > maxArgon2Time {
+			return fmt.Errorf("invalid argon2id time cost: %d", p.Time)
+		}
+		if p.Memory < 8*uint32(p.Threads) || p.Memory > maxArgon2Memory {
+			return fmt.Errorf("invalid argon2id memory cost: %d", p.Memory)
+		}
+		if p.Threads == 0 {
+			return errors.New("invalid argon2id parallelism: 0")
+		}
+	case KDFScrypt:
+		if p.LogN < 1 || p.LogN > maxScryptLogN {
+			return fmt.Errorf("invalid scrypt cost: %d", p.LogN)
+		}
+		if p.R == 0 || p.P == 0 || p.P > maxScryptP || 128*uint64(p.R)<<p.LogN > maxScryptMemory {
+			return fmt.Errorf("invalid scrypt parameters: r=%d p=%d", p.R, p.P)
+		}
+	default:
+		return fmt.Errorf("unsupported kdf algorithm: %d", p.Algorithm)
+	}
+	return nil
+}
+
+// deriveKey derives an AES-256 key from a password and salt.
+func deriveKey(password, salt []byte, p KDFParams) ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	switch p.Algorithm {
+	case KDFArgon2id:
+		return argon2.IDKey(password, salt, p.Time, p.Memory, p.Threads, keySize), nil
+	case KDFScrypt:
+		return scrypt.Key(password, salt, 1<<p.LogN, int(p.R), int(p.P), keySize)
+	default:
+		return nil, fmt.Errorf("unsupported kdf algorithm: %d", p.Algorithm)
+	}
+}
+
+// legacyKey derives the key used by headerless ciphertexts.
+func legacyKey(key []byte) []byte {
+	hash := sha256.Sum256(key)
+	return hash[:]
+}
+
+// kdfHeader is the plaintext header prepended to password based ciphertexts.
+type kdfHeader struct {
+	params KDFParams
+	salt   []byte
+}
+
+// newKDFHeader creates a header with a fresh random salt.
+func newKDFHeader(p KDFParams) (*kdfHeader, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	return &kdfHeader{params: p, salt: salt}, nil
+}
+
+// marshal encodes the header as:
+// magic(4) | version(1) | algorithm(1) | p1(4) | p2(4) | p3(4) | saltLen(1) | salt
+func (h *kdfHeader) marshal() []byte {
+	var p1, p2, p3 uint32
+	switch h.params.Algorithm {
+	case KDFArgon2id:
+		p1, p2, p3 = h.params.Time, h.params.Memory, uint32(h.params.Threads)
+	case KDFScrypt:
+		p1, p2, p3 = uint32(h.params.LogN), h.params.R, h.params.P
+	}
+
+	buf := make([]byte, 0, len(headerMagic)+15+len(h.salt))
+	buf = append(buf, headerMagic...)
+	buf = append(buf, headerVersion, byte(h.params.Algorithm))
+	buf = binary.BigEndian.AppendUint32(buf, p1)
+	buf = binary.BigEndian.AppendUint32(buf, p2)
+	buf = binary.BigEndian.AppendUint32(buf, p3)
+	buf = append(buf, byte(len(h.salt)))
+	buf = append(buf, h.salt...)
+	return buf
+}
+
+// hasKDFHeader reports whether data starts with the header magic.
+func hasKDFHeader(data []byte) bool {
+	return len(data) >= len(headerMagic) && string(data[:len(headerMagic)]) == string(headerMagic)
+}
+
+// parseKDFHeader decodes a header, returning it and the number of bytes consumed.
+func parseKDFHeader(data []byte) (*kdfHeader, int, error) {
+	const fixed = 4 + 1 + 1 + 12 + 1
+	if !hasKDFHeader(data) || len(data) < fixed {
+		return nil, 0, errors.New("invalid ciphertext header")
+	}
+
+	if data[4] != headerVersion {
+		return nil, 0, fmt.Errorf("unsupported ciphertext header version %d", data[4])
+	}
+
+	algorithm := KDFAlgorithm(data[5])
+	p1 := binary.BigEndian.Uint32(data[6:10])
+	p2 := binary.BigEndian.Uint32(data[10:14])
+	p3 := binary.BigEndian.Uint32(data[14:18])
+	saltLen := int(data[18])
+	if saltLen == 0 || len(data) < fixed+saltLen {
+		return nil, 0, errors.New("invalid ciphertext header")
+	}
+
+	params := KDFParams{Algorithm: algorithm}
+	switch algorithm {
+	case KDFArgon2id:
+		if p3 > 255 {
+			return nil, 0, errors.New("invalid argon2id parallelism")
+		}
+		params.Time, params.Memory, params.Threads = p1, p2, uint8(p3)
+	case KDFScrypt:
+		if p1 > 255 {
+			return nil, 0, errors.New("invalid scrypt cost")
+		}
+		params.LogN, params.R, params.P = uint8(p1), p2, p3
+	}
+
+	if err := params.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	salt := make([]byte, saltLen)
+	copy(salt, data[fixed:fixed+saltLen])
+
+	return &kdfHeader{params: params, salt: salt}, fixed + saltLen, nil
+}
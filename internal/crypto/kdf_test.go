@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"encoding/base64"
+	"testing"
+)
+
+func TestEncryptWithParams_Scrypt(t *testing.T) {
+	key := []byte("mysecretkey")
+	input := "Hello, this is a test message!"
+
+	encrypted, err := EncryptWithParams(input, key, DefaultScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(encrypted, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != input {
+		t.Errorf("Expected decrypted data to be '%s', but got '%s'", input, decrypted)
+	}
+}
+
+func TestEncrypt_UsesRandomSalt(t *testing.T) {
+	key := []byte("mysecretkey")
+	input := "Hello, this is a test message!"
+
+	first, err := Encrypt(input, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second, err := Encrypt(input, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	firstData, _ := base64.StdEncoding.DecodeString(first)
+	secondData, _ := base64.StdEncoding.DecodeString(second)
+
+	firstHeader, _, err := parseKDFHeader(firstData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	secondHeader, _, err := parseKDFHeader(secondData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(firstHeader.salt) == string(secondHeader.salt) {
+		t.Error("Expected a different salt for each ciphertext")
+	}
+
+	if firstHeader.params != DefaultKDFParams {
+		t.Errorf("Expected default parameters in header, got %+v", firstHeader.params)
+	}
+}
+
+func TestDecrypt_Legacy(t *testing.T) {
+	key := []byte("mysecretkey")
+	input := "Hello, this is a test message!"
+
+	// Headerless ciphertexts were produced with an unsalted SHA-256 key
+	encryptedData, err := sealLegacy([]byte(input), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decrypted, err := Decrypt(base64.StdEncoding.EncodeToString(encryptedData), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if decrypted != input {
+		t.Errorf("Expected decrypted data to be '%s', but got '%s'", input, decrypted)
+	}
+}
+
+func TestDecrypt_TamperedHeader(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	encrypted, err := Encrypt("Hello, this is a test message!", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encryptedData, _ := base64.StdEncoding.DecodeString(encrypted)
+	// Flip a bit of the salt, the header is authenticated
+	encryptedData[len(headerMagic)+15] ^= 0x01
+
+	_, err = Decrypt(base64.StdEncoding.EncodeToString(encryptedData), key)
+	if err == nil {
+		t.Error("Expected error for tampered header")
+	}
+}
+
+func TestDecrypt_WrongKey(t *testing.T) {
+	encrypted, err := Encrypt("Hello, this is a test message!", []byte("mysecretkey"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = Decrypt(encrypted, []byte("wrongkey"))
+	if err == nil {
+		t.Error("Expected error for wrong key")
+	}
+}
+
+func TestKDFParams_Validate(t *testing.T) {
+	invalid := []KDFParams{
+		{Algorithm: KDFArgon2id, Time: 0, Memory: 64 * 1024, Threads: 4},
+		{Algorithm: KDFArgon2id, Time: 1, Memory: maxArgon2Memory + 1, Threads: 4},
+		{Algorithm: KDFScrypt, LogN: maxScryptLogN + 1, R: 8, P: 1},
+		{Algorithm: KDFScrypt, LogN: 21, R: 8, P: 1},
+		{Algorithm: KDFScrypt, LogN: 15, R: 8, P: maxScryptP + 1},
+		{Algorithm: 0},
+	}
+
+	for _, params := range invalid {
+		if err := params.Validate(); err == nil {
+			t.Errorf("Expected error for parameters %+v", params)
+		}
+	}
+
+	if err := DefaultKDFParams.Validate(); err != nil {
+		t.Errorf("Expected default parameters to be valid, got %v", err)
+	}
+	if err := DefaultScryptParams.Validate(); err != nil {
+		t.Errorf("Expected default scrypt parameters to be valid, got %v", err)
+	}
+}
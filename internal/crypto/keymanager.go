@@ -6,7 +6,8 @@ import (
 	"sync"
 )
 
-// KeyManager stores encryption keys externally
+// KeyManager stores encryption keys externally, in locked memory wiped when
+// a key is deleted
 type KeyManager struct {
 	keys map[string][]byte
 	mu   sync.RWMutex
@@ -19,19 +20,24 @@ func NewKeyManager() *KeyManager {
 
 // GenerateKey creates a new AES-256 key
 func (km *KeyManager) GenerateKey(id string) ([]byte, error) {
-	key := make([]byte, 32) // AES-256 key
+	key := LockedBytes(32) // AES-256 key
 	_, err := rand.Read(key)
 	if err != nil {
+		Release(key)
 		return nil, err
 	}
 
 	km.mu.Lock()
+	if previous, exists := km.keys[id]; exists {
+		Release(previous)
+	}
 	km.keys[id] = key
 	km.mu.Unlock()
 	return key, nil
 }
 
-// GetKey retrieves a key from the key manager
+// GetKey retrieves a key from the key manager, it is wiped once deleted so
+// it must not be kept
 func (km *KeyManager) GetKey(id string) ([]byte, error) {
 	km.mu.RLock()
 	defer km.mu.RUnlock()
@@ -43,9 +49,22 @@ func (km *KeyManager) GetKey(id string) ([]byte, error) {
 	return key, nil
 }
 
-// DeleteKey removes a key from the key manager
+// DeleteKey wipes and removes a key from the key manager
 func (km *KeyManager) DeleteKey(id string) {
 	km.mu.Lock()
-	delete(km.keys, id)
+	if key, exists := km.keys[id]; exists {
+		Release(key)
+		delete(km.keys, id)
+	}
+	km.mu.Unlock()
+}
+
+// Clear wipes and removes all keys from the key manager
+func (km *KeyManager) Clear() {
+	km.mu.Lock()
+	for _, key := range km.keys {
+		Release(key)
+	}
+	km.keys = make(map[string][]byte)
 	km.mu.Unlock()
 }
@@ -1,6 +1,7 @@
 package crypto
 
 import (
+	"bytes"
 	"testing"
 )
 
@@ -51,3 +52,29 @@ func TestKeyManager_DeleteKey(t *testing.T) {
 		t.Errorf("expected error for deleted key, got nil")
 	}
 }
+
+func TestKeyManager_DeleteKeyWipes(t *testing.T) {
+	km := NewKeyManager()
+
+	key, err := km.GenerateKey("test-key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+	other, err := km.GenerateKey("other-key")
+	if err != nil {
+		t.Fatalf("expected no error, got %v", err)
+	}
+
+	km.DeleteKey("test-key")
+	if !bytes.Equal(key, make([]byte, len(key))) {
+		t.Errorf("expected deleted key to be wiped")
+	}
+
+	km.Clear()
+	if !bytes.Equal(other, make([]byte, len(other))) {
+		t.Errorf("expected cleared key to be wiped")
+	}
+	if _, err := km.GetKey("other-key"); err == nil {
+		t.Errorf("expected error for cleared key, got nil")
+	}
+}
@@ -5,9 +5,12 @@ import (
 	"sync"
 )
 
-// SecureKVStore is an in-memory encrypted key-value store
+// SecureKVStore is an in-memory encrypted key-value store, the values are
+// sealed with keys held in locked memory and wiped when removed. Only the
+// sealed values are protected, copies made by the callers are not: Go strings
+// can't be wiped and stay in memory until they are garbage collected.
 type SecureKVStore struct {
-	store      map[string]string
+	store      map[string][]byte
 	keyManager *KeyManager
 	mu         sync.RWMutex
 }
@@ -15,13 +18,22 @@ type SecureKVStore struct {
 // NewSecureKVStore initializes a new secure in-memory store
 func NewSecureKVStore(km *KeyManager) *SecureKVStore {
 	return &SecureKVStore{
-		store:      make(map[string]string),
+		store:      make(map[string][]byte),
 		keyManager: km,
 	}
 }
 
-// Put securely stores an encrypted key-value pair in memory
+// Put securely stores an encrypted key-value pair in memory, the string value
+// itself can't be wiped, use PutBytes where the value is held as bytes
 func (s *SecureKVStore) Put(key, value string) error {
+	plainData := []byte(value)
+	defer Wipe(plainData)
+	return s.PutBytes(key, plainData)
+}
+
+// PutBytes securely stores an encrypted key-value pair in memory, the caller
+// may wipe the value afterwards
+func (s *SecureKVStore) PutBytes(key string, value []byte) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -30,7 +42,7 @@ func (s *SecureKVStore) Put(key, value string) error {
 		return err
 	}
 
-	encryptedValue, err := Encrypt(value, encryptionKey)
+	encryptedValue, err := sealWithKey(value, encryptionKey)
 	if err != nil {
 		return err
 	}
@@ -39,36 +51,64 @@ func (s *SecureKVStore) Put(key, value string) error {
 	return nil
 }
 
-// Get retrieves and decrypts a value from memory
+// Get retrieves and decrypts a value from memory, the returned string can't be
+// wiped, use GetBytes where the value can be used as bytes
 func (s *SecureKVStore) Get(key string) (string, error) {
+	value, err := s.GetBytes(key)
+	if err != nil {
+		return "", err
+	}
+	defer Wipe(value)
+	return string(value), nil
+}
+
+// GetBytes retrieves and decrypts a value from memory, the caller should wipe
+// it once done
+func (s *SecureKVStore) GetBytes(key string) ([]byte, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	encryptionKey, err := s.keyManager.GetKey(key)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
 	encryptedValue, exists := s.store[key]
 	if !exists {
-		return "", errors.New("key not found")
+		return nil, errors.New("key not found")
 	}
 
-	return Decrypt(encryptedValue, encryptionKey)
+	return openWithKey(encryptedValue, encryptionKey)
 }
 
-// Delete removes a key from the store
+// Has checks if a key is present in the store
+func (s *SecureKVStore) Has(key string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, exists := s.store[key]
+	return exists
+}
+
+// Delete removes a key from the store, wiping its encryption key
 func (s *SecureKVStore) Delete(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	delete(s.store, key)
+	if encryptedValue, exists := s.store[key]; exists {
+		Wipe(encryptedValue)
+		delete(s.store, key)
+	}
+	s.keyManager.DeleteKey(key)
 }
 
-// Clear removes all values from the store
+// Clear removes all values from the store, wiping their encryption keys
 func (s *SecureKVStore) Clear() {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.store = make(map[string]string)
+	for key, encryptedValue := range s.store {
+		Wipe(encryptedValue)
+		s.keyManager.DeleteKey(key)
+	}
+	s.store = make(map[string][]byte)
 }
 
 func (s *SecureKVStore) ListKeys() []string {
@@ -98,12 +138,14 @@ func (s *SecureKVStore) MatchValue(value string) bool {
 			continue
 		}
 
-		decryptedValue, err := Decrypt(encryptedValue, encryptionKey)
+		decryptedValue, err := openWithKey(encryptedValue, encryptionKey)
 		if err != nil {
 			continue
 		}
 
-		if decryptedValue == value {
+		match := string(decryptedValue) == value
+		Wipe(decryptedValue)
+		if match {
 			return true
 		}
 
@@ -76,3 +76,35 @@ func TestSecureKVStore_Clear(t *testing.T) {
 		t.Fatal("expected error for cleared key, got nil")
 	}
 }
+
+func TestSecureKVStore_Bytes(t *testing.T) {
+	mk := NewKeyManager()
+	store := NewSecureKVStore(mk)
+
+	if err := store.PutBytes("key1", []byte("value1")); err != nil {
+		t.Fatalf("failed to put value: %v", err)
+	}
+
+	value, err := store.GetBytes("key1")
+	if err != nil {
+		t.Fatalf("failed to get value: %v", err)
+	}
+	if string(value) != "value1" {
+		t.Errorf("expected value1, got %s", value)
+	}
+
+	// Deleting a value wipes its encryption key
+	key, err := mk.GetKey("key1")
+	if err != nil {
+		t.Fatalf("failed to get encryption key: %v", err)
+	}
+	store.Delete("key1")
+	if _, err := mk.GetKey("key1"); err == nil {
+		t.Error("expected the encryption key to be removed")
+	}
+	for _, b := range key {
+		if b != 0 {
+			t.Fatal("expected the encryption key to be wiped")
+		}
+	}
+}
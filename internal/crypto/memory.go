@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"os"
+	"runtime"
+	"unsafe"
+)
+
+// Wipe overwrites a secret with zeros.
+func Wipe(b []byte) {
+	clear(b)
+	runtime.KeepAlive(b)
+}
+
+// LockedBytes allocates a buffer for a secret that is kept out of swap and
+// of core dumps where the platform allows it, it must be given back with
+// Release. The buffer occupies whole pages of its own so locking it doesn't
+// affect other memory. Locking is best effort, it is skipped when over the
+// limit of locked memory.
+func LockedBytes(size int) []byte {
+	pageSize := os.Getpagesize()
+	pages := (size + pageSize - 1) / pageSize
+	if pages == 0 {
+		pages = 1
+	}
+
+	buf := make([]byte, (pages+1)*pageSize)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&buf[0])) % uintptr(pageSize)); rem != 0 {
+		offset = pageSize - rem
+	}
+
+	locked := buf[offset : offset+pages*pageSize]
+	lockMemory(locked)
+	return locked[:size:len(locked)]
+}
+
+// Release wipes and unlocks a buffer allocated by LockedBytes.
+func Release(b []byte) {
+	locked := b[:cap(b)]
+	Wipe(locked)
+	unlockMemory(locked)
+}
+
+// lockedCopy moves a secret to a buffer allocated by LockedBytes and wipes
+// the original.
+func lockedCopy(b []byte) []byte {
+	locked := LockedBytes(len(b))
+	copy(locked, b)
+	Wipe(b)
+	return locked
+}
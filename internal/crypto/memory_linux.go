@@ -0,0 +1,24 @@
+//go:build linux
+
+package crypto
+
+import "golang.org/x/sys/unix"
+
+// lockMemory keeps the pages out of swap and of core dumps.
+func lockMemory(b []byte) {
+	_ = unix.Mlock(b)
+	_ = unix.Madvise(b, unix.MADV_DONTDUMP)
+}
+
+// unlockMemory reverts lockMemory, the pages go back to the Go heap.
+func unlockMemory(b []byte) {
+	_ = unix.Madvise(b, unix.MADV_DODUMP)
+	_ = unix.Munlock(b)
+}
+
+// DisableDumps marks the process as not dumpable: it doesn't dump core and
+// other processes of the user can't attach to it or read its memory. It is
+// used by the commands holding secrets for a while.
+func DisableDumps() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package crypto
+
+// lockMemory does nothing, secrets are only wiped on this platform.
+func lockMemory(b []byte) {}
+
+// unlockMemory does nothing, secrets are only wiped on this platform.
+func unlockMemory(b []byte) {}
+
+// DisableDumps is not supported on this platform.
+func DisableDumps() error {
+	return nil
+}
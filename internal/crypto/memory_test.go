@@ -0,0 +1,34 @@
+package crypto
+
+import (
+	"os"
+	"testing"
+	"unsafe"
+)
+
+func TestLockedBytes(t *testing.T) {
+	pageSize := os.Getpagesize()
+
+	for _, size := range []int{0, 32, pageSize, pageSize + 1} {
+		b := LockedBytes(size)
+		if len(b) != size {
+			t.Errorf("expected length %d, got %d", size, len(b))
+		}
+		if cap(b)%pageSize != 0 {
+			t.Errorf("expected whole pages, got capacity %d", cap(b))
+		}
+		if addr := uintptr(unsafe.Pointer(unsafe.SliceData(b))); addr%uintptr(pageSize) != 0 {
+			t.Errorf("expected a page aligned buffer for size %d", size)
+		}
+
+		for i := range b {
+			b[i] = 0xff
+		}
+		Release(b)
+		for _, v := range b[:cap(b)] {
+			if v != 0 {
+				t.Fatalf("expected the buffer to be wiped for size %d", size)
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package crypto
+
+import (
+	"bufio"
+	"io"
+	"os"
+
+	"github.com/a13labs/sectool/internal/fsutil"
+)
+
+// EncryptPath encrypts a file or a directory to a stream written to target.
+// The plain text is a tar archive so the file modes are restored on decrypt.
+func EncryptPath(sourcePath string, targetFilePath string, key []byte) error {
+	if _, err := os.Stat(sourcePath); err != nil {
+		return err
+	}
+
+	return fsutil.WriteAtomic(targetFilePath, fsutil.DefaultFileMode, func(w io.Writer) error {
+		encrypted, err := NewEncryptWriter(w, key, DefaultKDFParams)
+		if err != nil {
+			return err
+		}
+
+		if err := fsutil.WriteTar(encrypted, sourcePath); err != nil {
+			return err
+		}
+		return encrypted.Close()
+	})
+}
+
+// DecryptPath decrypts a file produced by EncryptPath to target, a file or a
+// directory depending on what was encrypted. Data that is not an archive,
+// such as files produced by EncryptFile, is written as is.
+func DecryptPath(sourceFilePath string, targetPath string, key []byte) error {
+	source, err := os.Open(sourceFilePath)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+
+	decrypted, err := NewDecryptReader(source, key)
+	if err != nil {
+		return err
+	}
+
+	// A tar header is a 512 bytes block
+	r := bufio.NewReaderSize(decrypted, 4096)
+	header, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	if fsutil.IsTar(header) {
+		return fsutil.ReadTar(r, targetPath)
+	}
+
+	return fsutil.WriteAtomic(targetPath, fsutil.DefaultFileMode, func(w io.Writer) error {
+		_, err := io.Copy(w, r)
+		return err
+	})
+}
@@ -0,0 +1,54 @@
+package crypto
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEncryptDecryptPath(t *testing.T) {
+	key := []byte("mysecretkey")
+	dir := t.TempDir()
+
+	source := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(source, []byte("apiVersion: v1"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(source, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	encrypted := filepath.Join(dir, "kubeconfig.enc")
+	if err := EncryptPath(source, encrypted, key); err != nil {
+		t.Fatal(err)
+	}
+
+	restored := filepath.Join(dir, "restored")
+	if err := DecryptPath(encrypted, restored, key); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(restored)
+	if err != nil || string(data) != "apiVersion: v1" {
+		t.Errorf("Expected 'apiVersion: v1', got %q (%v)", data, err)
+	}
+	if info, _ := os.Stat(restored); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %v", info.Mode().Perm())
+	}
+
+	if err := DecryptPath(encrypted, filepath.Join(dir, "other"), []byte("wrongkey")); err == nil {
+		t.Error("Expected error for a wrong key")
+	}
+
+	// Files encrypted without an archive are restored as is
+	if err := EncryptFile(source, encrypted, key); err != nil {
+		t.Fatal(err)
+	}
+	plain := filepath.Join(dir, "plain")
+	if err := DecryptPath(encrypted, plain, key); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(plain); string(data) != "apiVersion: v1" {
+		t.Errorf("Expected 'apiVersion: v1', got %q", data)
+	}
+}
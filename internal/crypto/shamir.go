@@ -0,0 +1,201 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// sharePrefix identifies the text form of a share and its version.
+const sharePrefix = "SSS1"
+
+// shareEncoding encodes share values, upper case without padding so shares
+// are easy to read aloud and type.
+var shareEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Share is one Shamir share of a secret, any Threshold shares with the same
+// ID rebuild the secret.
+type Share struct {
+	ID        uint32
+	Threshold int
+	X         byte
+	Y         []byte
+}
+
+// SplitSecret splits a secret into n shares, threshold of which are needed
+// to rebuild it. The shares of a split carry the same random ID so shares of
+// different splits are not mixed.
+func SplitSecret(secret []byte, n, threshold int) ([]Share, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("secret is empty")
+	}
+	if threshold < 2 || threshold > n || n > 255 {
+		return nil, fmt.Errorf("invalid threshold %d of %d shares, need 2 <= threshold <= shares <= 255", threshold, n)
+	}
+
+	var id [4]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+
+	shares := make([]Share, n)
+	for i := range shares {
+		shares[i] = Share{
+			ID:        binary.BigEndian.Uint32(id[:]),
+			Threshold: threshold,
+			X:         byte(i + 1),
+			Y:         make([]byte, len(secret)),
+		}
+	}
+
+	// Each byte of the secret is the constant term of a random polynomial of
+	// degree threshold-1, the shares are points on the polynomials
+	coefficients := make([]byte, threshold)
+	for b, value := range secret {
+		if _, err := rand.Read(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		coefficients[0] = value
+
+		for i := range shares {
+			shares[i].Y[b] = gfEval(coefficients, shares[i].X)
+		}
+	}
+	clear(coefficients)
+
+	return shares, nil
+}
+
+// CombineShares rebuilds a secret from at least threshold shares of the same
+// split.
+func CombineShares(shares []Share) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares given")
+	}
+
+	first := shares[0]
+	seen := map[byte]bool{}
+	for _, share := range shares {
+		if share.ID != first.ID || share.Threshold != first.Threshold || len(share.Y) != len(first.Y) {
+			return nil, errors.New("shares belong to different splits")
+		}
+		if share.X == 0 || seen[share.X] {
+			return nil, fmt.Errorf("duplicate or invalid share %d", share.X)
+		}
+		seen[share.X] = true
+	}
+
+	if len(shares) < first.Threshold {
+		return nil, fmt.Errorf("%d shares needed, got %d", first.Threshold, len(shares))
+	}
+	shares = shares[:first.Threshold]
+
+	// Lagrange interpolation of the polynomials at x = 0
+	secret := make([]byte, len(first.Y))
+	for i, share := range shares {
+		basis := byte(1)
+		for j, other := range shares {
+			if i != j {
+				basis = gfMul(basis, gfDiv(other.X, other.X^share.X))
+			}
+		}
+		for b := range secret {
+			secret[b] ^= gfMul(share.Y[b], basis)
+		}
+	}
+
+	return secret, nil
+}
+
+// String returns the printable form of the share, the split ID, threshold,
+// share number, value and a checksum separated by dashes.
+func (s Share) String() string {
+	body := fmt.Sprintf("%s-%08X-%d-%d-%s", sharePrefix, s.ID, s.Threshold, s.X, shareEncoding.EncodeToString(s.Y))
+	return body + "-" + shareChecksum(body)
+}
+
+// ParseShare parses the printable form of a share, verifying its checksum.
+func ParseShare(text string) (Share, error) {
+	text = strings.ToUpper(strings.Join(strings.Fields(text), ""))
+
+	i := strings.LastIndex(text, "-")
+	if i < 0 {
+		return Share{}, errors.New("invalid share")
+	}
+	body, checksum := text[:i], text[i+1:]
+	if checksum != shareChecksum(body) {
+		return Share{}, errors.New("share checksum mismatch, check the share for typos")
+	}
+
+	parts := strings.Split(body, "-")
+	if len(parts) != 5 || parts[0] != sharePrefix {
+		return Share{}, errors.New("invalid share")
+	}
+
+	id, err := hex.DecodeString(parts[1])
+	if err != nil || len(id) != 4 {
+		return Share{}, errors.New("invalid share id")
+	}
+	threshold, err := strconv.Atoi(parts[2])
+	if err != nil || threshold < 2 {
+		return Share{}, errors.New("invalid share threshold")
+	}
+	x, err := strconv.ParseUint(parts[3], 10, 8)
+	if err != nil || x == 0 {
+		return Share{}, errors.New("invalid share number")
+	}
+	y, err := shareEncoding.DecodeString(parts[4])
+	if err != nil || len(y) == 0 {
+		return Share{}, errors.New("invalid share value")
+	}
+
+	return Share{
+		ID:        binary.BigEndian.Uint32(id),
+		Threshold: threshold,
+		X:         byte(x),
+		Y:         y,
+	}, nil
+}
+
+// shareChecksum returns the first 4 bytes of the SHA-256 of the share body.
+func shareChecksum(body string) string {
+	sum := sha256.Sum256([]byte(body))
+	return fmt.Sprintf("%X", sum[:4])
+}
+
+// gfEval evaluates a polynomial over GF(2^8) at x using Horner's method.
+func gfEval(coefficients []byte, x byte) byte {
+	result := byte(0)
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		result = gfMul(result, x) ^ coefficients[i]
+	}
+	return result
+}
+
+// gfMul multiplies in GF(2^8) with the AES polynomial, without branching on
+// the operands.
+func gfMul(a, b byte) byte {
+	var product byte
+	for i := 0; i < 8; i++ {
+		product ^= -(b & 1) & a
+		carry := -(a >> 7) & 0x1b
+		a = a<<1 ^ carry
+		b >>= 1
+	}
+	return product
+}
+
+// gfDiv divides in GF(2^8), b must not be zero. The inverse of b is b^254.
+func gfDiv(a, b byte) byte {
+	inverse := b
+	for i := 0; i < 6; i++ {
+		inverse = gfMul(gfMul(inverse, inverse), b)
+	}
+	return gfMul(a, gfMul(inverse, inverse))
+}
@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestShamir_SplitCombine(t *testing.T) {
+	secret := []byte("mysecretkey")
+
+	shares, err := SplitSecret(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(shares) != 5 {
+		t.Fatalf("Expected 5 shares, got %d", len(shares))
+	}
+
+	// Any 3 shares rebuild the secret
+	for _, subset := range [][]int{{0, 1, 2}, {4, 2, 0}, {1, 3, 4}, {0, 1, 2, 3, 4}} {
+		selected := []Share{}
+		for _, i := range subset {
+			selected = append(selected, shares[i])
+		}
+		combined, err := CombineShares(selected)
+		if err != nil || !bytes.Equal(combined, secret) {
+			t.Errorf("Expected the secret from shares %v, got %q (%v)", subset, combined, err)
+		}
+	}
+
+	if _, err := CombineShares(shares[:2]); err == nil {
+		t.Error("Expected error with fewer shares than the threshold")
+	}
+
+	if _, err := CombineShares([]Share{shares[0], shares[0], shares[1]}); err == nil {
+		t.Error("Expected error for duplicate shares")
+	}
+
+	other, _ := SplitSecret(secret, 5, 3)
+	if _, err := CombineShares([]Share{shares[0], shares[1], other[2]}); err == nil {
+		t.Error("Expected error mixing shares of different splits")
+	}
+
+	for _, invalid := range [][2]int{{5, 1}, {3, 4}, {256, 3}} {
+		if _, err := SplitSecret(secret, invalid[0], invalid[1]); err == nil {
+			t.Errorf("Expected error for %d of %d shares", invalid[1], invalid[0])
+		}
+	}
+}
+
+func TestShamir_ParseShare(t *testing.T) {
+	shares, err := SplitSecret([]byte("mysecretkey"), 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	text := shares[1].String()
+	if !strings.HasPrefix(text, "SSS1-") {
+		t.Errorf("Unexpected share format %q", text)
+	}
+
+	// Case and whitespace are ignored
+	parsed, err := ParseShare(" " + strings.ToLower(text[:10]) + " " + text[10:] + "\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID != shares[1].ID || parsed.Threshold != 2 || parsed.X != 2 || !bytes.Equal(parsed.Y, shares[1].Y) {
+		t.Errorf("Expected %v, got %v", shares[1], parsed)
+	}
+
+	// A typo is caught by the checksum
+	i := strings.LastIndex(text, "-") - 1
+	replacement := "A"
+	if text[i] == 'A' {
+		replacement = "B"
+	}
+	typo := text[:i] + replacement + text[i+1:]
+	if _, err := ParseShare(typo); err == nil {
+		t.Error("Expected checksum error for a typo")
+	}
+
+	if _, err := ParseShare("not a share"); err == nil {
+		t.Error("Expected error for an invalid share")
+	}
+}
+
+func TestShamir_GF256(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			if gfDiv(gfMul(byte(a), byte(b)), byte(b)) != byte(a) {
+				t.Fatalf("Expected %d * %d / %d = %d", a, b, b, a)
+			}
+		}
+	}
+}
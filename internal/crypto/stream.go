@@ -0,0 +1,366 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// streamMagic identifies streamed ciphertexts, the version byte that follows
+// is not part of the base64 alphabet so streams are told apart from the base64
+// ciphertexts produced by Encrypt.
+var streamMagic = []byte("SECS")
+
+// streamVersion is the current stream format version, version 2 added the
+// associated data.
+const streamVersion byte = 2
+
+// streamVersionNoContext is the first stream format version, it is not bound
+// to associated data.
+const streamVersionNoContext byte = 1
+
+const (
+	// streamChunkSize is the plain text size of every chunk but the last.
+	streamChunkSize = 64 * 1024
+	// streamNoncePrefixSize is the size of the random nonce prefix, the
+	// nonce of a chunk is prefix | counter (4 bytes) | last chunk flag.
+	streamNoncePrefixSize = nonceSize - 5
+)
+
+// streamWriter encrypts data written to it as a sequence of chunks, following
+// the STREAM construction. The stream is:
+//
+//	magic | version | KDF header | nonce prefix | chunk...
+//
+// Every chunk is sealed with AES-GCM, authenticating the header and the
+// associated data, under a nonce made of the prefix, the chunk counter and a
+// flag set on the last chunk, so chunks can't be reordered, dropped or the
+// stream truncated.
+type streamWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	ad      []byte
+	nonce   []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+// NewEncryptWriter returns a writer encrypting to w with a key derived from
+// the password. Close must be called to write the last chunk, it does not
+// close w.
+func NewEncryptWriter(w io.Writer, password []byte, params KDFParams) (io.WriteCloser, error) {
+	return NewEncryptWriterWithContext(w, password, params, nil)
+}
+
+// NewEncryptWriterWithContext returns a writer like NewEncryptWriter, the
+// stream is bound to the associated data.
+func NewEncryptWriterWithContext(w io.Writer, password []byte, params KDFParams, ad []byte) (io.WriteCloser, error) {
+	kdf, err := newKDFHeader(params)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := deriveKey(password, kdf.salt, kdf.params)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(derivedKey)
+	Wipe(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	header := append([]byte{}, streamMagic...)
+	header = append(header, streamVersion)
+	header = append(header, kdf.marshal()...)
+
+	prefix := make([]byte, streamNoncePrefixSize)
+	if _, err := io.ReadFull(rand.Reader, prefix); err != nil {
+		return nil, err
+	}
+	header = append(header, prefix...)
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &streamWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		ad:     concat(header, ad),
+		nonce:  make([]byte, nonceSize),
+		buf:    make([]byte, 0, streamChunkSize),
+	}, nil
+}
+
+// Write encrypts p, full chunks are only written once more data follows so
+// the last chunk can be flagged on Close.
+func (s *streamWriter) Write(p []byte) (int, error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(s.buf) == streamChunkSize {
+			if err := s.flush(false); err != nil {
+				return written, err
+			}
+		}
+
+		n := copy(s.buf[len(s.buf):streamChunkSize], p)
+		s.buf = s.buf[:len(s.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close writes the last chunk.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return nil
+	}
+	s.closed = true
+	return s.flush(true)
+}
+
+// flush seals and writes the buffered chunk.
+func (s *streamWriter) flush(last bool) error {
+	if err := streamNonce(s.nonce, s.header, s.counter, last); err != nil {
+		return err
+	}
+	s.counter++
+
+	sealed := s.aead.Seal(nil, s.nonce, s.buf, s.ad)
+	s.buf = s.buf[:0]
+
+	_, err := s.w.Write(sealed)
+	return err
+}
+
+// streamReader decrypts a stream produced by streamWriter.
+type streamReader struct {
+	r       *bufio.Reader
+	aead    cipher.AEAD
+	header  []byte
+	ad      []byte
+	nonce   []byte
+	counter uint32
+	chunk   []byte
+	out     []byte
+	plain   []byte
+	last    bool
+}
+
+// NewDecryptReader returns a reader decrypting r with the password. Base64
+// ciphertexts produced by Encrypt are accepted too, they are read whole.
+func NewDecryptReader(r io.Reader, password []byte) (io.Reader, error) {
+	return NewDecryptReaderWithContext(r, password, nil)
+}
+
+// NewDecryptReaderWithContext returns a reader like NewDecryptReader, the
+// stream must have been encrypted with the same associated data. Ciphertexts
+// without associated data, base64 ones and streams of the first version, fail
+// with ErrNoContext unless ad is empty.
+func NewDecryptReaderWithContext(r io.Reader, password []byte, ad []byte) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(len(streamMagic) + 1)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	if len(magic) <= len(streamMagic) || !bytes.HasPrefix(magic, streamMagic) {
+		return openBase64(br, password, ad)
+	}
+	switch magic[len(streamMagic)] {
+	case streamVersion:
+	case streamVersionNoContext:
+		if len(ad) > 0 {
+			return nil, ErrNoContext
+		}
+	default:
+		return openBase64(br, password, ad)
+	}
+
+	// The KDF header is variable length, its last fixed byte is the salt size
+	const fixed = 4 + 1 + 1 + 12 + 1
+	header := make([]byte, len(magic)+fixed)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, errors.New("invalid stream header")
+	}
+	salt := make([]byte, int(header[len(header)-1])+streamNoncePrefixSize)
+	if _, err := io.ReadFull(br, salt); err != nil {
+		return nil, errors.New("invalid stream header")
+	}
+	header = append(header, salt...)
+
+	kdf, _, err := parseKDFHeader(header[len(magic):])
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := deriveKey(password, kdf.salt, kdf.params)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(derivedKey)
+	Wipe(derivedKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamReader{
+		r:      br,
+		aead:   aead,
+		header: header,
+		ad:     concat(header, ad),
+		nonce:  make([]byte, nonceSize),
+		chunk:  make([]byte, streamChunkSize+aead.Overhead()),
+		out:    make([]byte, 0, streamChunkSize),
+	}, nil
+}
+
+// Read returns decrypted data, chunks are only returned once authenticated.
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.last {
+			return 0, io.EOF
+		}
+		if err := s.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the next chunk. A full chunk may be the last one, it
+// is opened as such if it fails to open as an intermediate chunk.
+func (s *streamReader) next() error {
+	n, err := io.ReadFull(s.r, s.chunk)
+	switch {
+	case err == io.EOF:
+		return errors.New("stream truncated, missing last chunk")
+	case err == io.ErrUnexpectedEOF:
+		return s.open(s.chunk[:n], true)
+	case err != nil:
+		return err
+	}
+
+	if s.open(s.chunk, false) == nil {
+		return nil
+	}
+	if err := s.open(s.chunk, true); err != nil {
+		return err
+	}
+
+	if _, err := s.r.ReadByte(); err != io.EOF {
+		return errors.New("unexpected data after the last chunk")
+	}
+	return nil
+}
+
+// open authenticates and decrypts a chunk.
+func (s *streamReader) open(chunk []byte, last bool) error {
+	if err := streamNonce(s.nonce, s.header, s.counter, last); err != nil {
+		return err
+	}
+
+	plain, err := s.aead.Open(s.out[:0], s.nonce, chunk, s.ad)
+	if err != nil {
+		return err
+	}
+
+	s.plain = plain
+	s.counter++
+	s.last = last
+	return nil
+}
+
+// streamNonce builds the nonce of a chunk, the prefix is the end of the
+// header.
+func streamNonce(nonce []byte, header []byte, counter uint32, last bool) error {
+	if counter == ^uint32(0) {
+		return errors.New("stream too long")
+	}
+
+	copy(nonce, header[len(header)-streamNoncePrefixSize:])
+	binary.BigEndian.PutUint32(nonce[streamNoncePrefixSize:], counter)
+	nonce[nonceSize-1] = 0
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+	return nil
+}
+
+// openBase64 reads a whole base64 ciphertext produced by Encrypt, empty input
+// decrypts to nothing. It is not bound to associated data, it fails with
+// ErrNoContext if ad is given and the ciphertext opens without it.
+func openBase64(r io.Reader, password []byte, ad []byte) (io.Reader, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return bytes.NewReader(nil), nil
+	}
+
+	plainText, err := Decrypt(string(data), password)
+	if err != nil {
+		return nil, err
+	}
+	if len(ad) > 0 {
+		return nil, ErrNoContext
+	}
+	return bytes.NewReader([]byte(plainText)), nil
+}
+
+// EncryptStream encrypts src to dst as a stream.
+func EncryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	return EncryptStreamWithContext(dst, src, key, nil)
+}
+
+// EncryptStreamWithContext encrypts src to dst as a stream bound to the
+// associated data.
+func EncryptStreamWithContext(dst io.Writer, src io.Reader, key []byte, ad []byte) error {
+	w, err := NewEncryptWriterWithContext(dst, key, DefaultKDFParams, ad)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(w, src); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// DecryptStream decrypts a stream, or a base64 ciphertext, from src to dst.
+func DecryptStream(dst io.Writer, src io.Reader, key []byte) error {
+	return DecryptStreamWithContext(dst, src, key, nil)
+}
+
+// DecryptStreamWithContext decrypts a stream bound to the associated data,
+// or a base64 ciphertext, from src to dst.
+func DecryptStreamWithContext(dst io.Writer, src io.Reader, key []byte, ad []byte) error {
+	r, err := NewDecryptReaderWithContext(src, key, ad)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(dst, r)
+	return err
+}
@@ -0,0 +1,143 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+// encryptStream encrypts data as a stream for tests.
+func encryptStream(t *testing.T, data []byte, key []byte) []byte {
+	var encrypted bytes.Buffer
+	if err := EncryptStream(&encrypted, bytes.NewReader(data), key); err != nil {
+		t.Fatal(err)
+	}
+	return encrypted.Bytes()
+}
+
+func TestStream_EncryptDecrypt(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	for _, size := range []int{0, 1, streamChunkSize - 1, streamChunkSize, streamChunkSize + 1, 3 * streamChunkSize} {
+		data := make([]byte, size)
+		if _, err := rand.Read(data); err != nil {
+			t.Fatal(err)
+		}
+
+		encrypted := encryptStream(t, data, key)
+
+		var decrypted bytes.Buffer
+		if err := DecryptStream(&decrypted, bytes.NewReader(encrypted), key); err != nil {
+			t.Fatalf("Size %d: %v", size, err)
+		}
+		if !bytes.Equal(decrypted.Bytes(), data) {
+			t.Errorf("Size %d: decrypted data differs", size)
+		}
+
+		if err := DecryptStream(io.Discard, bytes.NewReader(encrypted), []byte("wrongkey")); err == nil {
+			t.Errorf("Size %d: expected error for a wrong key", size)
+		}
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	key := []byte("mysecretkey")
+	data := make([]byte, 2*streamChunkSize+100)
+	encrypted := encryptStream(t, data, key)
+
+	header := len(encrypted) - len(data) - 3*16
+	chunk := streamChunkSize + 16
+
+	tampered := map[string][]byte{
+		// Truncated at a chunk boundary, the last chunk is missing
+		"truncated": encrypted[:header+2*chunk],
+		// Truncated inside the last chunk
+		"short":         encrypted[:len(encrypted)-1],
+		"trailing data": append(append([]byte{}, encrypted...), 0),
+		"swapped chunks": concat(concat(encrypted[:header], encrypted[header+chunk:header+2*chunk]),
+			concat(encrypted[header:header+chunk], encrypted[header+2*chunk:])),
+	}
+	flipped := append([]byte{}, encrypted...)
+	flipped[header+chunk+10] ^= 1
+	tampered["flipped bit"] = flipped
+
+	for name, stream := range tampered {
+		if err := DecryptStream(io.Discard, bytes.NewReader(stream), key); err == nil {
+			t.Errorf("Expected error for %s stream", name)
+		}
+	}
+}
+
+func TestStream_Base64(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	encrypted, err := Encrypt("Hello, World!", key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Ciphertexts produced by Encrypt are still accepted
+	var decrypted bytes.Buffer
+	if err := DecryptStream(&decrypted, bytes.NewReader([]byte(encrypted+"\n")), key); err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.String() != "Hello, World!" {
+		t.Errorf("Expected 'Hello, World!', got %q", decrypted.String())
+	}
+}
+
+func TestStream_Context(t *testing.T) {
+	key := []byte("mysecretkey")
+	ad := AssociatedData("ssh-key", "alice/id_rsa")
+
+	var encrypted bytes.Buffer
+	if err := EncryptStreamWithContext(&encrypted, bytes.NewReader([]byte("private key")), key, ad); err != nil {
+		t.Fatal(err)
+	}
+
+	var decrypted bytes.Buffer
+	if err := DecryptStreamWithContext(&decrypted, bytes.NewReader(encrypted.Bytes()), key, ad); err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.String() != "private key" {
+		t.Errorf("Expected 'private key', got %q", decrypted.String())
+	}
+
+	// A stream moved to another context fails to open
+	for _, other := range [][]byte{nil, AssociatedData("ssh-key", "bob/id_rsa")} {
+		if err := DecryptStreamWithContext(io.Discard, bytes.NewReader(encrypted.Bytes()), key, other); err == nil {
+			t.Errorf("Expected error for associated data %q", other)
+		}
+	}
+
+	// Streams of the first version are not bound to associated data
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, key, DefaultKDFParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stream := w.(*streamWriter)
+	stream.header[len(streamMagic)] = streamVersionNoContext
+	stream.ad = stream.header
+	w.Write([]byte("legacy"))
+	w.Close()
+	legacy := buf.Bytes()
+	legacy[len(streamMagic)] = streamVersionNoContext
+
+	if err := DecryptStreamWithContext(io.Discard, bytes.NewReader(legacy), key, ad); !errors.Is(err, ErrNoContext) {
+		t.Errorf("Expected ErrNoContext for the first version, got %v", err)
+	}
+	decrypted.Reset()
+	if err := DecryptStreamWithContext(&decrypted, bytes.NewReader(legacy), key, nil); err != nil || decrypted.String() != "legacy" {
+		t.Errorf("Expected the first version to open without context, got %q (%v)", decrypted.String(), err)
+	}
+
+	// Base64 ciphertexts are not bound to associated data either
+	encrypted64, _ := Encrypt("legacy", key)
+	if err := DecryptStreamWithContext(io.Discard, strings.NewReader(encrypted64), key, ad); !errors.Is(err, ErrNoContext) {
+		t.Errorf("Expected ErrNoContext for a base64 ciphertext, got %v", err)
+	}
+}
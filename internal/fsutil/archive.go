@@ -0,0 +1,201 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// WriteTar writes a file or a directory tree as a tar archive keeping the
+// file modes, entries are named relative to the parent of root. Only regular
+// files and directories can be archived.
+func WriteTar(w io.Writer, root string) error {
+	tw := tar.NewWriter(w)
+	parent := filepath.Dir(filepath.Clean(root))
+
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return fmt.Errorf("'%s' is not a regular file or directory", p)
+		}
+
+		name, err := filepath.Rel(parent, p)
+		if err != nil {
+			return err
+		}
+
+		header := &tar.Header{
+			Name:    filepath.ToSlash(name),
+			Mode:    int64(info.Mode().Perm()),
+			ModTime: info.ModTime(),
+		}
+		if info.IsDir() {
+			header.Typeflag = tar.TypeDir
+			header.Name += "/"
+		} else {
+			header.Typeflag = tar.TypeReg
+			header.Size = info.Size()
+		}
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		file, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tw, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// IsTar reports whether data starts with a tar header.
+func IsTar(data []byte) bool {
+	_, err := tar.NewReader(bytes.NewReader(data)).Next()
+	return err == nil
+}
+
+// ReadTar restores an archive written by WriteTar to dst. An archived file
+// is written atomically, an archived directory is extracted next to dst and
+// renamed once complete, dst must not exist.
+func ReadTar(r io.Reader, dst string) error {
+	tr := tar.NewReader(r)
+
+	header, err := tr.Next()
+	if err != nil {
+		return fmt.Errorf("invalid archive: %w", err)
+	}
+
+	if header.Typeflag == tar.TypeReg {
+		err := WriteAtomic(dst, fs.FileMode(header.Mode).Perm(), func(w io.Writer) error {
+			if _, err := io.Copy(w, tr); err != nil {
+				return err
+			}
+			if _, err := tr.Next(); err != io.EOF {
+				return errors.New("unexpected entries after the archived file")
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return os.Chmod(dst, fs.FileMode(header.Mode).Perm())
+	}
+
+	if header.Typeflag != tar.TypeDir {
+		return fmt.Errorf("unsupported archive entry '%s'", header.Name)
+	}
+
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("'%s' already exists", dst)
+	}
+
+	tmp, err := os.MkdirTemp(filepath.Dir(dst), "."+filepath.Base(dst)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Remove the partial extraction if anything goes wrong
+	success := false
+	defer func() {
+		if !success {
+			os.RemoveAll(tmp)
+		}
+	}()
+
+	root := strings.TrimSuffix(header.Name, "/")
+	dirModes := map[string]fs.FileMode{tmp: fs.FileMode(header.Mode).Perm()}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name, found := strings.CutPrefix(strings.TrimSuffix(header.Name, "/"), root+"/")
+		if !found || !filepath.IsLocal(name) || path.Clean(name) != name {
+			return fmt.Errorf("archive entry '%s' is outside of '%s'", header.Name, root)
+		}
+		target := filepath.Join(tmp, filepath.FromSlash(name))
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0700); err != nil {
+				return err
+			}
+			dirModes[target] = fs.FileMode(header.Mode).Perm()
+		case tar.TypeReg:
+			if err := extractFile(tr, target, fs.FileMode(header.Mode).Perm()); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported archive entry '%s'", header.Name)
+		}
+	}
+
+	// Directories are kept writable until every file is extracted
+	for dir, mode := range dirModes {
+		if err := os.Chmod(dir, mode); err != nil {
+			return err
+		}
+	}
+
+	if err := os.Rename(tmp, dst); err != nil {
+		return err
+	}
+	success = true
+
+	syncDir(filepath.Dir(dst))
+	return nil
+}
+
+// extractFile writes an archived file with its mode.
+func extractFile(r io.Reader, target string, mode fs.FileMode) error {
+	if err := os.MkdirAll(filepath.Dir(target), 0700); err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	// The mode given to OpenFile is subject to the umask
+	return os.Chmod(target, mode)
+}
@@ -0,0 +1,104 @@
+package fsutil
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTar_File(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "kubeconfig")
+	if err := os.WriteFile(source, []byte("data"), 0640); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(source, 0640); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteTar(&archive, source); err != nil {
+		t.Fatal(err)
+	}
+
+	if !IsTar(archive.Bytes()) || IsTar([]byte("data")) {
+		t.Error("Expected only the archive to be detected as tar")
+	}
+
+	target := filepath.Join(dir, "restored")
+	if err := ReadTar(&archive, target); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(target)
+	if err != nil || string(data) != "data" {
+		t.Errorf("Expected 'data', got %q (%v)", data, err)
+	}
+
+	info, _ := os.Stat(target)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("Expected mode 0640, got %v", info.Mode().Perm())
+	}
+}
+
+func TestTar_Directory(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "dump")
+	if err := os.MkdirAll(filepath.Join(source, "sub"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "sub", "script.sh"), []byte("#!/bin/sh"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(source, "data.sql"), []byte("select 1;"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := WriteTar(&archive, source); err != nil {
+		t.Fatal(err)
+	}
+	contents := archive.Bytes()
+
+	target := filepath.Join(dir, "restored")
+	if err := ReadTar(bytes.NewReader(contents), target); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(target, "data.sql"))
+	if err != nil || string(data) != "select 1;" {
+		t.Errorf("Expected 'select 1;', got %q (%v)", data, err)
+	}
+
+	info, err := os.Stat(filepath.Join(target, "sub", "script.sh"))
+	if err != nil || info.Mode().Perm() != 0700 {
+		t.Errorf("Expected an executable script, got %v (%v)", info, err)
+	}
+
+	// Directories are not restored over existing ones
+	if err := ReadTar(bytes.NewReader(contents), target); err == nil {
+		t.Error("Expected error restoring over an existing directory")
+	}
+}
+
+func TestTar_OutsideEntries(t *testing.T) {
+	var archive bytes.Buffer
+	tw := tar.NewWriter(&archive)
+	_ = tw.WriteHeader(&tar.Header{Name: "dump/", Typeflag: tar.TypeDir, Mode: 0700})
+	_ = tw.WriteHeader(&tar.Header{Name: "dump/../escape", Typeflag: tar.TypeReg, Mode: 0600})
+	_ = tw.Close()
+
+	dir := t.TempDir()
+	if err := ReadTar(&archive, filepath.Join(dir, "restored")); err == nil {
+		t.Error("Expected error for an entry outside of the archived directory")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "escape")); !os.IsNotExist(err) {
+		t.Error("Expected no file outside of the target")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Expected the partial extraction to be removed, got %v", entries)
+	}
+}
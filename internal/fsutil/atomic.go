@@ -0,0 +1,81 @@
+package fsutil
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// DefaultFileMode is the mode used for new vault and key files.
+const DefaultFileMode os.FileMode = 0600
+
+// WriteFileAtomic writes data to a temporary file next to path, syncs it to
+// disk and renames it over path, so readers see either the old or the new
+// contents. The permissions of an existing file are preserved, new files are
+// created with perm.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	return WriteAtomic(path, perm, func(w io.Writer) error {
+		_, err := w.Write(data)
+		return err
+	})
+}
+
+// WriteAtomic is like WriteFileAtomic but streams the contents from write,
+// path is left untouched if write fails.
+func WriteAtomic(path string, perm os.FileMode, write func(w io.Writer) error) error {
+	mode := perm
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+
+	// Remove the temporary file if anything goes wrong
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpName)
+		}
+	}()
+
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+
+	if err := write(tmp); err != nil {
+		return err
+	}
+
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpName, path); err != nil {
+		return err
+	}
+	success = true
+
+	syncDir(dir)
+	return nil
+}
+
+// syncDir flushes the directory entry of a renamed file, errors are ignored
+// since not every platform supports syncing directories.
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
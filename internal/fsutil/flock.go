@@ -0,0 +1,63 @@
+package fsutil
+
+import (
+	"errors"
+	"os"
+	"time"
+)
+
+// ErrLockTimeout is returned when a lock can't be acquired in time.
+var ErrLockTimeout = errors.New("timeout waiting for file lock")
+
+// lockRetryInterval is the delay between attempts to acquire a lock.
+const lockRetryInterval = 50 * time.Millisecond
+
+// FileLock is an advisory lock held on a lock file.
+type FileLock struct {
+	file *os.File
+}
+
+// LockFile acquires an advisory lock on path, creating it if needed. A
+// shared lock can be held by several readers, an exclusive lock by a single
+// writer. It waits up to timeout before returning ErrLockTimeout.
+func LockFile(path string, exclusive bool, timeout time.Duration) (*FileLock, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, DefaultFileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(file, exclusive)
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+
+		if locked {
+			return &FileLock{file: file}, nil
+		}
+
+		if time.Now().After(deadline) {
+			file.Close()
+			return nil, ErrLockTimeout
+		}
+
+		time.Sleep(lockRetryInterval)
+	}
+}
+
+// Unlock releases the lock.
+func (l *FileLock) Unlock() error {
+	if l == nil || l.file == nil {
+		return nil
+	}
+
+	err := unlock(l.file)
+	closeErr := l.file.Close()
+	l.file = nil
+	if err != nil {
+		return err
+	}
+	return closeErr
+}
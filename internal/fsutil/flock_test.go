@@ -0,0 +1,53 @@
+package fsutil
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLockFile_Exclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repository.vault.lock")
+
+	l, err := LockFile(path, true, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = LockFile(path, false, 100*time.Millisecond)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout, got %v", err)
+	}
+
+	if err := l.Unlock(); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err = LockFile(path, true, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected lock to be released, got %v", err)
+	}
+	l.Unlock()
+}
+
+func TestLockFile_Shared(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "repository.vault.lock")
+
+	first, err := LockFile(path, false, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Unlock()
+
+	second, err := LockFile(path, false, 100*time.Millisecond)
+	if err != nil {
+		t.Fatalf("Expected shared locks to coexist, got %v", err)
+	}
+	defer second.Unlock()
+
+	_, err = LockFile(path, true, 100*time.Millisecond)
+	if !errors.Is(err, ErrLockTimeout) {
+		t.Errorf("Expected ErrLockTimeout, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// DefaultAgentChallenge is the data signed by the agent when no challenge is
+// configured, followed by the vault ID for vault keys.
+const DefaultAgentChallenge = "sectool vault key"
+
+// VaultChallenge returns the default challenge of a vault, every vault
+// protected by the same ssh key derives its own key.
+func VaultChallenge(id string) string {
+	return DefaultAgentChallenge + "\x00" + id
+}
+
+// agentKeyInfo is the HKDF info used to derive keys from agent signatures.
+const agentKeyInfo = "sectool ssh-agent key"
+
+// DialAgent connects to the ssh-agent listening on SSH_AUTH_SOCK.
+func DialAgent() (agent.ExtendedAgent, io.Closer, error) {
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, nil, errors.New("SSH_AUTH_SOCK is not defined, is ssh-agent running?")
+	}
+
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+
+	return agent.NewClient(conn), conn, nil
+}
+
+// AgentKey derives a key from the signature of the challenge by the agent key
+// matching key, given as a public key, a SHA256 fingerprint or the key
+// comment. Only keys with deterministic signatures can be used, Ed25519 and
+// RSA, so the same key is derived every time.
+func AgentKey(client agent.ExtendedAgent, key string, challenge string) (string, error) {
+	publicKey, err := findAgentKey(client, key)
+	if err != nil {
+		return "", err
+	}
+
+	if challenge == "" {
+		challenge = DefaultAgentChallenge
+	}
+
+	var signature *ssh.Signature
+	switch publicKey.Type() {
+	case ssh.KeyAlgoED25519:
+		signature, err = client.Sign(publicKey, []byte(challenge))
+	case ssh.KeyAlgoRSA:
+		signature, err = client.SignWithFlags(publicKey, []byte(challenge), agent.SignatureFlagRsaSha256)
+	default:
+		return "", fmt.Errorf("%s keys have no deterministic signatures, use an ed25519 or rsa key", publicKey.Type())
+	}
+	if err != nil {
+		return "", fmt.Errorf("ssh-agent failed to sign: %w", err)
+	}
+
+	derived := make([]byte, 32)
+	kdf := hkdf.New(sha256.New, signature.Blob, []byte(challenge), []byte(agentKeyInfo))
+	if _, err := io.ReadFull(kdf, derived); err != nil {
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(derived), nil
+}
+
+// findAgentKey returns the agent key matching key.
+func findAgentKey(client agent.ExtendedAgent, key string) (ssh.PublicKey, error) {
+	keys, err := client.List()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ssh-agent keys: %w", err)
+	}
+
+	var wanted []byte
+	if publicKey, _, _, _, err := ssh.ParseAuthorizedKey([]byte(key)); err == nil {
+		wanted = publicKey.Marshal()
+	}
+
+	for _, agentKey := range keys {
+		switch {
+		case wanted != nil && string(agentKey.Blob) == string(wanted):
+		case ssh.FingerprintSHA256(agentKey) == strings.TrimSpace(key):
+		case agentKey.Comment != "" && agentKey.Comment == key:
+		default:
+			continue
+		}
+		return agentKey, nil
+	}
+
+	return nil, fmt.Errorf("key '%s' not found in ssh-agent", key)
+}
@@ -0,0 +1,56 @@
+package ssh
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+func TestAgentKey(t *testing.T) {
+	_, ed25519Key, _ := ed25519.GenerateKey(rand.Reader)
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecdsaKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	keyring := agent.NewKeyring().(agent.ExtendedAgent)
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: ed25519Key, Comment: "alice@laptop"}))
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: rsaKey, Comment: "alice@rsa"}))
+	assert.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: ecdsaKey, Comment: "alice@ecdsa"}))
+
+	publicKey, _ := ssh.NewPublicKey(ed25519Key.Public())
+
+	// The key can be selected by comment, fingerprint or public key
+	key, err := AgentKey(keyring, "alice@laptop", "")
+	assert.NoError(t, err)
+	assert.NotEmpty(t, key)
+
+	byFingerprint, err := AgentKey(keyring, ssh.FingerprintSHA256(publicKey), "")
+	assert.NoError(t, err)
+	assert.Equal(t, key, byFingerprint)
+
+	byPublicKey, err := AgentKey(keyring, string(ssh.MarshalAuthorizedKey(publicKey)), DefaultAgentChallenge)
+	assert.NoError(t, err)
+	assert.Equal(t, key, byPublicKey)
+
+	// Another challenge derives another key
+	other, err := AgentKey(keyring, "alice@laptop", "another vault")
+	assert.NoError(t, err)
+	assert.NotEqual(t, key, other)
+
+	rsaDerived, err := AgentKey(keyring, "alice@rsa", "")
+	assert.NoError(t, err)
+	again, _ := AgentKey(keyring, "alice@rsa", "")
+	assert.Equal(t, rsaDerived, again)
+
+	_, err = AgentKey(keyring, "alice@ecdsa", "")
+	assert.Error(t, err)
+
+	_, err = AgentKey(keyring, "bob@laptop", "")
+	assert.Error(t, err)
+}
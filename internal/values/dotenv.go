@@ -0,0 +1,110 @@
+package values
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// dotenvMetadataPrefix prefixes the variables holding the metadata.
+const dotenvMetadataPrefix = metadataKey + "_"
+
+// dotenvLine is a line of a dotenv file, comments and blank lines have no key.
+type dotenvLine struct {
+	raw    string
+	export bool
+	key    string
+	value  string
+}
+
+// String returns the line as written to the file.
+func (l *dotenvLine) String() string {
+	if l.key == "" {
+		return l.raw
+	}
+	if l.export {
+		return "export " + l.key + "=" + l.value
+	}
+	return l.key + "=" + l.value
+}
+
+// dotenvDocument is a dotenv file, a KEY=VALUE assignment per line. Values
+// are kept as written, quotes included, so they are restored exactly.
+type dotenvDocument struct {
+	lines []*dotenvLine
+}
+
+// parseDotenv parses a dotenv file, lines that are not assignments are kept
+// as they are.
+func parseDotenv(data []byte) *dotenvDocument {
+	doc := &dotenvDocument{}
+	contents := strings.TrimSuffix(string(data), "\n")
+	if contents == "" {
+		return doc
+	}
+
+	for _, raw := range strings.Split(contents, "\n") {
+		line := &dotenvLine{raw: raw}
+		trimmed := strings.TrimSpace(raw)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			assignment, export := strings.CutPrefix(trimmed, "export ")
+			if key, value, found := strings.Cut(assignment, "="); found && strings.TrimSpace(key) != "" {
+				line.export, line.key, line.value = export, strings.TrimSpace(key), value
+			}
+		}
+		doc.lines = append(doc.lines, line)
+	}
+	return doc
+}
+
+// isMetadata reports whether a line holds metadata.
+func (l *dotenvLine) isMetadata() bool {
+	return strings.HasPrefix(l.key, dotenvMetadataPrefix)
+}
+
+func (d *dotenvDocument) leaves(re *regexp.Regexp) []*leaf {
+	leaves := []*leaf{}
+	for _, line := range d.lines {
+		if line.key == "" || line.isMetadata() {
+			continue
+		}
+		leaves = append(leaves, &leaf{
+			path:     []string{line.key},
+			value:    leafValue{Value: line.value},
+			selected: re == nil || re.MatchString(line.key),
+			set: func(value leafValue) {
+				line.value = value.Value
+			},
+		})
+	}
+	return leaves
+}
+
+func (d *dotenvDocument) metadata() (map[string]string, bool) {
+	fields := map[string]string{}
+	for _, line := range d.lines {
+		if line.isMetadata() {
+			fields[strings.TrimPrefix(line.key, dotenvMetadataPrefix)] = line.value
+		}
+	}
+	return fields, len(fields) > 0
+}
+
+func (d *dotenvDocument) setMetadata(fields map[string]string) {
+	d.removeMetadata()
+	for _, name := range metadataFields {
+		d.lines = append(d.lines, &dotenvLine{key: dotenvMetadataPrefix + name, value: fields[name]})
+	}
+}
+
+func (d *dotenvDocument) removeMetadata() {
+	d.lines = slices.DeleteFunc(d.lines, (*dotenvLine).isMetadata)
+}
+
+func (d *dotenvDocument) encode() ([]byte, error) {
+	var b strings.Builder
+	for _, line := range d.lines {
+		b.WriteString(line.String() + "\n")
+	}
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,264 @@
+package values
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// treeDocument is a YAML or JSON document, JSON is parsed to the same tree
+// so both are walked the same way.
+type treeDocument struct {
+	doc  *yaml.Node
+	root *yaml.Node
+	json bool
+}
+
+// parseYAML parses a YAML document keeping its comments.
+func parseYAML(data []byte) (*treeDocument, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, errors.New("YAML document is not a mapping")
+	}
+	return &treeDocument{doc: &doc, root: doc.Content[0]}, nil
+}
+
+// parseJSON parses a JSON object keeping the order of its keys.
+func parseJSON(data []byte) (*treeDocument, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	root, err := parseJSONValue(dec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid JSON: unexpected data after the object")
+	}
+	if root.Kind != yaml.MappingNode {
+		return nil, errors.New("JSON document is not an object")
+	}
+	return &treeDocument{root: root, json: true}, nil
+}
+
+// parseJSONValue parses the next JSON value to a node.
+func parseJSONValue(dec *json.Decoder) (*yaml.Node, error) {
+	token, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch token := token.(type) {
+	case json.Delim:
+		node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+		if token == '[' {
+			node = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		}
+		for dec.More() {
+			if node.Kind == yaml.MappingNode {
+				key, err := dec.Token()
+				if err != nil {
+					return nil, err
+				}
+				node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key.(string)})
+			}
+			value, err := parseJSONValue(dec)
+			if err != nil {
+				return nil, err
+			}
+			node.Content = append(node.Content, value)
+		}
+		// The closing delimiter
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+		return node, nil
+	case string:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: token}, nil
+	case json.Number:
+		tag := "!!int"
+		if strings.ContainsAny(token.String(), ".eE") {
+			tag = "!!float"
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: token.String()}, nil
+	case bool:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(token)}, nil
+	case nil:
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!null", Value: "null"}, nil
+	}
+	return nil, fmt.Errorf("unexpected token %v", token)
+}
+
+func (d *treeDocument) leaves(re *regexp.Regexp) []*leaf {
+	leaves := []*leaf{}
+	var walk func(node *yaml.Node, path []string, selected bool)
+	walk = func(node *yaml.Node, path []string, selected bool) {
+		switch node.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				key := node.Content[i].Value
+				if node == d.root && key == metadataKey {
+					continue
+				}
+				walk(node.Content[i+1], append(slices.Clone(path), key), selected || re == nil || re.MatchString(key))
+			}
+		case yaml.SequenceNode:
+			for i, item := range node.Content {
+				walk(item, append(slices.Clone(path), strconv.Itoa(i)), selected)
+			}
+		case yaml.ScalarNode:
+			leaves = append(leaves, &leaf{
+				path:     path,
+				value:    leafValue{Value: node.Value, Tag: node.ShortTag(), Style: node.Style},
+				selected: selected,
+				set: func(value leafValue) {
+					node.Value, node.Tag, node.Style = value.Value, value.Tag, value.Style
+				},
+			})
+		}
+		// Aliases refer to values reached through their anchor
+	}
+	walk(d.root, nil, false)
+	return leaves
+}
+
+// metadataNode returns the index of the metadata value in the root mapping.
+func (d *treeDocument) metadataNode() int {
+	for i := 0; i+1 < len(d.root.Content); i += 2 {
+		if d.root.Content[i].Value == metadataKey {
+			return i + 1
+		}
+	}
+	return -1
+}
+
+func (d *treeDocument) metadata() (map[string]string, bool) {
+	i := d.metadataNode()
+	if i < 0 || d.root.Content[i].Kind != yaml.MappingNode {
+		return nil, false
+	}
+
+	fields := map[string]string{}
+	node := d.root.Content[i]
+	for j := 0; j+1 < len(node.Content); j += 2 {
+		fields[node.Content[j].Value] = node.Content[j+1].Value
+	}
+	return fields, true
+}
+
+func (d *treeDocument) setMetadata(fields map[string]string) {
+	node := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	for _, name := range metadataFields {
+		tag := "!!str"
+		if name == "version" {
+			tag = "!!int"
+		}
+		node.Content = append(node.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name},
+			&yaml.Node{Kind: yaml.ScalarNode, Tag: tag, Value: fields[name]})
+	}
+
+	if i := d.metadataNode(); i >= 0 {
+		d.root.Content[i] = node
+		return
+	}
+	d.root.Content = append(d.root.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: metadataKey}, node)
+}
+
+func (d *treeDocument) removeMetadata() {
+	if i := d.metadataNode(); i >= 0 {
+		d.root.Content = slices.Delete(d.root.Content, i-1, i+1)
+	}
+}
+
+func (d *treeDocument) encode() ([]byte, error) {
+	var b bytes.Buffer
+	if d.json {
+		if err := encodeJSON(&b, d.root, ""); err != nil {
+			return nil, err
+		}
+		b.WriteString("\n")
+		return b.Bytes(), nil
+	}
+
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	if err := enc.Encode(d.doc); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// encodeJSON writes a node as indented JSON.
+func encodeJSON(b *bytes.Buffer, node *yaml.Node, indent string) error {
+	switch node.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		open, close, step := "{", "}", 2
+		if node.Kind == yaml.SequenceNode {
+			open, close, step = "[", "]", 1
+		}
+		if len(node.Content) == 0 {
+			b.WriteString(open + close)
+			return nil
+		}
+
+		b.WriteString(open)
+		for i := 0; i < len(node.Content); i += step {
+			if i > 0 {
+				b.WriteString(",")
+			}
+			b.WriteString("\n" + indent + "  ")
+			if step == 2 {
+				if err := encodeJSONString(b, node.Content[i].Value); err != nil {
+					return err
+				}
+				b.WriteString(": ")
+			}
+			if err := encodeJSON(b, node.Content[i+step-1], indent+"  "); err != nil {
+				return err
+			}
+		}
+		b.WriteString("\n" + indent + close)
+		return nil
+	case yaml.ScalarNode:
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool":
+			b.WriteString(node.Value)
+		case "!!null":
+			b.WriteString("null")
+		default:
+			return encodeJSONString(b, node.Value)
+		}
+		return nil
+	}
+	return fmt.Errorf("unsupported JSON node kind %d", node.Kind)
+}
+
+// encodeJSONString writes a JSON string without escaping HTML characters.
+func encodeJSONString(b *bytes.Buffer, value string) error {
+	enc := json.NewEncoder(b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(value); err != nil {
+		return err
+	}
+	// Encode terminates the value with a newline
+	b.Truncate(b.Len() - 1)
+	return nil
+}
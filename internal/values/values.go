@@ -0,0 +1,394 @@
+// Package values encrypts the values of structured files, YAML, JSON and
+// dotenv, leaving their keys and layout readable so the files can be kept
+// in a repository and reviewed.
+package values
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/a13labs/sectool/internal/crypto"
+	"gopkg.in/yaml.v3"
+)
+
+// Format is the syntax of a structured file.
+type Format string
+
+const (
+	FormatYAML   Format = "yaml"
+	FormatJSON   Format = "json"
+	FormatDotenv Format = "dotenv"
+)
+
+// version is the current format version of encrypted files.
+const version = 1
+
+const (
+	// encryptedPrefix and encryptedSuffix enclose an encrypted value.
+	encryptedPrefix = "ENC[SECV1,"
+	encryptedSuffix = "]"
+
+	// metadataKey is the key holding the encryption metadata.
+	metadataKey = "sectool"
+)
+
+// ErrNotEncrypted is returned when decrypting a file without metadata.
+var ErrNotEncrypted = errors.New("file has no sectool metadata, it is not encrypted")
+
+// metadata describes how a file was encrypted, it is stored in the file.
+type metadata struct {
+	Version        int
+	KEK            string
+	EncryptedRegex string
+	MAC            string
+}
+
+// leafValue is a value with what is needed to restore it as it was, it is
+// the sealed plain text of an encrypted value.
+type leafValue struct {
+	Value string     `json:"v"`
+	Tag   string     `json:"t,omitempty"`
+	Style yaml.Style `json:"s,omitempty"`
+}
+
+// leaf is a value of a document with the path leading to it.
+type leaf struct {
+	path     []string
+	value    leafValue
+	selected bool
+	set      func(leafValue)
+}
+
+// id returns the leaf path as the identifier its value is bound to.
+func (l *leaf) id() string {
+	id, _ := json.Marshal(l.path)
+	return string(id)
+}
+
+// document is a parsed structured file.
+type document interface {
+	// leaves returns the values in document order, a value is selected if a
+	// key on its path matches re, or always if re is nil.
+	leaves(re *regexp.Regexp) []*leaf
+	metadata() (map[string]string, bool)
+	setMetadata(map[string]string)
+	removeMetadata()
+	encode() ([]byte, error)
+}
+
+// FormatFromPath returns the format of a file from its name.
+func FormatFromPath(path string) (Format, error) {
+	name := strings.ToLower(filepath.Base(path))
+	switch {
+	case strings.HasSuffix(name, ".yaml"), strings.HasSuffix(name, ".yml"):
+		return FormatYAML, nil
+	case strings.HasSuffix(name, ".json"):
+		return FormatJSON, nil
+	case strings.HasSuffix(name, ".env"), strings.HasPrefix(name, ".env"):
+		return FormatDotenv, nil
+	}
+	return "", fmt.Errorf("unknown format of '%s', set the format", path)
+}
+
+// ParseFormat validates a format name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(name) {
+	case FormatYAML, FormatJSON, FormatDotenv:
+		return Format(name), nil
+	case "yml":
+		return FormatYAML, nil
+	case "env":
+		return FormatDotenv, nil
+	}
+	return "", fmt.Errorf("unknown format '%s'", name)
+}
+
+// parse parses data in the given format.
+func parse(data []byte, format Format) (document, error) {
+	switch format {
+	case FormatYAML:
+		return parseYAML(data)
+	case FormatJSON:
+		return parseJSON(data)
+	case FormatDotenv:
+		return parseDotenv(data), nil
+	}
+	return nil, fmt.Errorf("unknown format '%s'", format)
+}
+
+// Encrypt encrypts the values of the file whose key, or the key of a parent,
+// matches the regular expression, all values if it is empty. Encrypting a file
+// again encrypts the values added since, keeping the encrypted ones, with the
+// regular expression it was encrypted with. The values already there must
+// match the stored MAC, so a modified file isn't signed again.
+func Encrypt(data []byte, format Format, key []byte, encryptedRegex string, params crypto.KDFParams) ([]byte, error) {
+	doc, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	var envelope *crypto.Envelope
+	var wrappedKEK, storedMAC []byte
+	stored, found := doc.metadata()
+	if found {
+		meta, err := parseMetadata(stored)
+		if err != nil {
+			return nil, err
+		}
+		if encryptedRegex != "" && encryptedRegex != meta.EncryptedRegex {
+			return nil, fmt.Errorf("file is encrypted with regex '%s', decrypt it first to change it", meta.EncryptedRegex)
+		}
+		encryptedRegex = meta.EncryptedRegex
+
+		if wrappedKEK, err = base64.StdEncoding.DecodeString(meta.KEK); err != nil {
+			return nil, fmt.Errorf("invalid key-encryption key: %w", err)
+		}
+		if storedMAC, err = base64.StdEncoding.DecodeString(meta.MAC); err != nil {
+			return nil, fmt.Errorf("invalid MAC: %w", err)
+		}
+		if envelope, err = crypto.OpenEnvelope(wrappedKEK, key); err != nil {
+			return nil, err
+		}
+	} else {
+		if envelope, err = crypto.NewEnvelope(); err != nil {
+			return nil, err
+		}
+		if wrappedKEK, err = envelope.WrapKEK(key, params); err != nil {
+			envelope.Close()
+			return nil, err
+		}
+	}
+	defer envelope.Close()
+
+	re, err := compileRegex(encryptedRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := doc.leaves(re)
+	for _, l := range leaves {
+		if !isEncrypted(l.value.Value) {
+			continue
+		}
+		// Encrypted values are kept as they are once they are known to open
+		plain, err := openLeaf(envelope, l)
+		if err != nil {
+			return nil, err
+		}
+		l.value = plain
+		l.set = nil
+	}
+
+	// The values to encrypt are the ones added since, the others are checked
+	if found {
+		var previous []*leaf
+		for _, l := range leaves {
+			if !l.selected || l.set == nil {
+				previous = append(previous, l)
+			}
+		}
+		expected, err := leavesMAC(envelope, encryptedRegex, previous)
+		if err != nil {
+			return nil, err
+		}
+		if !hmac.Equal(storedMAC, expected) {
+			return nil, errors.New("MAC mismatch, the file was modified after it was encrypted")
+		}
+	}
+
+	mac, err := leavesMAC(envelope, encryptedRegex, leaves)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, l := range leaves {
+		if !l.selected || l.set == nil {
+			continue
+		}
+		sealed, err := sealLeaf(envelope, l)
+		if err != nil {
+			return nil, err
+		}
+		l.set(leafValue{Value: sealed, Tag: "!!str"})
+	}
+
+	doc.setMetadata(metadata{
+		Version:        version,
+		KEK:            base64.StdEncoding.EncodeToString(wrappedKEK),
+		EncryptedRegex: encryptedRegex,
+		MAC:            base64.StdEncoding.EncodeToString(mac),
+	}.fields())
+
+	return doc.encode()
+}
+
+// Decrypt decrypts the values of a file encrypted by Encrypt and removes its
+// metadata, the MAC detects values changed, added or removed since.
+func Decrypt(data []byte, format Format, key []byte) ([]byte, error) {
+	doc, err := parse(data, format)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, found := doc.metadata()
+	if !found {
+		return nil, ErrNotEncrypted
+	}
+	meta, err := parseMetadata(stored)
+	if err != nil {
+		return nil, err
+	}
+
+	wrappedKEK, err := base64.StdEncoding.DecodeString(meta.KEK)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key-encryption key: %w", err)
+	}
+	envelope, err := crypto.OpenEnvelope(wrappedKEK, key)
+	if err != nil {
+		return nil, err
+	}
+	defer envelope.Close()
+
+	re, err := compileRegex(meta.EncryptedRegex)
+	if err != nil {
+		return nil, err
+	}
+
+	leaves := doc.leaves(re)
+	for _, l := range leaves {
+		if !isEncrypted(l.value.Value) {
+			continue
+		}
+		if l.value, err = openLeaf(envelope, l); err != nil {
+			return nil, err
+		}
+	}
+
+	expected, err := leavesMAC(envelope, meta.EncryptedRegex, leaves)
+	if err != nil {
+		return nil, err
+	}
+	mac, err := base64.StdEncoding.DecodeString(meta.MAC)
+	if err != nil || !hmac.Equal(mac, expected) {
+		return nil, errors.New("MAC mismatch, the file was modified after it was encrypted")
+	}
+
+	for _, l := range leaves {
+		l.set(l.value)
+	}
+	doc.removeMetadata()
+
+	return doc.encode()
+}
+
+// IsEncrypted reports whether the file has encryption metadata.
+func IsEncrypted(data []byte, format Format) bool {
+	doc, err := parse(data, format)
+	if err != nil {
+		return false
+	}
+	_, found := doc.metadata()
+	return found
+}
+
+// compileRegex compiles the regular expression selecting the keys to
+// encrypt, nil selects all keys.
+func compileRegex(encryptedRegex string) (*regexp.Regexp, error) {
+	if encryptedRegex == "" {
+		return nil, nil
+	}
+	re, err := regexp.Compile(encryptedRegex)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %w", err)
+	}
+	return re, nil
+}
+
+// isEncrypted reports whether a value was encrypted by sealLeaf.
+func isEncrypted(value string) bool {
+	return strings.HasPrefix(value, encryptedPrefix) && strings.HasSuffix(value, encryptedSuffix)
+}
+
+// sealLeaf encrypts a value bound to its path.
+func sealLeaf(envelope *crypto.Envelope, l *leaf) (string, error) {
+	plain, err := json.Marshal(l.value)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := envelope.SealRecord(l.id(), plain)
+	if err != nil {
+		return "", err
+	}
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed) + encryptedSuffix, nil
+}
+
+// openLeaf decrypts a value, it fails if the value was moved to another path.
+func openLeaf(envelope *crypto.Envelope, l *leaf) (leafValue, error) {
+	encoded := strings.TrimSuffix(strings.TrimPrefix(l.value.Value, encryptedPrefix), encryptedSuffix)
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return leafValue{}, fmt.Errorf("invalid encrypted value at %s: %w", l.id(), err)
+	}
+
+	plain, err := envelope.OpenRecord(l.id(), sealed)
+	if err != nil {
+		return leafValue{}, fmt.Errorf("failed to decrypt value at %s: %w", l.id(), err)
+	}
+
+	var value leafValue
+	if err := json.Unmarshal(plain, &value); err != nil {
+		return leafValue{}, fmt.Errorf("failed to decode value at %s: %w", l.id(), err)
+	}
+	return value, nil
+}
+
+// leavesMAC computes the MAC of the plain values and their paths, the style
+// of a value is left out so the file can be reformatted.
+func leavesMAC(envelope *crypto.Envelope, encryptedRegex string, leaves []*leaf) ([]byte, error) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "%d\n%s\n", version, strconv.Quote(encryptedRegex))
+	for _, l := range leaves {
+		fmt.Fprintf(&b, "%s %s %s\n", l.id(), strconv.Quote(l.value.Tag), strconv.Quote(l.value.Value))
+	}
+	return envelope.MAC(b.Bytes())
+}
+
+// fields returns the metadata as stored in the file.
+func (m metadata) fields() map[string]string {
+	return map[string]string{
+		"version":         strconv.Itoa(m.Version),
+		"kek":             m.KEK,
+		"encrypted_regex": m.EncryptedRegex,
+		"mac":             m.MAC,
+	}
+}
+
+// metadataFields is the order metadata fields are written in.
+var metadataFields = []string{"version", "kek", "encrypted_regex", "mac"}
+
+// parseMetadata parses the metadata stored in the file.
+func parseMetadata(fields map[string]string) (metadata, error) {
+	v, err := strconv.Atoi(fields["version"])
+	if err != nil || v > version {
+		return metadata{}, fmt.Errorf("unsupported encrypted file version '%s'", fields["version"])
+	}
+	if fields["kek"] == "" || fields["mac"] == "" {
+		return metadata{}, errors.New("incomplete sectool metadata")
+	}
+
+	return metadata{
+		Version:        v,
+		KEK:            fields["kek"],
+		EncryptedRegex: fields["encrypted_regex"],
+		MAC:            fields["mac"],
+	}, nil
+}
@@ -0,0 +1,180 @@
+package values
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/a13labs/sectool/internal/crypto"
+)
+
+// testKDF keeps the tests fast.
+var testKDF = crypto.KDFParams{Algorithm: crypto.KDFScrypt, LogN: 10, R: 8, P: 1}
+
+const testYAML = `# Application settings
+app:
+  name: demo # the name
+  port: 8080
+  db_password: "s3cr3t: yes"
+  tokens:
+    - abc
+    - 123
+`
+
+const testJSON = `{
+  "name": "demo",
+  "port": 8080,
+  "enabled": true,
+  "db_password": "<s3cr3t>",
+  "nested": {
+    "api_token": 1.5,
+    "empty": []
+  }
+}
+`
+
+const testDotenv = `# Settings
+NAME=demo
+export DB_PASSWORD="s3cr3t value"
+
+API_TOKEN=abc=def
+`
+
+func TestValues_EncryptDecrypt(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	for name, test := range map[string]struct {
+		format    Format
+		data      string
+		regex     string
+		secrets   []string
+		plainText []string
+	}{
+		"yaml":   {FormatYAML, testYAML, "^(db_password|tokens)$", []string{"s3cr3t", "abc", "123"}, []string{"name: demo", "port: 8080", "# the name"}},
+		"json":   {FormatJSON, testJSON, "password|token", []string{"s3cr3t", "1.5"}, []string{`"port": 8080`, `"enabled": true`}},
+		"dotenv": {FormatDotenv, testDotenv, "PASSWORD|TOKEN", []string{"s3cr3t", "abc=def"}, []string{"NAME=demo", "# Settings"}},
+		"all":    {FormatYAML, testYAML, "", []string{"s3cr3t", "demo", "8080"}, []string{"app:", "tokens:"}},
+	} {
+		encrypted, err := Encrypt([]byte(test.data), test.format, key, test.regex, testKDF)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for _, secret := range test.secrets {
+			if strings.Contains(string(encrypted), secret) {
+				t.Errorf("%s: expected '%s' to be encrypted:\n%s", name, secret, encrypted)
+			}
+		}
+		for _, plain := range test.plainText {
+			if !strings.Contains(string(encrypted), plain) {
+				t.Errorf("%s: expected '%s' to be left as is:\n%s", name, plain, encrypted)
+			}
+		}
+		if !IsEncrypted(encrypted, test.format) {
+			t.Errorf("%s: expected metadata", name)
+		}
+
+		decrypted, err := Decrypt(encrypted, test.format, key)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(decrypted) != test.data {
+			t.Errorf("%s: expected\n%s\ngot\n%s", name, test.data, decrypted)
+		}
+
+		if _, err := Decrypt(encrypted, test.format, []byte("wrongkey")); err == nil {
+			t.Errorf("%s: expected error for a wrong key", name)
+		}
+	}
+}
+
+func TestValues_Tampering(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	encrypted, err := Encrypt([]byte(testDotenv), FormatDotenv, key, "PASSWORD|TOKEN", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(string(encrypted), "\n")
+	password, token := lines[2], lines[4]
+
+	tampered := map[string]string{
+		"plain value changed": strings.Replace(string(encrypted), "NAME=demo", "NAME=other", 1),
+		"value removed":       strings.Replace(string(encrypted), token+"\n", "", 1),
+		"value added":         string(encrypted) + "OTHER=1\n",
+		// Encrypted values are bound to their key
+		"values swapped": strings.Replace(strings.Replace(string(encrypted), password, "export DB_PASSWORD="+strings.SplitN(token, "=", 2)[1], 1),
+			token, "API_TOKEN="+strings.SplitN(password, "=", 2)[1], 1),
+	}
+	for name, data := range tampered {
+		if _, err := Decrypt([]byte(data), FormatDotenv, key); err == nil {
+			t.Errorf("Expected error for %s", name)
+		}
+	}
+
+	if _, err := Decrypt([]byte(testDotenv), FormatDotenv, key); err != ErrNotEncrypted {
+		t.Errorf("Expected ErrNotEncrypted, got %v", err)
+	}
+}
+
+func TestValues_EncryptAgain(t *testing.T) {
+	key := []byte("mysecretkey")
+
+	encrypted, err := Encrypt([]byte(testYAML), FormatYAML, key, "password", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A value added to an encrypted file is encrypted, the others are kept
+	edited := strings.Replace(string(encrypted), "  port: 8080\n", "  port: 8080\n  admin_password: hunter2\n", 1)
+	again, err := Encrypt([]byte(edited), FormatYAML, key, "", testKDF)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(again), "hunter2") {
+		t.Errorf("Expected the new value to be encrypted:\n%s", again)
+	}
+	sealed := encrypted[strings.Index(string(encrypted), "ENC["):]
+	sealed = sealed[:strings.Index(string(sealed), "]")]
+	if !strings.Contains(string(again), string(sealed)) {
+		t.Error("Expected the encrypted value to be kept")
+	}
+
+	decrypted, err := Decrypt(again, FormatYAML, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(decrypted), "admin_password: hunter2") || !strings.Contains(string(decrypted), `db_password: "s3cr3t: yes"`) {
+		t.Errorf("Unexpected decrypted file:\n%s", decrypted)
+	}
+
+	if _, err := Encrypt(again, FormatYAML, key, "token", testKDF); err == nil {
+		t.Error("Expected error for a different regex")
+	}
+	// Values changed or removed since aren't signed again
+	tampered := map[string]string{
+		"plain value changed": strings.Replace(string(encrypted), "name: demo", "name: other", 1),
+		"value removed":       strings.Replace(string(encrypted), "  port: 8080\n", "", 1),
+	}
+	for name, data := range tampered {
+		if _, err := Encrypt([]byte(data), FormatYAML, key, "", testKDF); err == nil {
+			t.Errorf("Expected error encrypting again with %s", name)
+		}
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	for path, expected := range map[string]Format{
+		"values.yaml":      FormatYAML,
+		"config/app.YML":   FormatYAML,
+		"package.json":     FormatJSON,
+		".env":             FormatDotenv,
+		".env.production":  FormatDotenv,
+		"production.env":   FormatDotenv,
+		"config/secrets.t": "",
+	} {
+		format, err := FormatFromPath(path)
+		if format != expected || (expected == "") != (err != nil) {
+			t.Errorf("%s: expected '%s', got '%s' (%v)", path, expected, format, err)
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package vault
+
+import (
+	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/ssh"
+)
+
+// AgentVaultKey derives the key of the vault with the given ID from the
+// signature of the configured ssh-agent key. Unless a challenge is configured
+// the signed data includes the vault ID.
+func AgentVaultKey(c *config.SSHAgentConfig, id string) (string, error) {
+	challenge := c.Challenge
+	if challenge == "" {
+		challenge = ssh.VaultChallenge(id)
+	}
+
+	client, conn, err := ssh.DialAgent()
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return ssh.AgentKey(client, c.Key, challenge)
+}
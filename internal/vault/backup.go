@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/a13labs/sectool/internal/config"
+)
+
+// backupTimestampFormat is the timestamp suffix of backup names.
+const backupTimestampFormat = "20060102150405"
+
+// defaultKeepLast is the number of backups kept when no policy is configured.
+const defaultKeepLast = 10
+
+// Backup represents a backup of the vault.
+type Backup struct {
+	Name    string
+	Created time.Time
+}
+
+// RetentionPolicy defines which backups are kept when pruning, a backup is
+// kept if it is one of the KeepLast newest or younger than KeepDays. Zero
+// values disable the respective rule, backups are only pruned if at least
+// one rule is set.
+type RetentionPolicy struct {
+	KeepLast int
+	KeepDays int
+}
+
+// BackupProvider is implemented by providers that keep backups of the vault.
+type BackupProvider interface {
+	VaultListBackups() ([]Backup, error)
+	VaultRestoreBackup(name string) error
+	VaultPruneBackups(policy *RetentionPolicy) ([]string, error)
+}
+
+// backupEnabled returns whether backups are enabled, they are by default.
+func backupEnabled(b *bool) bool {
+	return b == nil || *b
+}
+
+// retentionPolicy converts the retention configuration into a policy.
+func retentionPolicy(c *config.RetentionConfig) RetentionPolicy {
+	if c == nil {
+		return RetentionPolicy{KeepLast: defaultKeepLast}
+	}
+	return RetentionPolicy{KeepLast: c.KeepLast, KeepDays: c.KeepDays}
+}
+
+// backupName generates a backup name with a timestamp.
+func backupName(base string, t time.Time) string {
+	return fmt.Sprintf("%s_%s", base, t.Format(backupTimestampFormat))
+}
+
+// parseBackups converts backup names into backups, newest first, ignoring
+// names that don't carry a valid timestamp.
+func parseBackups(base string, names []string) []Backup {
+	backups := make([]Backup, 0, len(names))
+	for _, name := range names {
+		suffix, found := strings.CutPrefix(name, base+"_")
+		if !found {
+			continue
+		}
+
+		created, err := time.ParseInLocation(backupTimestampFormat, suffix, time.Local)
+		if err != nil {
+			continue
+		}
+
+		backups = append(backups, Backup{Name: name, Created: created})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].Created.After(backups[j].Created)
+	})
+	return backups
+}
+
+// resolveBackup finds a backup by its name or timestamp.
+func resolveBackup(backups []Backup, name string) (Backup, bool) {
+	for _, backup := range backups {
+		if backup.Name == name || strings.HasSuffix(backup.Name, "_"+name) {
+			return backup, true
+		}
+	}
+	return Backup{}, false
+}
+
+// expiredBackups returns the backups that are not retained by the policy.
+func expiredBackups(backups []Backup, policy RetentionPolicy, now time.Time) []Backup {
+	if policy.KeepLast <= 0 && policy.KeepDays <= 0 {
+		return nil
+	}
+
+	cutoff := now.AddDate(0, 0, -policy.KeepDays)
+
+	var expired []Backup
+	for i, backup := range backups {
+		if policy.KeepLast > 0 && i < policy.KeepLast {
+			continue
+		}
+		if policy.KeepDays > 0 && backup.Created.After(cutoff) {
+			continue
+		}
+		expired = append(expired, backup)
+	}
+	return expired
+}
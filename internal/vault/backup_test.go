@@ -0,0 +1,59 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseBackups(t *testing.T) {
+	names := []string{
+		"repository.vault_20240101120000",
+		"repository.vault_20240301120000",
+		"repository.vault_invalid",
+		"repository.vault_20240201120000",
+	}
+
+	backups := parseBackups("repository.vault", names)
+	if len(backups) != 3 {
+		t.Fatalf("Expected 3 backups, got %d", len(backups))
+	}
+
+	if backups[0].Name != "repository.vault_20240301120000" {
+		t.Errorf("Expected newest backup first, got %s", backups[0].Name)
+	}
+
+	backup, ok := resolveBackup(backups, "20240201120000")
+	if !ok || backup.Name != "repository.vault_20240201120000" {
+		t.Errorf("Expected backup to be resolved by timestamp, got %+v", backup)
+	}
+}
+
+func TestExpiredBackups(t *testing.T) {
+	now := time.Date(2024, 3, 10, 0, 0, 0, 0, time.Local)
+	backups := []Backup{
+		{Name: "b1", Created: now.AddDate(0, 0, -1)},
+		{Name: "b2", Created: now.AddDate(0, 0, -5)},
+		{Name: "b3", Created: now.AddDate(0, 0, -10)},
+		{Name: "b4", Created: now.AddDate(0, 0, -20)},
+	}
+
+	expired := expiredBackups(backups, RetentionPolicy{KeepLast: 2}, now)
+	if len(expired) != 2 || expired[0].Name != "b3" {
+		t.Errorf("Expected b3 and b4 to expire, got %+v", expired)
+	}
+
+	expired = expiredBackups(backups, RetentionPolicy{KeepDays: 7}, now)
+	if len(expired) != 2 || expired[0].Name != "b3" {
+		t.Errorf("Expected b3 and b4 to expire, got %+v", expired)
+	}
+
+	// A backup is kept if any rule retains it
+	expired = expiredBackups(backups, RetentionPolicy{KeepLast: 3, KeepDays: 7}, now)
+	if len(expired) != 1 || expired[0].Name != "b4" {
+		t.Errorf("Expected b4 to expire, got %+v", expired)
+	}
+
+	if expired := expiredBackups(backups, RetentionPolicy{}, now); len(expired) != 0 {
+		t.Errorf("Expected no backup to expire without a policy, got %+v", expired)
+	}
+}
@@ -1,15 +1,29 @@
 package vault
 
 import (
+	"encoding/json"
 	"errors"
 	"log"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
 	"github.com/bitwarden/sdk-go"
 )
 
+// bitwardenNote is the note of secrets managed by sectool without metadata.
+const bitwardenNote = "Sectool managed secret"
+
+// bitwardenMetadata is the secret metadata as stored in the note field.
+type bitwardenMetadata struct {
+	Description string     `json:"description,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+	Owner       string     `json:"owner,omitempty"`
+	Expires     *time.Time `json:"expires,omitempty"`
+}
+
 // BitwardenVault represents a Bitwarden vault provider.
 type BitwardenVault struct {
 	VaultProvider
@@ -136,7 +150,7 @@ func (v *BitwardenVault) VaultSetValue(key, value string) error {
 		return err
 	}
 
-	_, err = client.Secrets().Create(key, value, "Sectool managed secret", v.organizationId, []string{v.projectId})
+	_, err = client.Secrets().Create(key, value, bitwardenNote, v.organizationId, []string{v.projectId})
 	if err != nil {
 		return err
 	}
@@ -354,3 +368,180 @@ func (v *BitwardenVault) VaultGetMultipleValues(keys []string, kv *crypto.Secure
 
 	return nil
 }
+
+// findSecret returns the secret of a key that belongs to the project, or nil
+// if it does not exist.
+func (v *BitwardenVault) findSecret(client sdk.BitwardenClientInterface, key string) (*sdk.SecretResponse, error) {
+	secretIdentifiers, err := client.Secrets().List(v.organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, identifier := range secretIdentifiers.Data {
+		if identifier.Key != key {
+			continue
+		}
+
+		secret, err := client.Secrets().Get(identifier.ID)
+		if err != nil {
+			continue
+		}
+
+		if secret.ProjectID == nil || *secret.ProjectID != v.projectId || secret.OrganizationID != v.organizationId {
+			continue
+		}
+
+		return secret, nil
+	}
+
+	return nil, nil
+}
+
+// encodeBitwardenNote stores the metadata in the note field as JSON.
+func encodeBitwardenNote(meta SecretMetadata) string {
+	if meta.IsEmpty() {
+		return bitwardenNote
+	}
+
+	m := bitwardenMetadata{
+		Description: meta.Description,
+		Tags:        meta.Tags,
+		Owner:       meta.Owner,
+	}
+	if !meta.Expires.IsZero() {
+		m.Expires = &meta.Expires
+	}
+
+	note, err := json.Marshal(m)
+	if err != nil {
+		return bitwardenNote
+	}
+	return string(note)
+}
+
+// decodeBitwardenNote reads the metadata from the note field, a note that
+// was not written by sectool is used as the description.
+func decodeBitwardenNote(note string) SecretMetadata {
+	var m bitwardenMetadata
+	if strings.HasPrefix(note, "{") && json.Unmarshal([]byte(note), &m) == nil {
+		meta := SecretMetadata{Description: m.Description, Tags: m.Tags, Owner: m.Owner}
+		if m.Expires != nil {
+			meta.Expires = *m.Expires
+		}
+		return meta
+	}
+
+	if note == bitwardenNote {
+		return SecretMetadata{}
+	}
+	return SecretMetadata{Description: note}
+}
+
+// VaultSetValueWithMetadata sets the value of a key in the Bitwarden vault,
+// keeping the metadata in the note field of the secret.
+func (v *BitwardenVault) VaultSetValueWithMetadata(key, value string, meta SecretMetadata) error {
+	client, err := sdk.NewBitwardenClient(&v.apiURL, &v.identityURL)
+	if err != nil {
+		log.Printf("Error creating Bitwarden client: %v", err)
+		return err
+	}
+	defer client.Close()
+
+	err = client.AccessTokenLogin(v.accessToken, nil)
+	if err != nil {
+		log.Printf("Error logging in with access token: %v", err)
+		return err
+	}
+
+	secret, err := v.findSecret(client, key)
+	if err != nil {
+		return err
+	}
+
+	if secret == nil {
+		_, err = client.Secrets().Create(key, value, encodeBitwardenNote(meta), v.organizationId, []string{v.projectId})
+		return err
+	}
+
+	current := decodeBitwardenNote(secret.Note)
+	if secret.Value != value {
+		current.Expires = time.Time{}
+	}
+	note := encodeBitwardenNote(mergeMetadata(current, meta))
+	_, err = client.Secrets().Update(secret.ID, key, value, note, v.organizationId, []string{v.projectId})
+	return err
+}
+
+// VaultGetMetadata returns the metadata of a key from the Bitwarden vault.
+func (v *BitwardenVault) VaultGetMetadata(key string) (SecretMetadata, error) {
+	client, err := sdk.NewBitwardenClient(&v.apiURL, &v.identityURL)
+	if err != nil {
+		log.Printf("Error creating Bitwarden client: %v", err)
+		return SecretMetadata{}, err
+	}
+	defer client.Close()
+
+	err = client.AccessTokenLogin(v.accessToken, nil)
+	if err != nil {
+		log.Printf("Error logging in with access token: %v", err)
+		return SecretMetadata{}, err
+	}
+
+	secret, err := v.findSecret(client, key)
+	if err != nil {
+		return SecretMetadata{}, err
+	}
+
+	if secret == nil {
+		return SecretMetadata{}, errors.New("key not found in vault")
+	}
+
+	meta := decodeBitwardenNote(secret.Note)
+	meta.Created = secret.CreationDate
+	meta.Updated = secret.RevisionDate
+	return meta, nil
+}
+
+// VaultListMetadata returns the metadata of all keys in the Bitwarden vault.
+func (v *BitwardenVault) VaultListMetadata() (map[string]SecretMetadata, error) {
+	client, err := sdk.NewBitwardenClient(&v.apiURL, &v.identityURL)
+	if err != nil {
+		log.Printf("Error creating Bitwarden client: %v", err)
+		return nil, err
+	}
+	defer client.Close()
+
+	err = client.AccessTokenLogin(v.accessToken, nil)
+	if err != nil {
+		log.Printf("Error logging in with access token: %v", err)
+		return nil, err
+	}
+
+	secretIdentifiers, err := client.Secrets().List(v.organizationId)
+	if err != nil {
+		return nil, err
+	}
+
+	metadata := make(map[string]SecretMetadata, len(secretIdentifiers.Data))
+	for _, identifier := range secretIdentifiers.Data {
+		if identifier.OrganizationID != v.organizationId {
+			continue
+		}
+
+		secret, err := client.Secrets().Get(identifier.ID)
+		if err != nil {
+			continue
+		}
+
+		if secret.ProjectID == nil || *secret.ProjectID != v.projectId {
+			continue
+		}
+
+		meta := decodeBitwardenNote(secret.Note)
+		meta.Created = secret.CreationDate
+		meta.Updated = secret.RevisionDate
+		metadata[identifier.Key] = meta
+	}
+
+	return metadata, nil
+}
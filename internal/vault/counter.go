@@ -0,0 +1,116 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/a13labs/sectool/internal/fsutil"
+)
+
+// ErrVaultRollback is returned when a vault is older than the last one seen,
+// it may have been replaced by an old copy such as a backup.
+var ErrVaultRollback = errors.New("the vault is older than the last one seen, it may have been rolled back")
+
+// counterFile returns the file recording the highest counter seen of every
+// vault, SECTOOL_COUNTER_FILE overrides it. It is kept in the user
+// configuration so it isn't rolled back along with a vault, rollback
+// protection is disabled if there is none.
+func counterFile() string {
+	if path := os.Getenv("SECTOOL_COUNTER_FILE"); path != "" {
+		return path
+	}
+
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "sectool", "counters.json")
+}
+
+// readCounters returns the highest counter seen of every vault.
+func readCounters(path string) (map[string]uint64, error) {
+	counters := map[string]uint64{}
+
+	contents, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return counters, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(contents, &counters); err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", path, err)
+	}
+	return counters, nil
+}
+
+// seenCounter returns the highest counter seen of the vault at location.
+func seenCounter(location string) (uint64, error) {
+	path := counterFile()
+	if path == "" {
+		return 0, nil
+	}
+
+	counters, err := readCounters(path)
+	if err != nil {
+		return 0, err
+	}
+	return counters[location], nil
+}
+
+// recordCounter records the counter of the vault at location, it only moves
+// forward unless reset is set.
+func recordCounter(location string, counter uint64, reset bool) error {
+	path := counterFile()
+	if path == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	l, err := fsutil.LockFile(path+".lock", true, defaultLockTimeout)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	counters, err := readCounters(path)
+	if err != nil {
+		return err
+	}
+	if counters[location] == counter || (counters[location] > counter && !reset) {
+		return nil
+	}
+	counters[location] = counter
+
+	contents, err := json.MarshalIndent(counters, "", "  ")
+	if err != nil {
+		return err
+	}
+	return fsutil.WriteFileAtomic(path, contents, fsutil.DefaultFileMode)
+}
+
+// checkCounter fails with ErrVaultRollback if the counter of the vault at
+// location is lower than the highest one seen, and records it otherwise.
+// SECTOOL_ALLOW_ROLLBACK accepts an older vault, e.g. after checking out an
+// older commit of a vault kept in git.
+func checkCounter(location string, counter uint64) error {
+	seen, err := seenCounter(location)
+	if err != nil {
+		return err
+	}
+
+	if counter < seen {
+		if os.Getenv("SECTOOL_ALLOW_ROLLBACK") == "" {
+			return fmt.Errorf("%w (counter %d, last seen %d), set SECTOOL_ALLOW_ROLLBACK=1 to accept it", ErrVaultRollback, counter, seen)
+		}
+		return recordCounter(location, counter, true)
+	}
+
+	return recordCounter(location, counter, false)
+}
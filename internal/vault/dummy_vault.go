@@ -3,6 +3,7 @@ package vault
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/a13labs/sectool/internal/crypto"
 )
@@ -10,6 +11,7 @@ import (
 type DummyVault struct {
 	VaultProvider
 	data   map[string]string
+	meta   map[string]SecretMetadata
 	mu     sync.RWMutex
 	backup bool
 }
@@ -17,6 +19,7 @@ type DummyVault struct {
 func NewDummyVault() *DummyVault {
 	return &DummyVault{
 		data: make(map[string]string),
+		meta: make(map[string]SecretMetadata),
 	}
 }
 
@@ -24,6 +27,7 @@ func (v *DummyVault) Initialize() error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
 	v.data = make(map[string]string)
+	v.meta = make(map[string]SecretMetadata)
 	return nil
 }
 
@@ -55,12 +59,47 @@ func (v *DummyVault) VaultListKeys() []string {
 }
 
 func (v *DummyVault) VaultSetValue(key, value string) error {
+	return v.VaultSetValueWithMetadata(key, value, SecretMetadata{})
+}
+
+func (v *DummyVault) VaultSetValueWithMetadata(key, value string, meta SecretMetadata) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
+	now := time.Now().UTC()
+	current, exists := v.meta[key]
+	if !exists {
+		current = SecretMetadata{Created: now}
+	}
+	if v.data[key] != value {
+		current.Expires = time.Time{}
+	}
+	current = mergeMetadata(current, meta)
+	current.Updated = now
 	v.data[key] = value
+	v.meta[key] = current
 	return nil
 }
 
+func (v *DummyVault) VaultGetMetadata(key string) (SecretMetadata, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	meta, exists := v.meta[key]
+	if !exists {
+		return SecretMetadata{}, errors.New("key not found in vault")
+	}
+	return meta, nil
+}
+
+func (v *DummyVault) VaultListMetadata() (map[string]SecretMetadata, error) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	metadata := make(map[string]SecretMetadata, len(v.meta))
+	for key, meta := range v.meta {
+		metadata[key] = meta
+	}
+	return metadata, nil
+}
+
 func (v *DummyVault) VaultDelKey(key string) error {
 	v.mu.Lock()
 	defer v.mu.Unlock()
@@ -68,6 +107,7 @@ func (v *DummyVault) VaultDelKey(key string) error {
 		return errors.New("key not found in vault")
 	}
 	delete(v.data, key)
+	delete(v.meta, key)
 	return nil
 }
 
@@ -121,3 +121,25 @@ func TestDummyVault_VaultGetMultipleValues(t *testing.T) {
 		t.Fatalf("Expected value 'value2', got %v", value)
 	}
 }
+
+func TestDummyVault_Metadata(t *testing.T) {
+	vault := NewDummyVault()
+	vault.VaultSetValueWithMetadata("testKey", "testValue", SecretMetadata{Description: "test", Tags: []string{"a"}})
+	vault.VaultSetValue("testKey", "newValue")
+
+	meta, err := vault.VaultGetMetadata("testKey")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if meta.Description != "test" || len(meta.Tags) != 1 {
+		t.Fatalf("Expected metadata to be kept, got %+v", meta)
+	}
+	if meta.Created.IsZero() || meta.Updated.IsZero() {
+		t.Fatalf("Expected timestamps to be set, got %+v", meta)
+	}
+
+	vault.VaultDelKey("testKey")
+	if _, err := vault.VaultGetMetadata("testKey"); err == nil {
+		t.Fatal("Expected error for deleted key")
+	}
+}
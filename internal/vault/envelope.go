@@ -0,0 +1,268 @@
+package vault
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"filippo.io/age"
+	"github.com/a13labs/sectool/internal/crypto"
+)
+
+// vaultContext returns the associated data the ciphertexts of a vault are
+// bound to, so a vault copied over another one with the same key fails to
+// open.
+func vaultContext(id string) []byte {
+	return crypto.AssociatedData("sectool-vault", id)
+}
+
+// fingerprintKeySize is the size of the key the fingerprints of the values
+// are computed with.
+const fingerprintKeySize = 32
+
+// decryptVault decrypts and decodes the vault contents, in either format,
+// with the vault key or the identities, opening the sealed values. Vaults written before envelope
+// encryption get a new envelope and are upgraded on the next write.
+func decryptVault(data []byte, ad []byte, key []byte, identities []age.Identity) (*vaultDocument, error) {
+	if isGitVault(data) {
+		doc, err := decryptGitVault(data, ad, key, identities)
+		if errors.Is(err, crypto.ErrNoContext) {
+			return nil, fmt.Errorf("%w, vault migrate binds it to the vault", err)
+		}
+		return doc, err
+	}
+
+	contents, envelope, counter, err := crypto.DecryptEnvelopeWithContext(string(data), key, ad, identities...)
+	if errors.Is(err, crypto.ErrNoContext) {
+		return nil, fmt.Errorf("%w, vault migrate binds it to the vault", err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseVault(contents)
+	if err != nil {
+		envelope.Close()
+		return nil, err
+	}
+
+	if err := doc.openValues(envelope); err != nil {
+		envelope.Close()
+		return nil, err
+	}
+	doc.counter = counter
+
+	return doc, nil
+}
+
+// decryptUnboundVault decrypts vault contents written before they were bound
+// to the vault context, it returns nil if they already are.
+func decryptUnboundVault(data []byte, ad []byte, key []byte, identities []age.Identity) (*vaultDocument, error) {
+	doc, err := decryptVault(data, ad, key, identities)
+	if err == nil {
+		doc.close()
+		return nil, nil
+	}
+	if !errors.Is(err, crypto.ErrNoContext) {
+		return nil, err
+	}
+	return decryptVault(data, nil, key, identities)
+}
+
+// encryptVault encodes and encrypts the vault contents, to its members if it
+// has any or with the vault key otherwise. The counter of the document is
+// increased, it is stored in the header.
+func encryptVault(doc *vaultDocument, ad []byte, key []byte, kdf crypto.KDFParams) ([]byte, error) {
+	if doc.envelope == nil {
+		return nil, errors.New("vault has no key-encryption key")
+	}
+	if err := doc.newFingerprintKey(); err != nil {
+		return nil, err
+	}
+
+	contents, err := doc.encode()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(doc.Recipients) > 0 {
+		recipients, err := parseRecipients(doc.Recipients)
+		if err != nil {
+			return nil, err
+		}
+		doc.counter++
+		return doc.envelope.EncryptForRecipientsWithContext(contents, recipients, ad, doc.counter)
+	}
+
+	if len(key) == 0 {
+		return nil, errors.New("vault key is not defined")
+	}
+	doc.counter++
+	return doc.envelope.EncryptWithContext(contents, key, kdf, ad, doc.counter)
+}
+
+// openValues decrypts the sealed values with the envelope, keeping the sealed
+// form so unchanged values are not sealed again. A new envelope is created
+// if none is given, it is closed if the values fail to open.
+func (d *vaultDocument) openValues(envelope *crypto.Envelope) error {
+	if envelope == nil {
+		var err error
+		envelope, err = crypto.NewEnvelope()
+		if err != nil {
+			return err
+		}
+		if err := d.openValues(envelope); err != nil {
+			envelope.Close()
+			return err
+		}
+		return nil
+	}
+
+	for i := range d.Entries {
+		entry := &d.Entries[i]
+		if err := openValue(envelope, entry.DataKey, &entry.Value, &entry.sealed); err != nil {
+			return fmt.Errorf("failed to open '%s': %w", entry.Key, err)
+		}
+
+		for j := range entry.History {
+			previous := &entry.History[j]
+			if err := openValue(envelope, previous.DataKey, &previous.Value, &previous.sealed); err != nil {
+				return fmt.Errorf("failed to open '%s' version %d: %w", entry.Key, previous.Version, err)
+			}
+		}
+	}
+
+	d.envelope = envelope
+	return nil
+}
+
+// close wipes the key-encryption key of the document, it can't be encrypted
+// afterwards. Closing a document without an envelope does nothing.
+func (d *vaultDocument) close() {
+	if d == nil {
+		return
+	}
+	d.envelope.Close()
+	d.envelope = nil
+}
+
+// openValue replaces a sealed value with its plain text, values without a
+// data key are stored in plain text.
+func openValue(envelope *crypto.Envelope, dataKey []byte, value *[]byte, sealed *[]byte) error {
+	if len(dataKey) == 0 {
+		return nil
+	}
+
+	plainData, err := envelope.Open(dataKey, *value)
+	if err != nil {
+		return err
+	}
+
+	*sealed = *value
+	*value = plainData
+	return nil
+}
+
+// sealedEntries returns a copy of the entries as stored, sealing the values
+// that changed with new data keys. Values are left in plain text if the
+// document has no envelope.
+func (d *vaultDocument) sealedEntries() ([]vaultEntry, error) {
+	entries := make([]vaultEntry, len(d.Entries))
+	for i := range d.Entries {
+		entry := &d.Entries[i]
+
+		if d.envelope != nil && entry.sealed == nil {
+			dataKey, sealed, err := d.envelope.Seal(fmt.Sprintf("%s#%d", entry.Key, entry.Version), entry.Value)
+			if err != nil {
+				return nil, err
+			}
+			entry.DataKey, entry.sealed = dataKey, sealed
+		}
+
+		stored := *entry
+		stored.History = make([]vaultVersion, len(entry.History))
+		for j := range entry.History {
+			previous := &entry.History[j]
+
+			if d.envelope != nil && previous.sealed == nil {
+				dataKey, sealed, err := d.envelope.Seal(fmt.Sprintf("%s#%d", entry.Key, previous.Version), previous.Value)
+				if err != nil {
+					return nil, err
+				}
+				previous.DataKey, previous.sealed = dataKey, sealed
+			}
+
+			stored.History[j] = *previous
+			if d.envelope == nil {
+				stored.History[j].DataKey = nil
+			} else {
+				stored.History[j].Value = previous.sealed
+			}
+		}
+
+		if d.envelope == nil {
+			stored.DataKey = nil
+		} else {
+			stored.Value = entry.sealed
+		}
+		if len(stored.History) == 0 {
+			stored.History = nil
+		}
+		entries[i] = stored
+	}
+
+	return entries, nil
+}
+
+// rotate moves the document to a new key-encryption key, re-wrapping the
+// data keys without sealing the values again.
+func (d *vaultDocument) rotate() error {
+	if d.envelope == nil {
+		return errors.New("vault has no key-encryption key")
+	}
+
+	envelope, err := crypto.NewEnvelope()
+	if err != nil {
+		return err
+	}
+
+	for i := range d.Entries {
+		entry := &d.Entries[i]
+		entry.line = nil
+		if entry.sealed != nil {
+			if entry.DataKey, err = d.envelope.Rewrap(entry.DataKey, envelope); err != nil {
+				envelope.Close()
+				return err
+			}
+		}
+
+		for j := range entry.History {
+			previous := &entry.History[j]
+			if previous.sealed != nil {
+				if previous.DataKey, err = d.envelope.Rewrap(previous.DataKey, envelope); err != nil {
+					envelope.Close()
+					return err
+				}
+			}
+		}
+	}
+
+	d.envelope.Close()
+	d.envelope = envelope
+	d.wrappedKEK, d.sealedFingerprintKey = nil, nil
+	return nil
+}
+
+// newFingerprintKey creates the fingerprint key of documents written before
+// it existed.
+func (d *vaultDocument) newFingerprintKey() error {
+	if d.FingerprintKey != nil {
+		return nil
+	}
+	key := make([]byte, fingerprintKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	d.FingerprintKey = key
+	return nil
+}
@@ -4,19 +4,59 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/fsutil"
 )
 
+// defaultLockTimeout is how long an operation waits for a busy vault.
+const defaultLockTimeout = 10 * time.Second
+
+// ErrVaultBusy is returned when the vault is locked by another process.
+var ErrVaultBusy = errors.New("vault busy, another operation is in progress")
+
 // FileVault represents a secure key-value store.
 type FileVault struct {
 	VaultProvider
-	path   string
-	key    []byte
-	backup bool
+	path        string
+	location    string
+	context     []byte
+	key         []byte
+	identities  []age.Identity
+	format      string
+	kdf         crypto.KDFParams
+	backup      bool
+	retention   RetentionPolicy
+	lockTimeout time.Duration
+	history     int
+}
+
+// fileVaultPath returns the configured vault path, the FILE_VAULT_PATH
+// environment variable or repository.vault.
+func fileVaultPath(c *config.FileConfig) string {
+	path := c.Path
+	if path == "" {
+		path, _ = os.LookupEnv("FILE_VAULT_PATH")
+		if path == "" {
+			path = "repository.vault"
+		}
+	}
+	return path
+}
+
+// fileVaultID returns the ID the vault is bound to, its file name unless
+// configured.
+func fileVaultID(c *config.FileConfig) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return filepath.Base(fileVaultPath(c))
 }
 
 // NewVault creates a new FileVault instance.
@@ -27,50 +67,142 @@ func NewFileVault(config *config.FileConfig) (*FileVault, error) {
 		return nil, errors.New("file configuration is nil")
 	}
 
+	identities, err := loadIdentities(identityFile(config.Identity))
+	if err != nil {
+		return nil, err
+	}
+
+	// Members of a vault unlock it with their identity instead of the key
 	key := config.Key
+	if key == "" && config.SSHAgent != nil {
+		key, err = AgentVaultKey(config.SSHAgent, fileVaultID(config))
+		if err != nil {
+			return nil, err
+		}
+	}
 	if key == "" {
 		key, _ = os.LookupEnv("FILE_VAULT_KEY")
-		if key == "" {
+		if key == "" && len(identities) == 0 {
 			fmt.Println("FILE_VAULT_KEY it's not defined, aborting.")
 			return nil, errors.New("file vault key is not defined")
 		}
 	}
 
-	path := config.Path
-	if path == "" {
-		path, _ := os.LookupEnv("FILE_VAULT_PATH")
-		if path == "" {
-			path = "repository.vault"
+	path := fileVaultPath(config)
+
+	format, err := vaultFormat(config.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	lockTimeout := defaultLockTimeout
+	timeout := config.LockTimeout
+	if timeout == "" {
+		timeout, _ = os.LookupEnv("FILE_VAULT_LOCK_TIMEOUT")
+	}
+	if timeout != "" {
+		var err error
+		lockTimeout, err = time.ParseDuration(timeout)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lock timeout: %w", err)
 		}
 	}
 
+	kdf, err := kdfParams(config.KDF)
+	if err != nil {
+		return nil, err
+	}
+
+	location, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
 	return &FileVault{
-		path:   path,
-		key:    []byte(key),
-		backup: false,
+		path:        path,
+		location:    location,
+		context:     vaultContext(fileVaultID(config)),
+		key:         []byte(key),
+		identities:  identities,
+		format:      format,
+		kdf:         kdf,
+		backup:      backupEnabled(config.Backup),
+		retention:   retentionPolicy(config.Retention),
+		lockTimeout: lockTimeout,
+		history:     historySize(config.History),
 	}, nil
 }
 
-// readVault reads the vault file contents.
-func (v *FileVault) readVault() (string, error) {
+// lock acquires the advisory lock of the vault, exclusive for writers and
+// shared for readers, once an expired working copy is locked again.
+func (v *FileVault) lock(exclusive bool) (*fsutil.FileLock, error) {
+	if err := v.lockExpired(); err != nil {
+		return nil, err
+	}
+	return v.acquire(exclusive)
+}
+
+// acquire acquires the advisory lock of the vault.
+func (v *FileVault) acquire(exclusive bool) (*fsutil.FileLock, error) {
+	l, err := fsutil.LockFile(v.path+".lock", exclusive, v.lockTimeout)
+	if errors.Is(err, fsutil.ErrLockTimeout) {
+		return nil, ErrVaultBusy
+	}
+	return l, err
+}
+
+// readVault reads and decodes the vault file contents, or the working copy
+// while the vault is unlocked.
+func (v *FileVault) readVault() (*vaultDocument, error) {
+	if doc, err := v.readWorkingCopy(); doc != nil || err != nil {
+		return doc, err
+	}
+
 	if !v.vaultFileExists() {
-		f, err := os.Create(v.path)
+		err := fsutil.WriteFileAtomic(v.path, []byte(""), fsutil.DefaultFileMode)
 		if err != nil {
-			return "", errors.New("vault file does not exist")
+			return nil, errors.New("vault file does not exist")
 		}
-		f.Close()
 	}
 
-	decryptedContents, err := crypto.DecryptFromFile(v.path, v.key)
+	doc, _, err := v.readLiveVault()
+	return doc, err
+}
+
+// readLiveVault reads and decodes the vault file, failing with
+// ErrVaultRollback if it is older than the last one seen.
+func (v *FileVault) readLiveVault() (*vaultDocument, []byte, error) {
+	contents, err := os.ReadFile(v.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, nil, err
+	}
+
+	doc, err := decryptVault(contents, v.context, v.key, v.identities)
 	if err != nil {
-		return "", err
+		return nil, nil, err
 	}
 
-	return decryptedContents, nil
+	// An empty vault is a new one, its counter continues from the last seen
+	if len(contents) > 0 {
+		if err := checkCounter(v.location, doc.counter); err != nil {
+			doc.close()
+			return nil, nil, err
+		}
+	}
+	return doc, contents, nil
 }
 
-// writeVault writes encrypted data to the vault file.
-func (v *FileVault) writeVault(contents string) error {
+// writeVault encodes and writes encrypted data to the vault file, the
+// working copy of an unlocked vault is written in plain text instead.
+func (v *FileVault) writeVault(doc *vaultDocument) error {
+
+	if doc.unlocked() {
+		contents, err := doc.encode()
+		if err != nil {
+			return err
+		}
+		return fsutil.WriteFileAtomic(v.workingCopyPath(), []byte(contents), fsutil.DefaultFileMode)
+	}
 
 	if v.backup {
 		// Create a backup of the existing vault
@@ -79,25 +211,64 @@ func (v *FileVault) writeVault(contents string) error {
 		if err != nil {
 			return err
 		}
+
+		_, err = v.pruneBackups(v.retention)
+		if err != nil {
+			return err
+		}
+	}
+
+	// The counter never goes back, even if the vault was replaced
+	seen, err := seenCounter(v.location)
+	if err != nil {
+		return err
 	}
+	doc.counter = max(doc.counter, seen)
 
 	// Encrypt the data and write to the vault file
-	err := crypto.EncryptToFile(contents, v.path, v.key, false)
+	encryptedData, err := v.encryptVault(doc, v.key)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = fsutil.WriteFileAtomic(v.path, encryptedData, fsutil.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	return recordCounter(v.location, doc.counter, false)
 }
 
-// backupVault creates a backup of the vault file.
+// vaultFormat validates the configured vault format, binary by default.
+func vaultFormat(format string) (string, error) {
+	switch format {
+	case "":
+		return config.VaultFormatBinary, nil
+	case config.VaultFormatBinary, config.VaultFormatGit:
+		return format, nil
+	}
+	return "", fmt.Errorf("unknown vault format '%s'", format)
+}
+
+// encryptVault encrypts the vault contents in the configured format.
+func (v *FileVault) encryptVault(doc *vaultDocument, key []byte) ([]byte, error) {
+	if v.format == config.VaultFormatGit {
+		return encryptGitVault(doc, v.context, key, v.kdf)
+	}
+	return encryptVault(doc, v.context, key, v.kdf)
+}
+
+// backupVault creates a backup of the vault file, empty vaults are skipped.
 func (v *FileVault) backupVault(backupName string) error {
 	contents, err := os.ReadFile(v.path)
+	if os.IsNotExist(err) || (err == nil && len(contents) == 0) {
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
-	err = os.WriteFile(backupName, contents, 0600)
+	err = fsutil.WriteFileAtomic(backupName, contents, fsutil.DefaultFileMode)
 	if err != nil {
 		return err
 	}
@@ -105,10 +276,46 @@ func (v *FileVault) backupVault(backupName string) error {
 	return nil
 }
 
-// vaultBackupName generates a backup filename with a timestamp.
+// vaultBackupName generates a backup filename with a timestamp, moving to
+// the next second if a backup was already taken in the current one.
 func (v *FileVault) vaultBackupName() string {
-	timestamp := time.Now().Format("20060102150405")
-	return fmt.Sprintf("%s_%s", v.path, timestamp)
+	t := time.Now()
+	name := backupName(v.path, t)
+	for {
+		if _, err := os.Stat(name); os.IsNotExist(err) {
+			return name
+		}
+		t = t.Add(time.Second)
+		name = backupName(v.path, t)
+	}
+}
+
+// listBackups returns the backup files of the vault, oldest first.
+func (v *FileVault) listBackups() ([]string, error) {
+	matches, err := filepath.Glob(v.path + "_" + strings.Repeat("[0-9]", 14))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// pruneBackups removes the backups not retained by the policy.
+func (v *FileVault) pruneBackups(policy RetentionPolicy) ([]string, error) {
+	names, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, backup := range expiredBackups(parseBackups(v.path, names), policy, time.Now()) {
+		if err := os.Remove(backup.Name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup.Name)
+	}
+
+	return removed, nil
 }
 
 // vaultFileExists checks if the vault file exists.
@@ -124,7 +331,7 @@ func (v *FileVault) Initialize() error {
 	}
 
 	// Create an empty vault file
-	err := os.WriteFile(v.path, []byte(""), 0600)
+	err := fsutil.WriteFileAtomic(v.path, []byte(""), fsutil.DefaultFileMode)
 	if err != nil {
 		return err
 	}
@@ -134,108 +341,321 @@ func (v *FileVault) Initialize() error {
 
 // VaultHasKey checks if the vault contains the specified key.
 func (v *FileVault) VaultHasKey(key string) bool {
-	contents, err := v.readVault()
+	l, err := v.lock(false)
 	if err != nil {
 		return false
 	}
+	defer l.Unlock()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			return true
-		}
+	doc, err := v.readVault()
+	if err != nil {
+		return false
 	}
+	defer doc.close()
 
-	return false
+	return doc.has(key)
 }
 
 // VaultGetValue returns the value of a key from the vault.
 func (v *FileVault) VaultGetValue(key string) (string, error) {
-	contents, err := v.readVault()
+	l, err := v.lock(false)
 	if err != nil {
 		return "", err
 	}
+	defer l.Unlock()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			return parts[1], nil
-		}
+	doc, err := v.readVault()
+	if err != nil {
+		return "", err
+	}
+	defer doc.close()
+
+	value, ok := doc.get(key)
+	if !ok {
+		return "", errors.New("key not found in vault")
 	}
 
-	return "", errors.New("key not found in vault")
+	return value, nil
 }
 
 // VaultListKeys lists all keys in the vault.
 func (v *FileVault) VaultListKeys() []string {
-	contents, err := v.readVault()
+	l, err := v.lock(false)
 	if err != nil {
 		return []string{}
 	}
+	defer l.Unlock()
 
-	var keys []string
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			keys = append(keys, parts[0])
-		}
+	doc, err := v.readVault()
+	if err != nil {
+		return []string{}
 	}
+	defer doc.close()
 
-	return keys
+	return doc.keys()
 }
 
 // VaultSetValue sets the value of a key in the vault.
 func (v *FileVault) VaultSetValue(key, value string) error {
-	contents, err := v.readVault()
+	l, err := v.lock(true)
 	if err != nil {
 		return err
 	}
+	defer l.Unlock()
 
-	lines := strings.Split(contents, "\n")
-	for i, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			// Key already exists, update the value
-			lines[i] = fmt.Sprintf("%s=%s", key, value)
-			updatedContents := strings.Join(lines, "\n")
-			return v.writeVault(updatedContents)
-		}
+	doc, err := v.readVault()
+	if err != nil {
+		return err
 	}
+	defer doc.close()
 
-	// Key doesn't exist, add a new key-value pair
-	lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-	updatedContents := strings.Join(lines, "\n")
-	return v.writeVault(updatedContents)
+	doc.set(key, value, v.history)
+	return v.writeVault(doc)
+}
+
+// VaultSetValueWithMetadata sets the value and metadata of a key in the vault.
+func (v *FileVault) VaultSetValueWithMetadata(key, value string, meta SecretMetadata) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	doc.set(key, value, v.history)
+	doc.setMetadata(key, meta)
+	return v.writeVault(doc)
+}
+
+// VaultGetMetadata returns the metadata of a key in the vault.
+func (v *FileVault) VaultGetMetadata(key string) (SecretMetadata, error) {
+	l, err := v.lock(false)
+	if err != nil {
+		return SecretMetadata{}, err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return SecretMetadata{}, err
+	}
+	defer doc.close()
+
+	meta, ok := doc.metadata(key)
+	if !ok {
+		return SecretMetadata{}, errors.New("key not found in vault")
+	}
+
+	return meta, nil
+}
+
+// VaultListMetadata returns the metadata of all keys in the vault.
+func (v *FileVault) VaultListMetadata() (map[string]SecretMetadata, error) {
+	l, err := v.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	return doc.listMetadata(), nil
 }
 
 // VaultDelKey deletes a key from the vault.
 func (v *FileVault) VaultDelKey(key string) error {
-	contents, err := v.readVault()
+	l, err := v.lock(true)
 	if err != nil {
 		return err
 	}
+	defer l.Unlock()
 
-	var updatedLines []string
-	lines := strings.Split(contents, "\n")
-	keyFound := false
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			keyFound = true
-			continue
-		}
-		updatedLines = append(updatedLines, line)
+	doc, err := v.readVault()
+	if err != nil {
+		return err
 	}
+	defer doc.close()
 
-	if !keyFound {
+	if !doc.del(key) {
 		return errors.New("key not found in vault")
 	}
 
-	updatedContents := strings.Join(updatedLines, "\n")
-	return v.writeVault(updatedContents)
+	return v.writeVault(doc)
+}
+
+// VaultRenameKeys renames keys in the vault in a single write.
+func (v *FileVault) VaultRenameKeys(renames map[string]string) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.rename(renames); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultListMembers returns the recipients the vault is encrypted to.
+func (v *FileVault) VaultListMembers() ([]string, error) {
+	l, err := v.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	return doc.Recipients, nil
+}
+
+// VaultAddMembers encrypts the vault to additional recipients.
+func (v *FileVault) VaultAddMembers(recipients []string) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.addMembers(recipients, v.identities); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultRemoveMembers removes recipients from the vault, the key-encryption
+// key is rotated so removed members can't unwrap new data keys.
+func (v *FileVault) VaultRemoveMembers(recipients []string) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.removeMembers(recipients); err != nil {
+		return err
+	}
+
+	// A working copy is rotated when it is locked
+	if !doc.unlocked() {
+		if err := doc.rotate(); err != nil {
+			return err
+		}
+	}
+
+	return v.writeVault(doc)
+}
+
+// readVaultFile reads and decodes another copy of the vault, such as the
+// versions git hands to its drivers.
+func (v *FileVault) readVaultFile(path string) (*vaultDocument, []byte, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := decryptVault(contents, v.context, v.key, v.identities)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to decrypt '%s': %w", path, err)
+	}
+	return doc, contents, nil
+}
+
+// VaultTextConv returns the text representation of a copy of the vault used
+// by git to diff it.
+func (v *FileVault) VaultTextConv(path string) (string, error) {
+	doc, _, err := v.readVaultFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer doc.close()
+
+	return doc.textconv()
+}
+
+// VaultMerge merges the changes made in theirs since base into ours, keeping
+// the format of ours. Ours is only written if there are no conflicts, the
+// conflicting keys are returned.
+func (v *FileVault) VaultMerge(base, ours, theirs string) ([]string, error) {
+	baseDoc, _, err := v.readVaultFile(base)
+	if err != nil {
+		return nil, err
+	}
+	defer baseDoc.close()
+	oursDoc, contents, err := v.readVaultFile(ours)
+	if err != nil {
+		return nil, err
+	}
+	defer oursDoc.close()
+	theirsDoc, _, err := v.readVaultFile(theirs)
+	if err != nil {
+		return nil, err
+	}
+	defer theirsDoc.close()
+
+	conflicts, err := oursDoc.merge(baseDoc, theirsDoc)
+	if err != nil || len(conflicts) > 0 {
+		return conflicts, err
+	}
+
+	var merged []byte
+	if isGitVault(contents) {
+		merged, err = encryptGitVault(oursDoc, v.context, v.key, v.kdf)
+	} else {
+		merged, err = encryptVault(oursDoc, v.context, v.key, v.kdf)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return nil, fsutil.WriteFileAtomic(ours, merged, fsutil.DefaultFileMode)
+}
+
+// VaultVersion returns the size and modification time of the vault file, or
+// of the working copy while unlocked, they change on every write.
+func (v *FileVault) VaultVersion() (string, error) {
+	info, err := os.Stat(v.workingCopyPath())
+	if os.IsNotExist(err) {
+		info, err = os.Stat(v.path)
+	}
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%d-%d", info.Size(), info.ModTime().UnixNano()), nil
 }
 
 // VaultEnableBackup enables or disables vault backups.
@@ -245,67 +665,481 @@ func (v *FileVault) VaultEnableBackup(value bool) {
 
 // GetSensitiveStrings returns the sensitive strings in the vault.
 func (v *FileVault) SetSensitiveStrings(kv *crypto.SecureKVStore) {
-	kv.Put("SECTOOL_FV_SENSITIVE_1", string(v.key))
+	if len(v.key) > 0 {
+		kv.PutBytes("SECTOOL_FV_SENSITIVE_1", v.key)
+	}
 }
 
-// Lock encrypts the vault file.
-func (v *FileVault) Lock() error {
-
-	unlocked_vault := v.path + ".unlocked"
-	locked_vault := v.path
+// workingCopyPath returns the plain text working copy of the vault, used
+// instead of the vault while it is unlocked.
+func (v *FileVault) workingCopyPath() string {
+	return v.path + ".unlocked"
+}
 
-	_, err := os.Stat(unlocked_vault)
+// readWorkingCopy reads the working copy of the vault, or returns nil if the
+// vault is locked. A working copy left by older versions has no expiry, it
+// is expired.
+func (v *FileVault) readWorkingCopy() (*vaultDocument, error) {
+	contents, err := os.ReadFile(v.workingCopyPath())
 	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := parseVault(string(contents))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read '%s': %w", v.workingCopyPath(), err)
+	}
+	if doc.UnlockedUntil == nil {
+		doc.UnlockedUntil = &time.Time{}
+	}
+	return doc, nil
+}
+
+// lockExpired locks the working copy again once it expired, it fails if the
+// vault changed since it was unlocked as those changes would be lost. It is
+// only run by the next operation, the working copy stays on disk until then.
+func (v *FileVault) lockExpired() error {
+	if _, err := os.Stat(v.workingCopyPath()); err != nil {
 		return nil
 	}
 
-	err = crypto.EncryptFile(unlocked_vault, locked_vault, []byte(v.key))
+	l, err := v.acquire(true)
 	if err != nil {
 		return err
 	}
+	defer l.Unlock()
 
-	os.Remove(unlocked_vault)
+	wc, err := v.readWorkingCopy()
+	if wc == nil || err != nil {
+		return err
+	}
+	if !wc.expired(time.Now()) {
+		return nil
+	}
 
+	if err := v.lockWorkingCopy(wc, false); err != nil {
+		return fmt.Errorf("the unlocked vault expired but can't be locked: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "The unlocked vault expired, '%s' was locked again.\n", v.workingCopyPath())
 	return nil
 }
 
-// Unlock decrypts the vault file.
-func (v *FileVault) Unlock() error {
+// lockWorkingCopy writes the changes of the working copy to the vault and
+// removes it, the caller holds the exclusive lock. Unless forced it fails if
+// the vault changed since it was unlocked.
+func (v *FileVault) lockWorkingCopy(wc *vaultDocument, force bool) error {
+	doc, contents, err := v.readLiveVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+	if !force && wc.stale(contents) {
+		return ErrStaleWorkingCopy
+	}
+	if err := doc.applyWorkingCopy(wc); err != nil {
+		return err
+	}
+	if err := v.writeVault(doc); err != nil {
+		return err
+	}
+
+	return os.Remove(v.workingCopyPath())
+}
+
+// VaultUnlockFor decrypts the vault to a plain text working copy used by the
+// following reads and writes, until it is locked or expires. Unlocking an
+// unlocked vault changes its expiry.
+func (v *FileVault) VaultUnlockFor(d time.Duration) (*WorkingCopy, error) {
+	if d <= 0 {
+		return nil, errors.New("the vault can only be unlocked for a positive duration")
+	}
 
-	unlocked_vault := v.path + ".unlocked"
-	locked_vault := v.path
+	if err := v.lockExpired(); err != nil {
+		return nil, err
+	}
 
-	_, err := os.Stat(locked_vault)
+	l, err := v.acquire(true)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	doc, contents, err := v.readLiveVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	wc, err := v.readWorkingCopy()
+	if err != nil {
+		return nil, err
+	}
+	if wc == nil {
+		// The working copy holds the values in plain text
+		wc = doc
+		wc.close()
+	}
+
+	wc.unlock(contents, time.Now().Add(d))
+	if err := v.writeVault(wc); err != nil {
+		return nil, err
+	}
+
+	return wc.workingCopy(v.workingCopyPath(), contents), nil
+}
+
+// VaultLockWorkingCopy encrypts the changes of the working copy to the vault
+// and removes it. Unless forced it fails with ErrStaleWorkingCopy if the
+// vault changed since it was unlocked.
+func (v *FileVault) VaultLockWorkingCopy(force bool) error {
+	l, err := v.acquire(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	wc, err := v.readWorkingCopy()
+	if wc == nil || err != nil {
+		return err
+	}
+
+	return v.lockWorkingCopy(wc, force)
+}
+
+// VaultDiscardWorkingCopy removes the working copy, dropping its changes.
+func (v *FileVault) VaultDiscardWorkingCopy() error {
+	l, err := v.acquire(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	err = os.Remove(v.workingCopyPath())
 	if os.IsNotExist(err) {
 		return nil
 	}
+	return err
+}
 
-	err = crypto.DecryptFile(locked_vault, unlocked_vault, []byte(v.key))
+// VaultWorkingCopy describes the working copy, or returns nil if the vault is
+// locked.
+func (v *FileVault) VaultWorkingCopy() (*WorkingCopy, error) {
+	l, err := v.acquire(false)
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer l.Unlock()
 
-	return nil
+	wc, err := v.readWorkingCopy()
+	if wc == nil || err != nil {
+		return nil, err
+	}
+
+	contents, err := os.ReadFile(v.path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	return wc.workingCopy(v.workingCopyPath(), contents), nil
+}
+
+// Lock encrypts the working copy of the vault.
+func (v *FileVault) Lock() error {
+	return v.VaultLockWorkingCopy(false)
+}
+
+// Unlock decrypts the vault to a working copy for the default duration.
+func (v *FileVault) Unlock() error {
+	_, err := v.VaultUnlockFor(defaultUnlockDuration)
+	return err
 }
 
 // VaultGetMultipleValues returns the values of multiple keys from the vault.
 func (v *FileVault) VaultGetMultipleValues(keys []string, kv *crypto.SecureKVStore) error {
-	contents, err := v.readVault()
+	l, err := v.lock(false)
 	if err != nil {
 		return err
 	}
+	defer l.Unlock()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			for _, key := range keys {
-				if parts[0] == key {
-					kv.Put(parts[0], parts[1])
-				}
-			}
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	for _, key := range keys {
+		if value, ok := doc.getBytes(key); ok {
+			kv.PutBytes(key, value)
 		}
 	}
 
 	return nil
 }
+
+// Rekey re-encrypts the vault and its backups with a new key, or removes the
+// backups if purgeBackups is set. A vault with members is refused unless
+// dropMembers is set. It returns the list of rewritten or removed files.
+func (v *FileVault) Rekey(newKey []byte, purgeBackups bool, dropMembers bool) ([]string, error) {
+	if len(newKey) == 0 {
+		return nil, errors.New("new key is empty")
+	}
+
+	l, err := v.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	if _, err := os.Stat(v.workingCopyPath()); err == nil {
+		return nil, ErrVaultUnlocked
+	}
+
+	backups, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// The live vault goes last so it is only replaced once every backup is done
+	targets := []string{}
+	if !purgeBackups {
+		targets = append(targets, backups...)
+	}
+	targets = append(targets, v.path)
+
+	// Decrypt and re-encrypt everything first, nothing is written if any
+	// artifact can't be decrypted with the current key. The key-encryption
+	// key is rotated as well, so the old key can't unwrap new data keys.
+	rewritten := make(map[string][]byte, len(targets))
+	for _, target := range targets {
+		contents, err := os.ReadFile(target)
+		if err != nil {
+			return nil, err
+		}
+
+		doc, err := decryptVault(contents, v.context, v.key, v.identities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt '%s': %w", target, err)
+		}
+
+		// The new key replaces the members of the vault, only if asked to
+		if len(doc.Recipients) > 0 && !dropMembers {
+			doc.close()
+			return nil, fmt.Errorf("%w, members remove rotates its key: '%s'", ErrVaultHasMembers, target)
+		}
+		doc.Recipients = nil
+		if err := doc.rotate(); err != nil {
+			doc.close()
+			return nil, err
+		}
+
+		encryptedData, err := v.encryptVault(doc, newKey)
+		doc.close()
+		if err != nil {
+			return nil, err
+		}
+		rewritten[target] = encryptedData
+	}
+
+	report := make([]string, 0, len(targets))
+	for _, target := range targets {
+		err := fsutil.WriteFileAtomic(target, rewritten[target], fsutil.DefaultFileMode)
+		if err != nil {
+			return report, err
+		}
+		report = append(report, target)
+	}
+
+	v.key = newKey
+
+	if purgeBackups {
+		for _, backup := range backups {
+			if err := os.Remove(backup); err != nil {
+				return report, err
+			}
+			report = append(report, backup+" (removed)")
+		}
+	}
+
+	return report, nil
+}
+
+// VaultMigrate re-encrypts the vault and its backups written before they were
+// bound to the vault context, with the same key or members. It returns the
+// list of rewritten files.
+func (v *FileVault) VaultMigrate() ([]string, error) {
+	l, err := v.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	if _, err := os.Stat(v.workingCopyPath()); err == nil {
+		return nil, ErrVaultUnlocked
+	}
+
+	backups, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// The live vault goes last, its counter is recorded once it is written
+	targets := append(backups, v.path)
+
+	report := []string{}
+	for _, target := range targets {
+		contents, err := os.ReadFile(target)
+		if err != nil && !os.IsNotExist(err) {
+			return report, err
+		}
+		if len(contents) == 0 {
+			continue
+		}
+
+		doc, err := decryptUnboundVault(contents, v.context, v.key, v.identities)
+		if err != nil {
+			return report, fmt.Errorf("failed to decrypt '%s': %w", target, err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		if target == v.path {
+			seen, err := seenCounter(v.location)
+			if err != nil {
+				doc.close()
+				return report, err
+			}
+			doc.counter = max(doc.counter, seen)
+		}
+
+		encryptedData, err := v.encryptVault(doc, v.key)
+		doc.close()
+		if err != nil {
+			return report, err
+		}
+		if err := fsutil.WriteFileAtomic(target, encryptedData, fsutil.DefaultFileMode); err != nil {
+			return report, err
+		}
+		report = append(report, target)
+
+		if target == v.path {
+			if err := recordCounter(v.location, doc.counter, false); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// VaultKeyHistory returns the current and previous versions of a key.
+func (v *FileVault) VaultKeyHistory(key string) ([]SecretVersion, error) {
+	l, err := v.lock(false)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	versions, ok := doc.history(key)
+	if !ok {
+		return nil, errors.New("key not found in vault")
+	}
+
+	return versions, nil
+}
+
+// VaultRollback restores a previous version of a key.
+func (v *FileVault) VaultRollback(key string, version int) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.rollback(key, version, v.history); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultListBackups lists the backups of the vault, newest first.
+func (v *FileVault) VaultListBackups() ([]Backup, error) {
+	names, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBackups(v.path, names), nil
+}
+
+// VaultRestoreBackup replaces the vault with a backup, after checking the
+// backup can be decrypted with the vault key.
+func (v *FileVault) VaultRestoreBackup(name string) error {
+	l, err := v.lock(true)
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+
+	if _, err := os.Stat(v.workingCopyPath()); err == nil {
+		return ErrVaultUnlocked
+	}
+
+	names, err := v.listBackups()
+	if err != nil {
+		return err
+	}
+
+	backup, ok := resolveBackup(parseBackups(v.path, names), name)
+	if !ok {
+		return fmt.Errorf("backup '%s' not found", name)
+	}
+
+	contents, err := os.ReadFile(backup.Name)
+	if err != nil {
+		return err
+	}
+
+	doc, err := decryptVault(contents, v.context, v.key, v.identities)
+	if err != nil {
+		return fmt.Errorf("backup '%s' can't be restored: %w", backup.Name, err)
+	}
+	defer doc.close()
+
+	// The backup is written as a new version of the vault, so the restore
+	// isn't taken for a rollback. The current vault is backed up first when
+	// backups are enabled, so the restore can be undone.
+	return v.writeVault(doc)
+}
+
+// VaultPruneBackups removes the backups not retained by the policy, or by the
+// configured policy if nil.
+func (v *FileVault) VaultPruneBackups(policy *RetentionPolicy) ([]string, error) {
+	l, err := v.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer l.Unlock()
+
+	if policy == nil {
+		policy = &v.retention
+	}
+
+	return v.pruneBackups(*policy)
+}
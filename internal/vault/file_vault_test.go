@@ -1,11 +1,24 @@
 package vault
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
 	"errors"
+	"fmt"
+	"net"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"filippo.io/age"
 	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/fsutil"
+	"golang.org/x/crypto/ssh/agent"
 )
 
 func TestFileVault(t *testing.T) {
@@ -19,9 +32,7 @@ func TestFileVault(t *testing.T) {
 		t.Error(err)
 	}
 
-	defer func() {
-		_ = os.Remove(vault_path)
-	}()
+	defer removeVaultFiles(vault_path)
 
 	if len(vault.VaultListKeys()) != 0 {
 		t.Error(errors.New("VaultListKeys != 0"))
@@ -88,12 +99,911 @@ func TestFileVault(t *testing.T) {
 	}
 }
 
+func TestFileVault_MultilineAndLegacy(t *testing.T) {
+
+	vault_path := "testdata/legacy.vault"
+	key := "mysecretkey"
+
+	defer removeVaultFiles(vault_path)
+
+	// Write a vault using the legacy key=value format
+	if err := crypto.EncryptToFile("KEY1=VALUE1\nKEY2=VALUE2", vault_path, []byte(key), false); err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path: vault_path,
+		Key:  key,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The legacy vault is not bound to its context until it is migrated
+	if _, err := vault.VaultGetValue("KEY1"); !errors.Is(err, crypto.ErrNoContext) {
+		t.Errorf("Expected ErrNoContext, got %v", err)
+	}
+
+	migrated, err := vault.VaultMigrate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrated) != 1 || migrated[0] != vault_path {
+		t.Errorf("Expected the vault to be migrated, got %v", migrated)
+	}
+	if migrated, err := vault.VaultMigrate(); err != nil || len(migrated) != 0 {
+		t.Errorf("Expected nothing left to migrate, got %v (%v)", migrated, err)
+	}
+
+	if len(vault.VaultListKeys()) != 2 {
+		t.Error(errors.New("VaultListKeys != 2"))
+	}
+
+	multiline := "-----BEGIN KEY-----\nline1\nKEY1=line2\n-----END KEY-----"
+	if err := vault.VaultSetValue("PEM", multiline); err != nil {
+		t.Fatal(err)
+	}
+
+	// The write upgrades the vault to the structured format
+	contents, err := decryptVaultFile(vault_path, vault.context, []byte(key))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(contents, vaultMagic) {
+		t.Error(errors.New("vault was not upgraded"))
+	}
+
+	value, err := vault.VaultGetValue("PEM")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if value != multiline {
+		t.Errorf("Expected %q, got %q", multiline, value)
+	}
+
+	value, err = vault.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1, got %q", value)
+	}
+
+	if len(vault.VaultListKeys()) != 3 {
+		t.Error(errors.New("VaultListKeys != 3"))
+	}
+}
+
+func TestFileVault_Rekey(t *testing.T) {
+
+	vault_path := "testdata/rekey.vault"
+	oldKey := "mysecretkey"
+	newKey := "mynewsecretkey"
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path: vault_path,
+		Key:  oldKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer removeVaultFiles(vault_path)
+
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	vault.VaultEnableBackup(true)
+	if err := vault.VaultSetValue("KEY2", "VALUE2"); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := vault.listBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d (%v)", len(backups), err)
+	}
+
+	report, err := vault.Rekey([]byte(newKey), false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report) != 2 {
+		t.Errorf("Expected 2 rewritten files, got %v", report)
+	}
+
+	for _, file := range []string{vault_path, backups[0]} {
+		if _, err := decryptVaultFile(file, vault.context, []byte(oldKey)); err == nil {
+			t.Errorf("Expected '%s' to no longer decrypt with the old key", file)
+		}
+		if _, err := decryptVaultFile(file, vault.context, []byte(newKey)); err != nil {
+			t.Errorf("Expected '%s' to decrypt with the new key: %v", file, err)
+		}
+	}
+
+	value, err := vault.VaultGetValue("KEY2")
+	if err != nil || value != "VALUE2" {
+		t.Errorf("Expected VALUE2, got %q (%v)", value, err)
+	}
+
+	report, err = vault.Rekey([]byte(oldKey), true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report) != 2 {
+		t.Errorf("Expected 2 rewritten or removed files, got %v", report)
+	}
+
+	if _, err := os.Stat(backups[0]); !os.IsNotExist(err) {
+		t.Error("Expected backup to be purged")
+	}
+}
+
+func TestFileVault_ConcurrentSet(t *testing.T) {
+
+	vault_path := "testdata/concurrent.vault"
+
+	defer removeVaultFiles(vault_path)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			// Each writer uses its own instance, as separate processes would
+			vault, err := NewFileVault(&config.FileConfig{
+				Path: vault_path,
+				Key:  "mysecretkey",
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if err := vault.VaultSetValue(fmt.Sprintf("KEY%d", i), "VALUE"); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path: vault_path,
+		Key:  "mysecretkey",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vault.VaultListKeys()) != 8 {
+		t.Errorf("Expected 8 keys, got %d", len(vault.VaultListKeys()))
+	}
+}
+
+func TestFileVault_Busy(t *testing.T) {
+
+	vault_path := "testdata/busy.vault"
+
+	defer removeVaultFiles(vault_path)
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path:        vault_path,
+		Key:         "mysecretkey",
+		LockTimeout: "100ms",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := fsutil.LockFile(vault_path+".lock", true, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = vault.VaultSetValue("KEY1", "VALUE1")
+	if !errors.Is(err, ErrVaultBusy) {
+		t.Errorf("Expected ErrVaultBusy, got %v", err)
+	}
+
+	_, err = vault.VaultGetValue("KEY1")
+	if !errors.Is(err, ErrVaultBusy) {
+		t.Errorf("Expected ErrVaultBusy, got %v", err)
+	}
+
+	l.Unlock()
+
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestFileVault_RestoreBackup(t *testing.T) {
+
+	vault_path := "testdata/restore.vault"
+	defer removeVaultFiles(vault_path)
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path: vault_path,
+		Key:  "mysecretkey",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Backups are enabled by default
+	if err := vault.VaultSetValue("KEY1", "VALUE2"); err != nil {
+		t.Fatal(err)
+	}
+
+	backups, err := vault.VaultListBackups()
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Expected 1 backup, got %d (%v)", len(backups), err)
+	}
+
+	// A backup that doesn't decrypt is rejected
+	corrupted := backupName(vault_path, time.Now().Add(-time.Hour))
+	if err := os.WriteFile(corrupted, []byte("corrupted"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.VaultRestoreBackup(corrupted); err == nil {
+		t.Error("Expected error restoring a corrupted backup")
+	}
+
+	if err := vault.VaultRestoreBackup(backups[0].Name); err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := vault.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1 after restore, got %q (%v)", value, err)
+	}
+
+	// The restore kept the replaced vault as a second backup
+	removed, err := vault.VaultPruneBackups(&RetentionPolicy{KeepLast: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(removed) != 1 || removed[0] != corrupted {
+		t.Errorf("Expected the oldest backup to be pruned, got %v", removed)
+	}
+}
+
+func TestFileVault_Envelope(t *testing.T) {
+
+	vault_path := "testdata/envelope.vault"
+	defer removeVaultFiles(vault_path)
+
+	vault, err := NewFileVault(&config.FileConfig{
+		Path: vault_path,
+		Key:  "mysecretkey",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	sealedValue := func() ([]byte, []byte) {
+		contents, err := decryptVaultFile(vault_path, vault.context, vault.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err := parseVault(contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entry := doc.Entries[doc.find("KEY1")]
+		return entry.DataKey, entry.Value
+	}
+
+	dataKey, sealed := sealedValue()
+	if len(dataKey) == 0 || bytes.Contains(sealed, []byte("VALUE1")) {
+		t.Fatal("Expected the value to be sealed with a data key")
+	}
+
+	// Unchanged values are not sealed again
+	if err := vault.VaultSetValue("KEY2", "VALUE2"); err != nil {
+		t.Fatal(err)
+	}
+	if _, resealed := sealedValue(); !bytes.Equal(sealed, resealed) {
+		t.Error("Expected the unchanged value to keep its ciphertext")
+	}
+
+	// Rekeying re-wraps the data keys only
+	if _, err := vault.Rekey([]byte("mynewsecretkey"), true, false); err != nil {
+		t.Fatal(err)
+	}
+	rewrapped, resealed := sealedValue()
+	if !bytes.Equal(sealed, resealed) || bytes.Equal(dataKey, rewrapped) {
+		t.Error("Expected the data key to be re-wrapped and the value left untouched")
+	}
+
+	value, err := vault.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1, got %q (%v)", value, err)
+	}
+}
+
+func TestFileVault_Members(t *testing.T) {
+
+	vault_path := "testdata/members.vault"
+	defer removeVaultFiles(vault_path)
+
+	alice, _ := age.GenerateX25519Identity()
+	bob, _ := age.GenerateX25519Identity()
+
+	// identityPath writes an identity file and returns its path
+	identityPath := func(identity *age.X25519Identity) string {
+		path := filepath.Join(t.TempDir(), "identity.txt")
+		if err := os.WriteFile(path, []byte(identity.String()+"\n"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	owner, err := NewFileVault(&config.FileConfig{
+		Path:     vault_path,
+		Key:      "mysecretkey",
+		Identity: identityPath(alice),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := owner.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The first members include the identity adding them
+	if err := owner.VaultAddMembers([]string{bob.Recipient().String()}); err != nil {
+		t.Fatal(err)
+	}
+	members, err := owner.VaultListMembers()
+	if err != nil || len(members) != 2 {
+		t.Fatalf("Expected 2 members, got %v (%v)", members, err)
+	}
+
+	if _, err := decryptVaultFile(vault_path, owner.context, owner.key); err == nil {
+		t.Error("Expected the vault key to no longer open the vault")
+	}
+
+	member, err := NewFileVault(&config.FileConfig{
+		Path:     vault_path,
+		Identity: identityPath(bob),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	value, err := member.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1, got %q (%v)", value, err)
+	}
+
+	if err := owner.VaultAddMembers([]string{"not-a-recipient"}); err == nil {
+		t.Error("Expected error for an invalid recipient")
+	}
+
+	// Removed members can't open the vault anymore
+	if err := owner.VaultRemoveMembers([]string{bob.Recipient().String()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := member.VaultGetValue("KEY1"); err == nil {
+		t.Error("Expected a removed member to fail")
+	}
+
+	if err := owner.VaultRemoveMembers([]string{alice.Recipient().String()}); err == nil {
+		t.Error("Expected error removing the last member")
+	}
+
+	// Rekeying only switches the vault back to a key if asked to
+	if _, err := owner.Rekey([]byte("mynewsecretkey"), true, false); !errors.Is(err, ErrVaultHasMembers) {
+		t.Errorf("Expected ErrVaultHasMembers, got %v", err)
+	}
+	if members, _ := owner.VaultListMembers(); len(members) != 1 {
+		t.Errorf("Expected the members to be kept, got %v", members)
+	}
+	if _, err := owner.Rekey([]byte("mynewsecretkey"), true, true); err != nil {
+		t.Fatal(err)
+	}
+	if members, _ := owner.VaultListMembers(); len(members) != 0 {
+		t.Errorf("Expected no members after rekey, got %v", members)
+	}
+	if contents, err := decryptVaultFile(vault_path, owner.context, []byte("mynewsecretkey")); err != nil || !strings.Contains(contents, "KEY1") {
+		t.Errorf("Expected the new key to open the vault (%v)", err)
+	}
+}
+
+func TestFileVault_SSHAgent(t *testing.T) {
+
+	vault_path := "testdata/agent.vault"
+	defer removeVaultFiles(vault_path)
+
+	_, privateKey, _ := ed25519.GenerateKey(rand.Reader)
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: privateKey, Comment: "alice@laptop"}); err != nil {
+		t.Fatal(err)
+	}
+
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				_ = agent.ServeAgent(keyring, conn)
+			}()
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	t.Setenv("FILE_VAULT_KEY", "")
+
+	agentConfig := &config.FileConfig{
+		Path:     vault_path,
+		SSHAgent: &config.SSHAgentConfig{Key: "alice@laptop"},
+	}
+
+	vault, err := NewFileVault(agentConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The same key is derived on every use
+	vault, err = NewFileVault(agentConfig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := vault.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1, got %q (%v)", value, err)
+	}
+
+	// Another vault protected by the same ssh key derives another key
+	key, err := AgentVaultKey(agentConfig.SSHAgent, fileVaultID(agentConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := AgentVaultKey(agentConfig.SSHAgent, "other.vault")
+	if err != nil || other == key {
+		t.Errorf("Expected another key for another vault (%v)", err)
+	}
+
+	if _, err := NewFileVault(&config.FileConfig{
+		Path:     vault_path,
+		SSHAgent: &config.SSHAgentConfig{Key: "bob@laptop"},
+	}); err == nil {
+		t.Error("Expected error for a key missing from the agent")
+	}
+}
+
+func TestFileVault_GitFormat(t *testing.T) {
+
+	vault_path := "testdata/git.vault"
+	defer removeVaultFiles(vault_path)
+
+	// A binary vault is converted on the first write
+	binary, err := NewFileVault(&config.FileConfig{Path: vault_path, Key: "mysecretkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := binary.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+
+	vault, err := NewFileVault(&config.FileConfig{Path: vault_path, Key: "mysecretkey", Format: config.VaultFormatGit})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.VaultSetValue("KEY2", "VALUE2"); err != nil {
+		t.Fatal(err)
+	}
+
+	readLines := func() map[string]string {
+		contents, err := os.ReadFile(vault_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(contents, []byte("VALUE")) {
+			t.Fatal("Expected the values to be sealed")
+		}
+		lines := map[string]string{}
+		for _, line := range strings.Split(string(contents), "\n") {
+			if name, value, found := strings.Cut(line, ": "); found {
+				lines[name] = value
+			} else if name, value, found := strings.Cut(line, "="); found {
+				lines[name] = value
+			}
+		}
+		return lines
+	}
+
+	before := readLines()
+	if before["KEY1"] == "" || before["KEY2"] == "" {
+		t.Fatalf("Expected a line per key, got %v", before)
+	}
+
+	// Only the changed key and the MAC change
+	if err := vault.VaultSetValue("KEY2", "VALUE3"); err != nil {
+		t.Fatal(err)
+	}
+	after := readLines()
+	if after["KEY1"] != before["KEY1"] || after["kek"] != before["kek"] {
+		t.Error("Expected the unchanged key and the KEK to keep their ciphertext")
+	}
+	if after["KEY2"] == before["KEY2"] || after["mac"] == before["mac"] {
+		t.Error("Expected the changed key and the MAC to change")
+	}
+
+	// The binary provider reads the git format
+	value, err := binary.VaultGetValue("KEY2")
+	if err != nil || value != "VALUE3" {
+		t.Errorf("Expected VALUE3, got %q (%v)", value, err)
+	}
+
+	contents, _ := os.ReadFile(vault_path)
+	tampered := map[string]string{
+		"removed line": strings.Replace(string(contents), "KEY1="+after["KEY1"]+"\n", "", 1),
+		"swapped values": strings.NewReplacer("KEY1="+after["KEY1"], "KEY1="+after["KEY2"],
+			"KEY2="+after["KEY2"], "KEY2="+after["KEY1"]).Replace(string(contents)),
+	}
+	for name, data := range tampered {
+		if _, err := decryptVault([]byte(data), vault.context, []byte("mysecretkey"), nil); err == nil {
+			t.Errorf("Expected error for a vault with a %s", name)
+		}
+	}
+
+	if _, err := vault.Rekey([]byte("mynewsecretkey"), true, false); err != nil {
+		t.Fatal(err)
+	}
+	value, err = vault.VaultGetValue("KEY1")
+	if err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1 after rekey, got %q (%v)", value, err)
+	}
+}
+
+func TestFileVault_Merge(t *testing.T) {
+
+	vault_path := "testdata/merge.vault"
+	defer removeVaultFiles(vault_path)
+
+	vault, err := NewFileVault(&config.FileConfig{Path: vault_path, Key: "mysecretkey", Format: config.VaultFormatGit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// copyVault saves the current vault as one side of the merge
+	copyVault := func(name string) string {
+		contents, err := os.ReadFile(vault_path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := vault_path + "." + name
+		if err := os.WriteFile(path, contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	for key, value := range map[string]string{"KEEP": "1", "OURS": "1", "THEIRS": "1", "BOTH": "1", "GONE": "1"} {
+		if err := vault.VaultSetValue(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	base := copyVault("base")
+
+	vault.VaultSetValue("OURS", "2")
+	vault.VaultSetValue("BOTH", "2")
+	ours := copyVault("ours")
+
+	// Going back to base, as git does when switching branches, is a rollback
+	t.Setenv("SECTOOL_ALLOW_ROLLBACK", "1")
+	if err := os.WriteFile(vault_path, mustRead(t, base), 0600); err != nil {
+		t.Fatal(err)
+	}
+	vault.VaultSetValue("THEIRS", "2")
+	vault.VaultSetValue("BOTH", "2")
+	vault.VaultSetValue("NEW", "1")
+	vault.VaultDelKey("GONE")
+	theirs := copyVault("theirs")
+
+	conflicts, err := vault.VaultMerge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Fatalf("Expected no conflicts, got %v", conflicts)
+	}
+
+	// A copy of the vault opens with the ID of the original
+	merged, err := NewFileVault(&config.FileConfig{Path: ours, ID: filepath.Base(vault_path), Key: "mysecretkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"KEEP": "1", "OURS": "2", "THEIRS": "2", "BOTH": "2", "NEW": "1"}
+	if keys := merged.VaultListKeys(); len(keys) != len(expected) {
+		t.Errorf("Expected keys %v, got %v", expected, keys)
+	}
+	for key, value := range expected {
+		if got, err := merged.VaultGetValue(key); err != nil || got != value {
+			t.Errorf("Expected %s=%s, got '%s' (%v)", key, value, got, err)
+		}
+	}
+	if !isGitVault(mustRead(t, ours)) {
+		t.Error("Expected the merge to keep the git format")
+	}
+
+	// The text representation has fingerprints but no values
+	text, err := vault.VaultTextConv(ours)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(text, "THEIRS = ") || strings.Count(text, "\n") != len(expected) {
+		t.Errorf("Unexpected text representation:\n%s", text)
+	}
+
+	// Keys changed differently on both sides conflict and ours is kept
+	merged.VaultSetValue("BOTH", "3")
+	contents := mustRead(t, ours)
+	conflicts, err = vault.VaultMerge(base, ours, theirs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 1 || conflicts[0] != "BOTH" {
+		t.Errorf("Expected a conflict on BOTH, got %v", conflicts)
+	}
+	if !bytes.Equal(contents, mustRead(t, ours)) {
+		t.Error("Expected ours to be untouched on conflicts")
+	}
+
+	for _, path := range []string{base, ours, theirs} {
+		removeVaultFiles(path)
+	}
+}
+
+// mustRead reads a file for tests.
+func mustRead(t *testing.T, path string) []byte {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return contents
+}
+
+func TestFileVault_Context(t *testing.T) {
+
+	vault_path := "testdata/context.vault"
+	other_path := "testdata/other.vault"
+	defer removeVaultFiles(vault_path)
+	defer removeVaultFiles(other_path)
+
+	vault, err := NewFileVault(&config.FileConfig{Path: vault_path, Key: "mysecretkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := vault.VaultSetValue("KEY1", "VALUE1"); err != nil {
+		t.Fatal(err)
+	}
+	old := mustRead(t, vault_path)
+	if err := vault.VaultSetValue("KEY1", "VALUE2"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A vault copied over another one with the same key doesn't open
+	if err := os.WriteFile(other_path, mustRead(t, vault_path), 0600); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewFileVault(&config.FileConfig{Path: other_path, Key: "mysecretkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.VaultGetValue("KEY1"); err == nil {
+		t.Error("Expected a vault with another ID to fail to open")
+	}
+	other, err = NewFileVault(&config.FileConfig{Path: other_path, ID: "context.vault", Key: "mysecretkey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := other.VaultGetValue("KEY1"); err != nil || value != "VALUE2" {
+		t.Errorf("Expected VALUE2 with the ID of the vault, got %q (%v)", value, err)
+	}
+
+	// An older copy of the vault is a rollback, unless accepted
+	if err := os.WriteFile(vault_path, old, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := vault.VaultGetValue("KEY1"); !errors.Is(err, ErrVaultRollback) {
+		t.Errorf("Expected ErrVaultRollback, got %v", err)
+	}
+	t.Setenv("SECTOOL_ALLOW_ROLLBACK", "1")
+	if value, err := vault.VaultGetValue("KEY1"); err != nil || value != "VALUE1" {
+		t.Errorf("Expected VALUE1 once the rollback is accepted, got %q (%v)", value, err)
+	}
+}
+
+// decryptVaultFile returns the decrypted contents of a vault file bound to
+// the associated data.
+func decryptVaultFile(path string, ad []byte, key []byte) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	contents, _, _, err := crypto.DecryptEnvelopeWithContext(string(data), key, ad)
+	return contents, err
+}
+
+func TestFileVault_WorkingCopy(t *testing.T) {
+
+	vault_path := "testdata/working.vault"
+	defer removeVaultFiles(vault_path)
+
+	vault, err := NewFileVault(&config.FileConfig{Path: vault_path, Key: "mysecretkey", Format: config.VaultFormatGit})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, value := range map[string]string{"KEEP": "1", "EDIT": "1", "GONE": "1"} {
+		if err := vault.VaultSetValue(key, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+	locked := mustRead(t, vault_path)
+
+	wc, err := vault.VaultUnlockFor(time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wc.Path != vault_path+".unlocked" || wc.Stale || time.Until(wc.Expires) <= 0 {
+		t.Fatalf("Unexpected working copy %+v", wc)
+	}
+
+	// Writes go to the working copy only
+	if err := vault.VaultSetValue("EDIT", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := vault.VaultDelKey("GONE"); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(mustRead(t, vault_path), locked) {
+		t.Error("Expected the vault to be unchanged while unlocked")
+	}
+	if value, _ := vault.VaultGetValue("EDIT"); value != "2" {
+		t.Errorf("Expected the working copy value, got '%s'", value)
+	}
+
+	if err := vault.Lock(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(wc.Path); !os.IsNotExist(err) {
+		t.Error("Expected the working copy to be removed")
+	}
+	if value, _ := vault.VaultGetValue("EDIT"); value != "2" {
+		t.Errorf("Expected the edited value, got '%s'", value)
+	}
+	if vault.VaultHasKey("GONE") {
+		t.Error("Expected the deleted key to be gone")
+	}
+
+	// Only the edited secret changes in the vault
+	keepLine := func(contents []byte) string {
+		for _, line := range strings.Split(string(contents), "\n") {
+			if strings.HasPrefix(line, "KEEP=") {
+				return line
+			}
+		}
+		return ""
+	}
+	if line := keepLine(mustRead(t, vault_path)); line == "" || line != keepLine(locked) {
+		t.Error("Expected the unchanged secret to keep its sealed value")
+	}
+
+	// A vault changed while unlocked is only locked when forced
+	if _, err := vault.VaultUnlockFor(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("SECTOOL_ALLOW_ROLLBACK", "1")
+	if err := os.WriteFile(vault_path, locked, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if wc, _ := vault.VaultWorkingCopy(); wc == nil || !wc.Stale {
+		t.Error("Expected a stale working copy")
+	}
+	if err := vault.Lock(); !errors.Is(err, ErrStaleWorkingCopy) {
+		t.Errorf("Expected ErrStaleWorkingCopy, got %v", err)
+	}
+	if err := vault.VaultLockWorkingCopy(true); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := vault.VaultGetValue("EDIT"); value != "2" {
+		t.Errorf("Expected the working copy to win, got '%s'", value)
+	}
+
+	// Discarding drops the changes
+	if _, err := vault.VaultUnlockFor(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	vault.VaultSetValue("EDIT", "3")
+	if err := vault.VaultDiscardWorkingCopy(); err != nil {
+		t.Fatal(err)
+	}
+	if value, _ := vault.VaultGetValue("EDIT"); value != "2" {
+		t.Errorf("Expected the discarded value to be dropped, got '%s'", value)
+	}
+
+	// An expired working copy is locked again by the next operation
+	if _, err := vault.VaultUnlockFor(time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	vault.VaultSetValue("EDIT", "4")
+	if _, err := vault.VaultUnlockFor(time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if value, _ := vault.VaultGetValue("EDIT"); value != "4" {
+		t.Errorf("Expected the expired working copy to be locked, got '%s'", value)
+	}
+	if wc, _ := vault.VaultWorkingCopy(); wc != nil {
+		t.Error("Expected the vault to be locked")
+	}
+
+	if _, err := vault.VaultUnlockFor(0); err == nil {
+		t.Error("Expected an error unlocking without an expiry")
+	}
+}
+
+// removeVaultFiles removes a test vault with its lock file and backups.
+func removeVaultFiles(path string) {
+	backups, _ := filepath.Glob(path + "_*")
+	for _, backup := range backups {
+		_ = os.Remove(backup)
+	}
+	_ = os.Remove(path)
+	_ = os.Remove(path + ".lock")
+	_ = os.Remove(path + ".unlocked")
+}
+
 // TestMain runs before all tests and can be used for setup or teardown tasks
 func TestMain(m *testing.M) {
-	// Setup code (if any)
+	// Use cheaper key derivation parameters to keep tests fast
+	crypto.DefaultKDFParams = crypto.KDFParams{
+		Algorithm: crypto.KDFArgon2id,
+		Time:      1,
+		Memory:    8 * 1024,
+		Threads:   1,
+	}
+
+	// Keep the counters of the test vaults out of the user configuration
+	dir, err := os.MkdirTemp("", "sectool-counters")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("SECTOOL_COUNTER_FILE", filepath.Join(dir, "counters.json"))
 
 	// Run tests
 	exitCode := m.Run()
+	os.RemoveAll(dir)
 
 	// Teardown code (if any)
 
@@ -0,0 +1,358 @@
+package vault
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/a13labs/sectool/internal/crypto"
+)
+
+const (
+	// vaultMagic identifies the structured vault format.
+	vaultMagic = "SECTOOL-VAULT"
+	// vaultFormatVersion is the current structured vault format version.
+	vaultFormatVersion = 1
+)
+
+// vaultVersion represents a previous value of a secret.
+type vaultVersion struct {
+	Version int       `json:"version"`
+	Value   []byte    `json:"value"`
+	DataKey []byte    `json:"data_key,omitempty"`
+	Updated time.Time `json:"updated"`
+
+	// sealed is the value as stored, sealed with the data key.
+	sealed []byte
+}
+
+// vaultEntry represents a single secret stored in the vault.
+type vaultEntry struct {
+	Key         string         `json:"key"`
+	Value       []byte         `json:"value"`
+	DataKey     []byte         `json:"data_key,omitempty"`
+	Version     int            `json:"version,omitempty"`
+	Created     time.Time      `json:"created,omitempty"`
+	Updated     time.Time      `json:"updated"`
+	Description string         `json:"description,omitempty"`
+	Tags        []string       `json:"tags,omitempty"`
+	Owner       string         `json:"owner,omitempty"`
+	Expires     *time.Time     `json:"expires,omitempty"`
+	History     []vaultVersion `json:"history,omitempty"`
+
+	// sealed is the value as stored, sealed with the data key.
+	sealed []byte
+	// record and line are the entry as last stored in a git vault and its
+	// sealed form, reused while the entry is unchanged.
+	record []byte
+	line   []byte
+}
+
+// vaultDocument represents the decrypted contents of a vault.
+type vaultDocument struct {
+	Entries    []vaultEntry `json:"entries"`
+	Recipients []string     `json:"recipients,omitempty"`
+
+	// UnlockedUntil and UnlockedFrom are only set in the working copy of an
+	// unlocked vault, they hold its expiry and the digest of the vault it was
+	// taken from.
+	UnlockedUntil *time.Time `json:"unlocked_until,omitempty"`
+	UnlockedFrom  string     `json:"unlocked_from,omitempty"`
+
+	// FingerprintKey keys the fingerprints of the values shown in diffs, it
+	// is kept when the KEK is rotated so unchanged values keep theirs.
+	FingerprintKey []byte `json:"fingerprint_key,omitempty"`
+
+	// counter is the number of writes stored in the vault header, it is
+	// increased on every write so an older copy of the vault is detected.
+	counter uint64
+	// envelope seals the values of the document, values are stored in plain
+	// text if nil.
+	envelope *crypto.Envelope
+	// wrappedKEK is the KEK as stored in a git vault and wrappedFor the
+	// recipients it was wrapped to, reused while both are unchanged.
+	wrappedKEK []byte
+	wrappedFor []string
+	// sealedFingerprintKey is the fingerprint key as stored in a git vault,
+	// reused while the KEK is unchanged.
+	sealedFingerprintKey []byte
+}
+
+// parseVault decodes the decrypted vault contents, accepting both the
+// structured format and the legacy newline separated key=value format.
+func parseVault(contents string) (*vaultDocument, error) {
+	if !strings.HasPrefix(contents, vaultMagic) {
+		return parseLegacyVault(contents), nil
+	}
+
+	header, body, found := strings.Cut(contents, "\n")
+	if !found {
+		return nil, errors.New("invalid vault header")
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(header, vaultMagic)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid vault format version: %w", err)
+	}
+
+	if version > vaultFormatVersion {
+		return nil, fmt.Errorf("unsupported vault format version %d", version)
+	}
+
+	var doc vaultDocument
+	if err := json.Unmarshal([]byte(body), &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode vault: %w", err)
+	}
+
+	return &doc, nil
+}
+
+// parseLegacyVault decodes the legacy key=value vault format.
+func parseLegacyVault(contents string) *vaultDocument {
+	doc := &vaultDocument{}
+	lines := strings.Split(contents, "\n")
+	for _, line := range lines {
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 && !doc.has(parts[0]) {
+			doc.Entries = append(doc.Entries, vaultEntry{Key: parts[0], Value: []byte(parts[1])})
+		}
+	}
+	return doc
+}
+
+// encode serializes the document using the current structured format.
+func (d *vaultDocument) encode() (string, error) {
+	entries, err := d.sealedEntries()
+	if err != nil {
+		return "", err
+	}
+
+	body, err := json.Marshal(vaultDocument{
+		Entries:        entries,
+		Recipients:     d.Recipients,
+		UnlockedUntil:  d.UnlockedUntil,
+		UnlockedFrom:   d.UnlockedFrom,
+		FingerprintKey: d.FingerprintKey,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s %d\n%s", vaultMagic, vaultFormatVersion, body), nil
+}
+
+// find returns the index of a key in the document or -1 if not present.
+func (d *vaultDocument) find(key string) int {
+	for i := range d.Entries {
+		if d.Entries[i].Key == key {
+			return i
+		}
+	}
+	return -1
+}
+
+// has checks if the document contains the specified key.
+func (d *vaultDocument) has(key string) bool {
+	return d.find(key) >= 0
+}
+
+// get returns the value of a key.
+func (d *vaultDocument) get(key string) (string, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return "", false
+	}
+	return string(d.Entries[i].Value), true
+}
+
+// getBytes returns the value of a key without copying it to a string, the
+// caller must not modify it.
+func (d *vaultDocument) getBytes(key string) ([]byte, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return nil, false
+	}
+	return d.Entries[i].Value, true
+}
+
+// set adds or updates the value of a key, keeping up to history previous
+// values.
+func (d *vaultDocument) set(key, value string, history int) {
+	now := time.Now().UTC()
+
+	i := d.find(key)
+	if i < 0 {
+		d.Entries = append(d.Entries, vaultEntry{
+			Key:     key,
+			Value:   []byte(value),
+			Version: 1,
+			Created: now,
+			Updated: now,
+			Owner:   currentUser(),
+		})
+		return
+	}
+
+	entry := &d.Entries[i]
+	if entry.Version == 0 {
+		entry.Version = 1
+	}
+
+	if string(entry.Value) != value && history > 0 {
+		previous := vaultVersion{
+			Version: entry.Version,
+			Value:   entry.Value,
+			DataKey: entry.DataKey,
+			Updated: entry.Updated,
+			sealed:  entry.sealed,
+		}
+		entry.History = append([]vaultVersion{previous}, entry.History...)
+	}
+
+	if len(entry.History) > history {
+		entry.History = entry.History[:max(history, 0)]
+	}
+
+	if string(entry.Value) != value {
+		entry.Version++
+		entry.DataKey = nil
+		entry.sealed = nil
+		entry.Expires = nil
+	}
+	entry.Value = []byte(value)
+	entry.Updated = now
+}
+
+// metadata returns the metadata of a key.
+func (d *vaultDocument) metadata(key string) (SecretMetadata, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return SecretMetadata{}, false
+	}
+
+	entry := d.Entries[i]
+	meta := SecretMetadata{
+		Description: entry.Description,
+		Tags:        entry.Tags,
+		Owner:       entry.Owner,
+		Created:     entry.Created,
+		Updated:     entry.Updated,
+	}
+	if entry.Expires != nil {
+		meta.Expires = *entry.Expires
+	}
+	return meta, true
+}
+
+// setMetadata updates the metadata of a key, empty fields are left as is.
+func (d *vaultDocument) setMetadata(key string, meta SecretMetadata) bool {
+	i := d.find(key)
+	if i < 0 {
+		return false
+	}
+
+	entry := &d.Entries[i]
+	current, _ := d.metadata(key)
+	merged := mergeMetadata(current, meta)
+	entry.Description = merged.Description
+	entry.Tags = merged.Tags
+	entry.Owner = merged.Owner
+	entry.Expires = nil
+	if !merged.Expires.IsZero() {
+		entry.Expires = &merged.Expires
+	}
+	return true
+}
+
+// listMetadata returns the metadata of all keys in the document.
+func (d *vaultDocument) listMetadata() map[string]SecretMetadata {
+	metadata := make(map[string]SecretMetadata, len(d.Entries))
+	for _, entry := range d.Entries {
+		metadata[entry.Key], _ = d.metadata(entry.Key)
+	}
+	return metadata
+}
+
+// history returns the current and previous versions of a key, newest first.
+func (d *vaultDocument) history(key string) ([]SecretVersion, bool) {
+	i := d.find(key)
+	if i < 0 {
+		return nil, false
+	}
+
+	entry := d.Entries[i]
+	versions := []SecretVersion{{
+		Version: max(entry.Version, 1),
+		Value:   string(entry.Value),
+		Updated: entry.Updated,
+		Current: true,
+	}}
+
+	for _, previous := range entry.History {
+		versions = append(versions, SecretVersion{
+			Version: previous.Version,
+			Value:   string(previous.Value),
+			Updated: previous.Updated,
+		})
+	}
+
+	return versions, true
+}
+
+// rollback restores a previous version of a key as a new version.
+func (d *vaultDocument) rollback(key string, version int, history int) error {
+	i := d.find(key)
+	if i < 0 {
+		return errors.New("key not found in vault")
+	}
+
+	for _, previous := range d.Entries[i].History {
+		if previous.Version == version {
+			d.set(key, string(previous.Value), history)
+			return nil
+		}
+	}
+
+	return fmt.Errorf("version %d not found in history", version)
+}
+
+// del removes a key, returning false if it was not present.
+func (d *vaultDocument) del(key string) bool {
+	i := d.find(key)
+	if i < 0 {
+		return false
+	}
+	d.Entries = append(d.Entries[:i], d.Entries[i+1:]...)
+	return true
+}
+
+// rename renames keys, keeping their history and metadata.
+func (d *vaultDocument) rename(renames map[string]string) error {
+	for from, to := range renames {
+		if !d.has(from) {
+			return fmt.Errorf("key '%s' not found in vault", from)
+		}
+		if _, moved := renames[to]; d.has(to) && !moved {
+			return fmt.Errorf("key '%s' already exists", to)
+		}
+	}
+
+	for i := range d.Entries {
+		if to, ok := renames[d.Entries[i].Key]; ok {
+			d.Entries[i].Key = to
+		}
+	}
+	return nil
+}
+
+// keys returns all keys in the document.
+func (d *vaultDocument) keys() []string {
+	keys := make([]string, 0, len(d.Entries))
+	for _, entry := range d.Entries {
+		keys = append(keys, entry.Key)
+	}
+	return keys
+}
@@ -0,0 +1,310 @@
+package vault
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/a13labs/sectool/internal/crypto"
+)
+
+func TestParseVault_Legacy(t *testing.T) {
+	doc, err := parseVault("KEY1=VALUE1\nKEY2=a=b\n")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(doc.keys()) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(doc.keys()))
+	}
+
+	if value, _ := doc.get("KEY2"); value != "a=b" {
+		t.Fatalf("Expected value 'a=b', got %q", value)
+	}
+}
+
+func TestParseVault_Empty(t *testing.T) {
+	doc, err := parseVault("")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(doc.keys()) != 0 {
+		t.Fatalf("Expected empty vault, got %d keys", len(doc.keys()))
+	}
+}
+
+func TestVaultDocument_EncodeMultilineAndBinary(t *testing.T) {
+	multiline := "-----BEGIN KEY-----\nline1\nKEY=line2\n-----END KEY-----\n"
+	binary := string([]byte{0x00, 0xff, '\n', '=', 0x80})
+
+	doc := &vaultDocument{}
+	doc.set("PEM", multiline, 0)
+	doc.set("BIN", binary, 0)
+
+	contents, err := doc.encode()
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if !strings.HasPrefix(contents, vaultMagic) {
+		t.Fatal("Expected encoded vault to start with the format header")
+	}
+
+	decoded, err := parseVault(contents)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if value, _ := decoded.get("PEM"); value != multiline {
+		t.Fatalf("Expected multiline value to round-trip, got %q", value)
+	}
+
+	if value, _ := decoded.get("BIN"); value != binary {
+		t.Fatalf("Expected binary value to round-trip, got %q", value)
+	}
+
+	if len(decoded.keys()) != 2 {
+		t.Fatalf("Expected 2 keys, got %d", len(decoded.keys()))
+	}
+}
+
+func TestParseVault_UnsupportedVersion(t *testing.T) {
+	_, err := parseVault(vaultMagic + " 999\n{}")
+	if err == nil {
+		t.Fatal("Expected error for unsupported format version")
+	}
+}
+
+func TestVaultDocument_History(t *testing.T) {
+	doc := &vaultDocument{}
+	doc.set("KEY", "v1", 2)
+	doc.set("KEY", "v2", 2)
+	doc.set("KEY", "v3", 2)
+	doc.set("KEY", "v4", 2)
+
+	versions, ok := doc.history("KEY")
+	if !ok {
+		t.Fatal("Expected key to be present")
+	}
+
+	// The current version plus the last two previous versions
+	if len(versions) != 3 {
+		t.Fatalf("Expected 3 versions, got %d", len(versions))
+	}
+
+	if !versions[0].Current || versions[0].Version != 4 || versions[0].Value != "v4" {
+		t.Errorf("Unexpected current version %+v", versions[0])
+	}
+
+	if versions[1].Version != 3 || versions[2].Version != 2 {
+		t.Errorf("Expected versions 3 and 2, got %d and %d", versions[1].Version, versions[2].Version)
+	}
+
+	if err := doc.rollback("KEY", 2, 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, _ := doc.get("KEY"); value != "v2" {
+		t.Errorf("Expected value 'v2' after rollback, got %q", value)
+	}
+
+	versions, _ = doc.history("KEY")
+	if versions[0].Version != 5 || versions[1].Value != "v4" {
+		t.Errorf("Expected rollback to create version 5, got %+v", versions)
+	}
+
+	if err := doc.rollback("KEY", 1, 2); err == nil {
+		t.Error("Expected error for a version no longer in history")
+	}
+}
+
+func TestVaultDocument_HistoryDisabled(t *testing.T) {
+	doc := &vaultDocument{}
+	doc.set("KEY", "v1", 0)
+	doc.set("KEY", "v2", 0)
+
+	versions, _ := doc.history("KEY")
+	if len(versions) != 1 {
+		t.Errorf("Expected only the current version, got %d", len(versions))
+	}
+}
+
+func TestVaultDocument_Metadata(t *testing.T) {
+	doc := &vaultDocument{}
+	doc.set("KEY", "v1", 5)
+	doc.setMetadata("KEY", SecretMetadata{Description: "database root", Tags: []string{"db", "prod"}, Owner: "alice"})
+
+	meta, ok := doc.metadata("KEY")
+	if !ok {
+		t.Fatal("Expected metadata for KEY")
+	}
+	if meta.Description != "database root" || meta.Owner != "alice" || len(meta.Tags) != 2 {
+		t.Errorf("Unexpected metadata %+v", meta)
+	}
+	if meta.Created.IsZero() || meta.Updated.Before(meta.Created) {
+		t.Errorf("Unexpected timestamps %+v", meta)
+	}
+
+	created := meta.Created
+	doc.set("KEY", "v2", 5)
+	doc.setMetadata("KEY", SecretMetadata{Tags: []string{"db"}})
+
+	contents, err := doc.encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := parseVault(contents)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta, _ = parsed.metadata("KEY")
+	if meta.Description != "database root" || meta.Owner != "alice" {
+		t.Errorf("Expected description and owner to be kept, got %+v", meta)
+	}
+	if len(meta.Tags) != 1 || meta.Tags[0] != "db" {
+		t.Errorf("Expected tags to be replaced, got %v", meta.Tags)
+	}
+	if !meta.Created.Equal(created) {
+		t.Errorf("Expected creation time to be kept, got %v", meta.Created)
+	}
+
+	if _, ok := parsed.metadata("MISSING"); ok {
+		t.Error("Expected no metadata for a missing key")
+	}
+}
+
+func TestVaultDocument_ExpiryReset(t *testing.T) {
+	doc := &vaultDocument{}
+	expired := time.Now().Add(-time.Hour)
+	doc.set("KEY", "v1", 5)
+	doc.setMetadata("KEY", SecretMetadata{Expires: expired})
+
+	// Updating other metadata keeps the expiry
+	doc.set("KEY", "v1", 5)
+	doc.setMetadata("KEY", SecretMetadata{Description: "database root"})
+	if meta, _ := doc.metadata("KEY"); !meta.Expired(time.Now()) {
+		t.Errorf("Expected the expiry to be kept, got %+v", meta)
+	}
+
+	// Rotating the value resets it
+	doc.set("KEY", "v2", 5)
+	doc.setMetadata("KEY", SecretMetadata{})
+	if meta, _ := doc.metadata("KEY"); !meta.Expires.IsZero() {
+		t.Errorf("Expected the expiry to be reset, got %v", meta.Expires)
+	}
+
+	doc.setMetadata("KEY", SecretMetadata{Expires: expired})
+	doc.setMetadata("KEY", SecretMetadata{NoExpiry: true})
+	if meta, _ := doc.metadata("KEY"); !meta.Expires.IsZero() {
+		t.Errorf("Expected the expiry to be cleared, got %v", meta.Expires)
+	}
+}
+
+func TestBitwardenNote(t *testing.T) {
+	if note := encodeBitwardenNote(SecretMetadata{}); note != bitwardenNote {
+		t.Errorf("Expected default note, got %q", note)
+	}
+
+	if meta := decodeBitwardenNote(bitwardenNote); !meta.IsEmpty() {
+		t.Errorf("Expected no metadata for the default note, got %+v", meta)
+	}
+
+	if meta := decodeBitwardenNote("set by hand"); meta.Description != "set by hand" {
+		t.Errorf("Expected a plain note to be the description, got %+v", meta)
+	}
+
+	note := encodeBitwardenNote(SecretMetadata{Description: "api", Tags: []string{"ci"}, Owner: "bob"})
+	meta := decodeBitwardenNote(note)
+	if meta.Description != "api" || meta.Owner != "bob" || len(meta.Tags) != 1 || meta.Tags[0] != "ci" {
+		t.Errorf("Unexpected metadata %+v from note %q", meta, note)
+	}
+}
+
+func TestVaultDocument_Rename(t *testing.T) {
+	doc := &vaultDocument{}
+	doc.set("a/KEY", "v1", 5)
+	doc.set("a/KEY", "v2", 5)
+	doc.set("b/KEY", "other", 5)
+
+	if err := doc.rename(map[string]string{"a/KEY": "b/KEY"}); err == nil {
+		t.Error("Expected error renaming onto an existing key")
+	}
+
+	// Swapping keys is allowed as both are moved
+	if err := doc.rename(map[string]string{"a/KEY": "b/KEY", "b/KEY": "a/KEY"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if value, _ := doc.get("b/KEY"); value != "v2" {
+		t.Errorf("Expected 'v2', got %q", value)
+	}
+
+	if versions, _ := doc.history("b/KEY"); len(versions) != 2 {
+		t.Errorf("Expected history to be kept, got %d versions", len(versions))
+	}
+
+	if err := doc.rename(map[string]string{"missing": "c/KEY"}); err == nil {
+		t.Error("Expected error renaming a missing key")
+	}
+}
+
+func TestVaultDocument_FingerprintsSurviveRotate(t *testing.T) {
+	ad := vaultContext("fingerprints")
+	for _, format := range []string{"binary", "git"} {
+		doc, err := decryptVault(nil, ad, []byte("key"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc.set("KEPT", "value", 0)
+		doc.set("CHANGED", "before", 0)
+
+		// The fingerprint key is created on the first write and stored
+		encrypt := encryptVault
+		if format == "git" {
+			encrypt = encryptGitVault
+		}
+		data, err := encrypt(doc, ad, []byte("key"), crypto.DefaultKDFParams)
+		doc.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err = decryptVault(data, ad, []byte("key"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		before, err := doc.textconv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := doc.rotate(); err != nil {
+			t.Fatal(err)
+		}
+		doc.set("CHANGED", "after", 0)
+		data, err = encrypt(doc, ad, []byte("key"), crypto.DefaultKDFParams)
+		doc.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, err = decryptVault(data, ad, []byte("key"), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		after, err := doc.textconv()
+		doc.close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		beforeLines, afterLines := strings.Split(before, "\n"), strings.Split(after, "\n")
+		if beforeLines[0] == afterLines[0] || !strings.HasPrefix(afterLines[0], "CHANGED = ") {
+			t.Errorf("%s: expected the changed value to get a new fingerprint, got %q and %q", format, beforeLines[0], afterLines[0])
+		}
+		if beforeLines[1] != afterLines[1] || !strings.HasPrefix(afterLines[1], "KEPT = ") {
+			t.Errorf("%s: expected the unchanged value to keep its fingerprint, got %q and %q", format, beforeLines[1], afterLines[1])
+		}
+	}
+}
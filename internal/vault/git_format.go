@@ -0,0 +1,275 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/a13labs/sectool/internal/crypto"
+)
+
+// gitVaultMagic identifies the git friendly vault format, a text document
+// with a line per secret so diffs show which keys changed:
+//
+//	SECTOOL-VAULT-GIT 3
+//	counter: <number of writes>
+//	kek: <wrapped key-encryption key>
+//	fingerprint: <sealed fingerprint key>
+//	recipient: <age recipient>
+//
+//	KEY=<sealed entry>
+//
+//	mac: <HMAC of everything above>
+//
+// Entries are sealed with a key derived from the KEK and bound to their key,
+// the MAC detects entries being added, removed or moved between vaults. The
+// MAC also covers the vault context, which is not stored.
+// Unchanged entries keep their ciphertext from one write to the next.
+const gitVaultMagic = "SECTOOL-VAULT-GIT"
+
+// gitVaultVersion is the current git vault format version, version 2 added
+// the counter and binds the MAC to the vault context, version 3 added the
+// fingerprint key.
+const gitVaultVersion = 3
+
+// fingerprintRecord is the id the fingerprint key is sealed with.
+const fingerprintRecord = "\x00fingerprint"
+
+// isGitVault reports whether data is stored in the git friendly format.
+func isGitVault(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(gitVaultMagic+" "))
+}
+
+// decryptGitVault decodes a git friendly vault, verifying its MAC and opening
+// the sealed entries.
+func decryptGitVault(data []byte, ad []byte, key []byte, identities []age.Identity) (*vaultDocument, error) {
+	contents := string(data)
+
+	// The MAC is the last line, it covers everything before it
+	body := strings.TrimRight(contents, "\n")
+	i := strings.LastIndex(body, "\n")
+	if i < 0 {
+		return nil, errors.New("invalid git vault")
+	}
+	body, macLine := body[:i+1], body[i+1:]
+
+	lines := strings.Split(strings.TrimSuffix(body, "\n"), "\n")
+	version, err := strconv.Atoi(strings.TrimPrefix(lines[0], gitVaultMagic+" "))
+	if err != nil || version > gitVaultVersion {
+		return nil, fmt.Errorf("unsupported git vault format '%s'", lines[0])
+	}
+
+	doc := &vaultDocument{}
+	var wrappedKEK, sealedFingerprintKey []byte
+	sealed := map[string][]byte{}
+	inHeader := true
+	for _, line := range lines[1:] {
+		if line == "" {
+			inHeader = false
+			continue
+		}
+
+		if inHeader {
+			name, value, _ := strings.Cut(line, ": ")
+			switch name {
+			case "kek":
+				if wrappedKEK, err = base64.StdEncoding.DecodeString(value); err != nil {
+					return nil, fmt.Errorf("invalid key-encryption key: %w", err)
+				}
+			case "fingerprint":
+				if sealedFingerprintKey, err = base64.StdEncoding.DecodeString(value); err != nil {
+					return nil, fmt.Errorf("invalid fingerprint key: %w", err)
+				}
+			case "recipient":
+				doc.Recipients = append(doc.Recipients, value)
+			case "counter":
+				if doc.counter, err = strconv.ParseUint(value, 10, 64); err != nil {
+					return nil, fmt.Errorf("invalid counter: %w", err)
+				}
+			default:
+				return nil, fmt.Errorf("unknown git vault header '%s'", name)
+			}
+			continue
+		}
+
+		// Keys may contain '=', unpadded base64 doesn't
+		j := strings.LastIndex(line, "=")
+		if j < 0 {
+			return nil, errors.New("invalid git vault entry")
+		}
+		entryKey := line[:j]
+		if _, found := sealed[entryKey]; found {
+			return nil, fmt.Errorf("duplicate key '%s'", entryKey)
+		}
+		if sealed[entryKey], err = base64.RawStdEncoding.DecodeString(line[j+1:]); err != nil {
+			return nil, fmt.Errorf("invalid entry '%s': %w", entryKey, err)
+		}
+		doc.Entries = append(doc.Entries, vaultEntry{Key: entryKey})
+	}
+
+	if wrappedKEK == nil {
+		return nil, errors.New("git vault has no key-encryption key")
+	}
+
+	envelope, err := crypto.OpenEnvelope(wrappedKEK, key, identities...)
+	if err != nil {
+		return nil, err
+	}
+	// The envelope is wiped unless the document is returned
+	opened := false
+	defer func() {
+		if !opened {
+			envelope.Close()
+		}
+	}()
+
+	mac, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(macLine, "mac: "))
+	if err != nil || !strings.HasPrefix(macLine, "mac: ") {
+		return nil, errors.New("git vault has no MAC")
+	}
+	// The first version is not bound to the vault context
+	if version < 2 && len(ad) > 0 {
+		return nil, crypto.ErrNoContext
+	}
+	expected, err := envelope.MAC(slices.Concat(ad, []byte(body)))
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal(mac, expected) {
+		return nil, errors.New("git vault MAC mismatch, the vault was modified")
+	}
+
+	for i := range doc.Entries {
+		entry := &doc.Entries[i]
+		record, err := envelope.OpenRecord(entry.Key, sealed[entry.Key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to open '%s': %w", entry.Key, err)
+		}
+
+		var stored vaultEntry
+		if err := json.Unmarshal(record, &stored); err != nil {
+			return nil, fmt.Errorf("failed to decode '%s': %w", entry.Key, err)
+		}
+		if stored.Key != entry.Key {
+			return nil, fmt.Errorf("entry '%s' holds key '%s'", entry.Key, stored.Key)
+		}
+
+		stored.record, stored.line = record, sealed[entry.Key]
+		*entry = stored
+	}
+
+	if sealedFingerprintKey != nil {
+		if doc.FingerprintKey, err = envelope.OpenRecord(fingerprintRecord, sealedFingerprintKey); err != nil {
+			return nil, fmt.Errorf("failed to open the fingerprint key: %w", err)
+		}
+		doc.sealedFingerprintKey = sealedFingerprintKey
+	}
+
+	if err := doc.openValues(envelope); err != nil {
+		return nil, err
+	}
+	opened = true
+	doc.wrappedKEK, doc.wrappedFor = wrappedKEK, slices.Clone(doc.Recipients)
+
+	return doc, nil
+}
+
+// encryptGitVault encodes a vault in the git friendly format, entries are
+// sorted by key and only sealed again if they changed.
+func encryptGitVault(doc *vaultDocument, ad []byte, key []byte, kdf crypto.KDFParams) ([]byte, error) {
+	if doc.envelope == nil {
+		return nil, errors.New("vault has no key-encryption key")
+	}
+
+	// The wrapped KEK is kept while the KEK and its recipients are unchanged
+	if doc.wrappedKEK == nil || !slices.Equal(doc.wrappedFor, doc.Recipients) {
+		var wrappedKEK []byte
+		var err error
+		if len(doc.Recipients) > 0 {
+			recipients, err := parseRecipients(doc.Recipients)
+			if err != nil {
+				return nil, err
+			}
+			wrappedKEK, err = doc.envelope.WrapKEKForRecipients(recipients)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			if len(key) == 0 {
+				return nil, errors.New("vault key is not defined")
+			}
+			wrappedKEK, err = doc.envelope.WrapKEK(key, kdf)
+			if err != nil {
+				return nil, err
+			}
+		}
+		doc.wrappedKEK, doc.wrappedFor = wrappedKEK, slices.Clone(doc.Recipients)
+	}
+
+	if err := doc.newFingerprintKey(); err != nil {
+		return nil, err
+	}
+	if doc.sealedFingerprintKey == nil {
+		sealedFingerprintKey, err := doc.envelope.SealRecord(fingerprintRecord, doc.FingerprintKey)
+		if err != nil {
+			return nil, err
+		}
+		doc.sealedFingerprintKey = sealedFingerprintKey
+	}
+
+	entries, err := doc.sealedEntries()
+	if err != nil {
+		return nil, err
+	}
+
+	doc.counter++
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s %d\n", gitVaultMagic, gitVaultVersion)
+	fmt.Fprintf(&b, "counter: %d\n", doc.counter)
+	fmt.Fprintf(&b, "kek: %s\n", base64.StdEncoding.EncodeToString(doc.wrappedKEK))
+	fmt.Fprintf(&b, "fingerprint: %s\n", base64.StdEncoding.EncodeToString(doc.sealedFingerprintKey))
+	for _, recipient := range doc.Recipients {
+		fmt.Fprintf(&b, "recipient: %s\n", recipient)
+	}
+	b.WriteString("\n")
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	for _, stored := range entries {
+		record, err := json.Marshal(stored)
+		if err != nil {
+			return nil, err
+		}
+
+		line := stored.line
+		if line == nil || !bytes.Equal(record, stored.record) {
+			if line, err = doc.envelope.SealRecord(stored.Key, record); err != nil {
+				return nil, err
+			}
+		}
+
+		// Keep the sealed entry for the next write
+		if i := doc.find(stored.Key); i >= 0 {
+			doc.Entries[i].record, doc.Entries[i].line = record, line
+		}
+
+		fmt.Fprintf(&b, "%s=%s\n", stored.Key, base64.RawStdEncoding.EncodeToString(line))
+	}
+	b.WriteString("\n")
+
+	mac, err := doc.envelope.MAC(slices.Concat(ad, []byte(b.String())))
+	if err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(&b, "mac: %s\n", base64.StdEncoding.EncodeToString(mac))
+
+	return []byte(b.String()), nil
+}
@@ -0,0 +1,110 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+
+	"filippo.io/age"
+)
+
+// ErrVaultHasMembers is returned when rekeying a vault with members without
+// dropping them.
+var ErrVaultHasMembers = errors.New("the vault has members")
+
+// MembersProvider is implemented by providers whose key-encryption key can be
+// encrypted to a list of age recipients, the members of the vault.
+type MembersProvider interface {
+	VaultListMembers() ([]string, error)
+	VaultAddMembers(recipients []string) error
+	VaultRemoveMembers(recipients []string) error
+}
+
+// identityFile returns the configured identity file, or the one set in the
+// SECTOOL_IDENTITY environment variable.
+func identityFile(path string) string {
+	if path == "" {
+		path = os.Getenv("SECTOOL_IDENTITY")
+	}
+	return path
+}
+
+// loadIdentities reads the age identities of an identity file.
+func loadIdentities(path string) ([]age.Identity, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open identity file: %w", err)
+	}
+	defer f.Close()
+
+	identities, err := age.ParseIdentities(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse identity file: %w", err)
+	}
+	return identities, nil
+}
+
+// parseRecipients parses age X25519 recipients.
+func parseRecipients(recipients []string) ([]age.Recipient, error) {
+	parsed := make([]age.Recipient, 0, len(recipients))
+	for _, recipient := range recipients {
+		r, err := age.ParseX25519Recipient(recipient)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, r)
+	}
+	return parsed, nil
+}
+
+// addMembers adds recipients to the document. A vault without members is
+// encrypted with the vault key, when the first members are added the
+// recipients of the given identities are added as well so the vault can
+// still be opened.
+func (d *vaultDocument) addMembers(recipients []string, identities []age.Identity) error {
+	if _, err := parseRecipients(recipients); err != nil {
+		return err
+	}
+
+	if len(d.Recipients) == 0 {
+		own := 0
+		for _, identity := range identities {
+			if x25519, ok := identity.(*age.X25519Identity); ok {
+				recipients = append(recipients, x25519.Recipient().String())
+				own++
+			}
+		}
+		if own == 0 {
+			return errors.New("an identity is required to add the first members")
+		}
+	}
+
+	for _, recipient := range recipients {
+		if !slices.Contains(d.Recipients, recipient) {
+			d.Recipients = append(d.Recipients, recipient)
+		}
+	}
+	return nil
+}
+
+// removeMembers removes recipients from the document, at least one member
+// has to remain.
+func (d *vaultDocument) removeMembers(recipients []string) error {
+	for _, recipient := range recipients {
+		i := slices.Index(d.Recipients, recipient)
+		if i < 0 {
+			return fmt.Errorf("'%s' is not a member of the vault", recipient)
+		}
+		d.Recipients = slices.Delete(d.Recipients, i, i+1)
+	}
+
+	if len(d.Recipients) == 0 {
+		return errors.New("a vault needs at least one member, use rekey to switch back to a key")
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+package vault
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// GitProvider is implemented by providers whose vault files can be diffed
+// and merged by git.
+type GitProvider interface {
+	VaultTextConv(path string) (string, error)
+	VaultMerge(base, ours, theirs string) ([]string, error)
+}
+
+// textconv returns a text representation of the document for diffs, values
+// are replaced by a fingerprint so changes show without revealing them.
+func (d *vaultDocument) textconv() (string, error) {
+	var b strings.Builder
+	for _, recipient := range d.Recipients {
+		fmt.Fprintf(&b, "recipient: %s\n", recipient)
+	}
+
+	entries := slices.Clone(d.Entries)
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Key < entries[j].Key })
+	for _, entry := range entries {
+		fingerprint, err := d.fingerprint(entry)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(&b, "%s = %s\n", entry.Key, hex.EncodeToString(fingerprint[:8]))
+	}
+
+	return b.String(), nil
+}
+
+// fingerprint returns the HMAC of an entry with the fingerprint key. Vaults
+// written before it existed fall back to a key derived from the KEK, which
+// changes when the KEK is rotated.
+func (d *vaultDocument) fingerprint(entry vaultEntry) ([]byte, error) {
+	data := []byte(entry.Key + "\x00" + string(entry.Value))
+	if d.FingerprintKey == nil {
+		return d.envelope.MAC(data)
+	}
+	mac := hmac.New(sha256.New, d.FingerprintKey)
+	mac.Write(data)
+	return mac.Sum(nil), nil
+}
+
+// merge applies the changes made in theirs since base to the document, it
+// returns the keys changed differently on both sides, which are left as they
+// are. Entries taken from theirs are sealed again with the document key.
+func (d *vaultDocument) merge(base, theirs *vaultDocument) ([]string, error) {
+	keys := map[string]bool{}
+	for _, doc := range []*vaultDocument{base, d, theirs} {
+		for _, entry := range doc.Entries {
+			keys[entry.Key] = true
+		}
+	}
+
+	sorted := make([]string, 0, len(keys))
+	for key := range keys {
+		sorted = append(sorted, key)
+	}
+	sort.Strings(sorted)
+
+	conflicts := []string{}
+	for _, key := range sorted {
+		b, o, t := base.entry(key), d.entry(key), theirs.entry(key)
+		switch {
+		case sameEntry(o, t), sameEntry(b, t):
+			// Unchanged in theirs or changed the same way
+		case sameEntry(b, o):
+			if t == nil {
+				d.del(key)
+			} else {
+				d.replace(*t)
+			}
+		default:
+			conflicts = append(conflicts, key)
+		}
+	}
+
+	recipients := d.Recipients
+	switch {
+	case slices.Equal(d.Recipients, theirs.Recipients), slices.Equal(base.Recipients, theirs.Recipients):
+	case slices.Equal(base.Recipients, d.Recipients):
+		d.Recipients = slices.Clone(theirs.Recipients)
+	default:
+		conflicts = append(conflicts, "(members)")
+	}
+
+	// Members removed on the other side must not be able to unwrap new keys
+	if !slices.Equal(recipients, d.Recipients) {
+		if err := d.rotate(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The merged vault is newer than both sides
+	d.counter = max(d.counter, theirs.counter)
+
+	return conflicts, nil
+}
+
+// entry returns the entry of a key or nil if not present.
+func (d *vaultDocument) entry(key string) *vaultEntry {
+	if i := d.find(key); i >= 0 {
+		return &d.Entries[i]
+	}
+	return nil
+}
+
+// replace adds an entry from another document or replaces the current one,
+// its values are sealed again on the next write.
+func (d *vaultDocument) replace(entry vaultEntry) {
+	entry.DataKey, entry.sealed, entry.record, entry.line = nil, nil, nil, nil
+	entry.History = slices.Clone(entry.History)
+	for i := range entry.History {
+		entry.History[i].DataKey, entry.History[i].sealed = nil, nil
+	}
+
+	if i := d.find(entry.Key); i >= 0 {
+		d.Entries[i] = entry
+		return
+	}
+	d.Entries = append(d.Entries, entry)
+}
+
+// sameEntry reports whether two entries have the same value and metadata,
+// nil entries are missing keys.
+func sameEntry(a, b *vaultEntry) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	sameExpiry := (a.Expires == nil && b.Expires == nil) ||
+		(a.Expires != nil && b.Expires != nil && a.Expires.Equal(*b.Expires))
+
+	return bytes.Equal(a.Value, b.Value) &&
+		a.Description == b.Description &&
+		slices.Equal(a.Tags, b.Tags) &&
+		a.Owner == b.Owner &&
+		sameExpiry
+}
@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SecretMetadata holds the optional metadata of a secret.
+type SecretMetadata struct {
+	Description string
+	Tags        []string
+	Owner       string
+	Expires     time.Time
+	Created     time.Time
+	Updated     time.Time
+	// NoExpiry clears the expiry when updating.
+	NoExpiry bool
+}
+
+// IsEmpty reports whether no user defined metadata is set.
+func (m SecretMetadata) IsEmpty() bool {
+	return m.Description == "" && len(m.Tags) == 0 && m.Owner == "" && m.Expires.IsZero() && !m.NoExpiry
+}
+
+// Expired reports whether the secret has expired at the given time.
+func (m SecretMetadata) Expired(now time.Time) bool {
+	return !m.Expires.IsZero() && !now.Before(m.Expires)
+}
+
+// ExpiresWithin reports whether the secret expires within d of the given
+// time, expired secrets included.
+func (m SecretMetadata) ExpiresWithin(now time.Time, d time.Duration) bool {
+	return !m.Expires.IsZero() && now.Add(d).After(m.Expires)
+}
+
+// MetadataProvider is implemented by providers that keep metadata next to
+// each secret. Empty metadata fields keep the current value when updating,
+// except the expiry, which is reset when the value changes: a rotated secret
+// is no longer expired.
+type MetadataProvider interface {
+	VaultProvider
+	VaultSetValueWithMetadata(key, value string, meta SecretMetadata) error
+	VaultGetMetadata(key string) (SecretMetadata, error)
+	VaultListMetadata() (map[string]SecretMetadata, error)
+}
+
+// mergeMetadata applies the non empty fields of update over current.
+func mergeMetadata(current, update SecretMetadata) SecretMetadata {
+	if update.Description != "" {
+		current.Description = update.Description
+	}
+	if len(update.Tags) > 0 {
+		current.Tags = update.Tags
+	}
+	if update.Owner != "" {
+		current.Owner = update.Owner
+	}
+	if !update.Expires.IsZero() {
+		current.Expires = update.Expires
+	}
+	if update.NoExpiry {
+		current.Expires = time.Time{}
+	}
+	return current
+}
+
+// ParseDuration parses a duration, in addition to the units accepted by
+// time.ParseDuration it accepts days (d) and weeks (w), e.g. 90d or 2w.
+func ParseDuration(s string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if n, found := strings.CutSuffix(s, suffix); found {
+			count, err := strconv.Atoi(n)
+			if err != nil || count < 0 {
+				return 0, fmt.Errorf("invalid duration '%s'", s)
+			}
+			return time.Duration(count) * unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration '%s'", s)
+	}
+	return d, nil
+}
+
+// ParseExpiry parses an expiry given either as a duration from now or as a
+// date (2006-01-02) or timestamp (RFC3339).
+func ParseExpiry(s string, now time.Time) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.UTC(), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, time.Local); err == nil {
+		return t.UTC(), nil
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid expiry '%s'", s)
+	}
+	return now.Add(d).UTC(), nil
+}
+
+// currentUser returns the name of the user running the tool.
+func currentUser() string {
+	if u, err := user.Current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name, ok := os.LookupEnv("USER"); ok {
+		return name
+	}
+	return os.Getenv("USERNAME")
+}
@@ -0,0 +1,70 @@
+package vault
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"90d": 90 * 24 * time.Hour,
+		"2w":  14 * 24 * time.Hour,
+		"12h": 12 * time.Hour,
+		"0d":  0,
+	}
+	for input, expected := range tests {
+		d, err := ParseDuration(input)
+		if err != nil || d != expected {
+			t.Errorf("ParseDuration(%q) = %v, %v, expected %v", input, d, err, expected)
+		}
+	}
+
+	for _, input := range []string{"", "d", "-1d", "abc", "-5h"} {
+		if _, err := ParseDuration(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
+
+func TestParseExpiry(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	expires, err := ParseExpiry("90d", now)
+	if err != nil || !expires.Equal(now.AddDate(0, 0, 90)) {
+		t.Errorf("Unexpected expiry %v (%v)", expires, err)
+	}
+
+	expires, err = ParseExpiry("2024-06-01T00:00:00Z", now)
+	if err != nil || !expires.Equal(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Unexpected expiry %v (%v)", expires, err)
+	}
+
+	if _, err := ParseExpiry("2024-06-01", now); err != nil {
+		t.Errorf("Expected a date to be accepted, got %v", err)
+	}
+
+	if _, err := ParseExpiry("soon", now); err == nil {
+		t.Error("Expected error for an invalid expiry")
+	}
+}
+
+func TestSecretMetadata_Expiry(t *testing.T) {
+	now := time.Now()
+
+	if (SecretMetadata{}).Expired(now) || (SecretMetadata{}).ExpiresWithin(now, time.Hour) {
+		t.Error("Expected a secret without expiry to never expire")
+	}
+
+	meta := SecretMetadata{Expires: now.Add(-time.Minute)}
+	if !meta.Expired(now) || !meta.ExpiresWithin(now, 0) {
+		t.Error("Expected the secret to be expired")
+	}
+
+	meta = SecretMetadata{Expires: now.Add(48 * time.Hour)}
+	if meta.Expired(now) {
+		t.Error("Expected the secret not to be expired")
+	}
+	if meta.ExpiresWithin(now, 24*time.Hour) || !meta.ExpiresWithin(now, 72*time.Hour) {
+		t.Error("Unexpected ExpiresWithin result")
+	}
+}
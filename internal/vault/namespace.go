@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// NamespaceSeparator separates the namespaces of a key, e.g.
+// payments/prod/DB_PASSWORD.
+const NamespaceSeparator = "/"
+
+// RenameProvider is implemented by providers that can rename keys in place,
+// keeping their history and metadata.
+type RenameProvider interface {
+	VaultRenameKeys(renames map[string]string) error
+}
+
+// ValidateKey checks that a key is not empty and that none of its
+// namespaces is empty.
+func ValidateKey(key string) error {
+	if key == "" {
+		return errors.New("key is empty")
+	}
+	if strings.ContainsAny(key, "\n\r") {
+		return fmt.Errorf("key '%s' contains a line break", key)
+	}
+	for _, part := range strings.Split(key, NamespaceSeparator) {
+		if part == "" {
+			return fmt.Errorf("key '%s' has an empty namespace", key)
+		}
+	}
+	return nil
+}
+
+// JoinKey returns the key inside the namespace.
+func JoinKey(namespace, key string) string {
+	namespace = strings.Trim(namespace, NamespaceSeparator)
+	if namespace == "" {
+		return key
+	}
+	return namespace + NamespaceSeparator + key
+}
+
+// FilterKeys returns the keys inside the namespace, sorted, an empty
+// namespace matches every key.
+func FilterKeys(keys []string, namespace string) []string {
+	prefix := strings.Trim(namespace, NamespaceSeparator)
+	if prefix != "" {
+		prefix += NamespaceSeparator
+	}
+
+	filtered := []string{}
+	for _, key := range keys {
+		if strings.HasPrefix(key, prefix) {
+			filtered = append(filtered, key)
+		}
+	}
+	sort.Strings(filtered)
+	return filtered
+}
+
+// MoveKeys computes the renames needed to move src to dst. If src is a key
+// it is renamed to dst, or moved into dst if dst ends with the separator.
+// Otherwise src is a namespace and every key inside it is moved under dst.
+func MoveKeys(keys []string, src, dst string) (map[string]string, error) {
+	existing := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		existing[key] = true
+	}
+
+	renames := map[string]string{}
+	if existing[src] {
+		target := dst
+		if strings.HasSuffix(dst, NamespaceSeparator) {
+			parts := strings.Split(src, NamespaceSeparator)
+			target = JoinKey(dst, parts[len(parts)-1])
+		}
+		renames[src] = target
+	} else {
+		namespace := strings.Trim(src, NamespaceSeparator)
+		for _, key := range FilterKeys(keys, namespace) {
+			renames[key] = JoinKey(dst, strings.TrimPrefix(key, namespace+NamespaceSeparator))
+		}
+	}
+
+	if len(renames) == 0 {
+		return nil, fmt.Errorf("key or namespace '%s' not found in vault", src)
+	}
+
+	for from, to := range renames {
+		if err := ValidateKey(to); err != nil {
+			return nil, err
+		}
+		if existing[to] && renames[to] == "" {
+			return nil, fmt.Errorf("key '%s' already exists, moving '%s'", to, from)
+		}
+	}
+
+	return renames, nil
+}
@@ -0,0 +1,63 @@
+package vault
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateKey(t *testing.T) {
+	for _, key := range []string{"KEY", "payments/prod/DB_PASSWORD", "a.b/c-d"} {
+		if err := ValidateKey(key); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", key, err)
+		}
+	}
+
+	for _, key := range []string{"", "/KEY", "KEY/", "a//b", "a\nb"} {
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("Expected %q to be invalid", key)
+		}
+	}
+}
+
+func TestFilterKeys(t *testing.T) {
+	keys := []string{"ROOT", "payments/prod/DB", "payments/dev/DB", "paymentsx/DB"}
+
+	if filtered := FilterKeys(keys, ""); len(filtered) != 4 {
+		t.Errorf("Expected all keys, got %v", filtered)
+	}
+
+	expected := []string{"payments/dev/DB", "payments/prod/DB"}
+	for _, namespace := range []string{"payments", "payments/"} {
+		if filtered := FilterKeys(keys, namespace); !reflect.DeepEqual(filtered, expected) {
+			t.Errorf("FilterKeys(%q) = %v, expected %v", namespace, filtered, expected)
+		}
+	}
+}
+
+func TestMoveKeys(t *testing.T) {
+	keys := []string{"ROOT", "payments/prod/DB", "payments/prod/API", "billing/DB"}
+
+	renames, err := MoveKeys(keys, "ROOT", "shared/ROOT")
+	if err != nil || !reflect.DeepEqual(renames, map[string]string{"ROOT": "shared/ROOT"}) {
+		t.Errorf("Unexpected renames %v (%v)", renames, err)
+	}
+
+	renames, err = MoveKeys(keys, "ROOT", "shared/")
+	if err != nil || renames["ROOT"] != "shared/ROOT" {
+		t.Errorf("Expected key to be moved into the namespace, got %v (%v)", renames, err)
+	}
+
+	renames, err = MoveKeys(keys, "payments/prod", "payments/live")
+	expected := map[string]string{"payments/prod/DB": "payments/live/DB", "payments/prod/API": "payments/live/API"}
+	if err != nil || !reflect.DeepEqual(renames, expected) {
+		t.Errorf("Unexpected renames %v (%v)", renames, err)
+	}
+
+	if _, err := MoveKeys(keys, "missing", "other"); err == nil {
+		t.Error("Expected error for a missing key")
+	}
+
+	if _, err := MoveKeys(keys, "payments/prod/DB", "billing/DB"); err == nil {
+		t.Error("Expected error when the destination exists")
+	}
+}
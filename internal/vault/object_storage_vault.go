@@ -6,12 +6,17 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"filippo.io/age"
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/a13labs/sectool/internal/fsutil"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awsconfig "github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -21,11 +26,27 @@ import (
 // ObjectStorageVault represents a secure key-value store stored in an S3 bucket.
 type ObjectStorageVault struct {
 	VaultProvider
-	client   *s3.Client
-	bucket   string
-	key      []byte
-	fileName string
-	backup   bool
+	client     *s3.Client
+	bucket     string
+	location   string
+	context    []byte
+	key        []byte
+	identities []age.Identity
+	kdf        crypto.KDFParams
+	fileName   string
+	cachePath  string
+	backup     bool
+	retention  RetentionPolicy
+	history    int
+}
+
+// objectStorageVaultID returns the ID the vault is bound to, its bucket and
+// file name unless configured.
+func objectStorageVaultID(c *config.ObjectStorageConfig) string {
+	if c.ID != "" {
+		return c.ID
+	}
+	return c.Bucket + "/repository.vault"
 }
 
 // NewObjectStorageVault creates a new ObjectStorageVault instance.
@@ -34,14 +55,30 @@ func NewObjectStorageVault(c *config.ObjectStorageConfig) (*ObjectStorageVault,
 		return nil, errors.New("object storage configuration is nil")
 	}
 
+	identities, err := loadIdentities(identityFile(c.Identity))
+	if err != nil {
+		return nil, err
+	}
+
 	vaultKey := c.Key
+	if vaultKey == "" && c.SSHAgent != nil {
+		vaultKey, err = AgentVaultKey(c.SSHAgent, objectStorageVaultID(c))
+		if err != nil {
+			return nil, err
+		}
+	}
 	if vaultKey == "" {
 		vaultKey, _ = os.LookupEnv("FILE_VAULT_KEY")
-		if vaultKey == "" {
+		if vaultKey == "" && len(identities) == 0 {
 			return nil, errors.New("FILE_VAULT_KEY is not defined")
 		}
 	}
 
+	kdf, err := kdfParams(c.KDF)
+	if err != nil {
+		return nil, err
+	}
+
 	awsConfig, err := awsconfig.LoadDefaultConfig(context.TODO(),
 		awsconfig.WithRegion(c.Region),
 		awsconfig.LoadOptionsFunc(func(o *awsconfig.LoadOptions) error {
@@ -58,47 +95,101 @@ func NewObjectStorageVault(c *config.ObjectStorageConfig) (*ObjectStorageVault,
 	client := s3.NewFromConfig(awsConfig)
 
 	return &ObjectStorageVault{
-		client:   client,
-		bucket:   c.Bucket,
-		key:      []byte(vaultKey),
-		fileName: "repository.vault",
-		backup:   false,
+		client:     client,
+		bucket:     c.Bucket,
+		location:   c.Endpoint + "/" + c.Bucket + "/repository.vault",
+		context:    vaultContext(objectStorageVaultID(c)),
+		key:        []byte(vaultKey),
+		identities: identities,
+		kdf:        kdf,
+		fileName:   "repository.vault",
+		cachePath:  localCachePath(c, "repository.vault"),
+		backup:     backupEnabled(c.Backup),
+		retention:  retentionPolicy(c.Retention),
+		history:    historySize(c.History),
 	}, nil
 }
 
-// readVault reads the vault file contents from the S3 bucket.
-func (v *ObjectStorageVault) readVault() (string, error) {
-	output, err := v.client.GetObject(context.TODO(), &s3.GetObjectInput{
-		Bucket: aws.String(v.bucket),
-		Key:    aws.String(v.fileName),
-	})
+// localCachePath returns the local encrypted copy of the vault used while it
+// is unlocked, named after the location of the vault.
+func localCachePath(c *config.ObjectStorageConfig, fileName string) string {
+	dir, err := os.UserCacheDir()
 	if err != nil {
-		if isNotFoundError(err) {
-			return "", nil
+		dir = os.TempDir()
+	}
+	name := digest([]byte(c.Endpoint + "\x00" + c.Bucket + "\x00" + fileName))[:16]
+	return filepath.Join(dir, "sectool", name+".vault")
+}
+
+// readVault reads and decodes the vault file contents from the S3 bucket, or
+// from the local cache while the vault is unlocked.
+func (v *ObjectStorageVault) readVault() (*vaultDocument, error) {
+	doc, err := v.readCache()
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil {
+		if !doc.expired(time.Now()) {
+			return doc, nil
+		}
+		err := v.lockExpired(doc)
+		doc.close()
+		if err != nil {
+			return nil, err
 		}
-		return "", err
 	}
-	defer output.Body.Close()
 
-	decryptedContents, err := crypto.DecryptFromReader(output.Body, v.key)
+	data, err := v.readRemote()
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	return decryptedContents, nil
+	doc, err = decryptVault(data, v.context, v.key, v.identities)
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing vault is a new one, its counter continues from the last seen
+	if len(data) > 0 {
+		if err := checkCounter(v.location, doc.counter); err != nil {
+			doc.close()
+			return nil, err
+		}
+	}
+	return doc, nil
 }
 
-// writeVault writes encrypted data to the vault file in the S3 bucket.
-func (v *ObjectStorageVault) writeVault(contents string) error {
+// writeVault encodes and writes encrypted data to the vault file in the S3
+// bucket, or to the local cache while the vault is unlocked.
+func (v *ObjectStorageVault) writeVault(doc *vaultDocument) error {
+	if doc.unlocked() {
+		return v.writeCache(doc)
+	}
+
 	if v.backup {
-		backupName := v.vaultBackupName()
-		err := v.backupVault(backupName)
+		backupName, err := v.vaultBackupName()
+		if err != nil {
+			return err
+		}
+		err = v.backupVault(backupName)
+		if err != nil {
+			return err
+		}
+
+		_, err = v.pruneBackups(v.retention)
 		if err != nil {
 			return err
 		}
 	}
 
-	encryptedData, err := crypto.EncryptToBytes(contents, v.key)
+	// The counter never goes back, even if the vault was replaced
+	seen, err := seenCounter(v.location)
+	if err != nil {
+		return err
+	}
+	doc.counter = max(doc.counter, seen)
+
+	encryptedData, err := encryptVault(doc, v.context, v.key, v.kdf)
 	if err != nil {
 		return err
 	}
@@ -108,32 +199,120 @@ func (v *ObjectStorageVault) writeVault(contents string) error {
 		Key:    aws.String(v.fileName),
 		Body:   bytes.NewReader(encryptedData),
 	})
-	return err
+	if err != nil {
+		return err
+	}
+
+	return recordCounter(v.location, doc.counter, false)
 }
 
-// backupVault creates a backup of the vault file in the S3 bucket.
+// backupVault creates a backup of the vault file in the S3 bucket, a missing
+// vault is skipped.
 func (v *ObjectStorageVault) backupVault(backupName string) error {
+	data, err := v.readObject(v.fileName)
+	if err != nil {
+		if isNotFoundError(err) {
+			return nil
+		}
+		return err
+	}
+
+	return v.writeObject(backupName, data)
+}
+
+// vaultBackupName generates a backup filename with a timestamp, moving to
+// the next second if a backup was already taken in the current one.
+func (v *ObjectStorageVault) vaultBackupName() (string, error) {
+	backups, err := v.listBackups()
+	if err != nil {
+		return "", err
+	}
+
+	taken := make(map[string]bool, len(backups))
+	for _, backup := range backups {
+		taken[backup] = true
+	}
+
+	t := time.Now()
+	name := backupName(v.fileName, t)
+	for taken[name] {
+		t = t.Add(time.Second)
+		name = backupName(v.fileName, t)
+	}
+	return name, nil
+}
+
+// pruneBackups removes the backups not retained by the policy.
+func (v *ObjectStorageVault) pruneBackups(policy RetentionPolicy) ([]string, error) {
+	names, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, backup := range expiredBackups(parseBackups(v.fileName, names), policy, time.Now()) {
+		if err := v.deleteObject(backup.Name); err != nil {
+			return removed, err
+		}
+		removed = append(removed, backup.Name)
+	}
+
+	return removed, nil
+}
+
+// listBackups returns the backup objects of the vault, oldest first.
+func (v *ObjectStorageVault) listBackups() ([]string, error) {
+	var backups []string
+
+	paginator := s3.NewListObjectsV2Paginator(v.client, &s3.ListObjectsV2Input{
+		Bucket: aws.String(v.bucket),
+		Prefix: aws.String(v.fileName + "_"),
+	})
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(context.TODO())
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			backups = append(backups, aws.ToString(object.Key))
+		}
+	}
+
+	sort.Strings(backups)
+	return backups, nil
+}
+
+// readObject reads the raw contents of an object from the S3 bucket.
+func (v *ObjectStorageVault) readObject(name string) ([]byte, error) {
 	output, err := v.client.GetObject(context.TODO(), &s3.GetObjectInput{
 		Bucket: aws.String(v.bucket),
-		Key:    aws.String(v.fileName),
+		Key:    aws.String(name),
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer output.Body.Close()
 
-	_, err = v.client.PutObject(context.TODO(), &s3.PutObjectInput{
+	return io.ReadAll(output.Body)
+}
+
+// writeObject writes raw contents to an object in the S3 bucket.
+func (v *ObjectStorageVault) writeObject(name string, data []byte) error {
+	_, err := v.client.PutObject(context.TODO(), &s3.PutObjectInput{
 		Bucket: aws.String(v.bucket),
-		Key:    aws.String(backupName),
-		Body:   output.Body,
+		Key:    aws.String(name),
+		Body:   bytes.NewReader(data),
 	})
 	return err
 }
 
-// vaultBackupName generates a backup filename with a timestamp.
-func (v *ObjectStorageVault) vaultBackupName() string {
-	timestamp := time.Now().Format("20060102150405")
-	return fmt.Sprintf("%s_%s", v.fileName, timestamp)
+// deleteObject removes an object from the S3 bucket.
+func (v *ObjectStorageVault) deleteObject(name string) error {
+	_, err := v.client.DeleteObject(context.TODO(), &s3.DeleteObjectInput{
+		Bucket: aws.String(v.bucket),
+		Key:    aws.String(name),
+	})
+	return err
 }
 
 // vaultFileExists checks if the vault file exists in the S3 bucket.
@@ -151,111 +330,179 @@ func (v *ObjectStorageVault) Initialize() error {
 		return nil
 	}
 
-	return v.writeVault("")
+	doc, err := decryptVault(nil, v.context, v.key, v.identities)
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	return v.writeVault(doc)
 }
 
 // VaultHasKey checks if the vault contains the specified key.
 func (v *ObjectStorageVault) VaultHasKey(key string) bool {
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return false
 	}
+	defer doc.close()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			return true
-		}
-	}
-
-	return false
+	return doc.has(key)
 }
 
 // VaultGetValue returns the value of a key from the vault.
 func (v *ObjectStorageVault) VaultGetValue(key string) (string, error) {
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return "", err
 	}
+	defer doc.close()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			return parts[1], nil
-		}
+	value, ok := doc.get(key)
+	if !ok {
+		return "", errors.New("key not found in vault")
 	}
 
-	return "", errors.New("key not found in vault")
+	return value, nil
 }
 
 // VaultListKeys lists all keys in the vault.
 func (v *ObjectStorageVault) VaultListKeys() []string {
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return []string{}
 	}
+	defer doc.close()
 
-	var keys []string
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			keys = append(keys, parts[0])
-		}
-	}
-
-	return keys
+	return doc.keys()
 }
 
 // VaultSetValue sets the value of a key in the vault.
 func (v *ObjectStorageVault) VaultSetValue(key, value string) error {
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return err
 	}
+	defer doc.close()
 
-	lines := strings.Split(contents, "\n")
-	for i, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			lines[i] = fmt.Sprintf("%s=%s", key, value)
-			updatedContents := strings.Join(lines, "\n")
-			return v.writeVault(updatedContents)
-		}
+	doc.set(key, value, v.history)
+	return v.writeVault(doc)
+}
+
+// VaultSetValueWithMetadata sets the value and metadata of a key in the vault.
+func (v *ObjectStorageVault) VaultSetValueWithMetadata(key, value string, meta SecretMetadata) error {
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	doc.set(key, value, v.history)
+	doc.setMetadata(key, meta)
+	return v.writeVault(doc)
+}
+
+// VaultGetMetadata returns the metadata of a key in the vault.
+func (v *ObjectStorageVault) VaultGetMetadata(key string) (SecretMetadata, error) {
+	doc, err := v.readVault()
+	if err != nil {
+		return SecretMetadata{}, err
+	}
+	defer doc.close()
+
+	meta, ok := doc.metadata(key)
+	if !ok {
+		return SecretMetadata{}, errors.New("key not found in vault")
+	}
+
+	return meta, nil
+}
+
+// VaultListMetadata returns the metadata of all keys in the vault.
+func (v *ObjectStorageVault) VaultListMetadata() (map[string]SecretMetadata, error) {
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
 	}
+	defer doc.close()
 
-	lines = append(lines, fmt.Sprintf("%s=%s", key, value))
-	updatedContents := strings.Join(lines, "\n")
-	return v.writeVault(updatedContents)
+	return doc.listMetadata(), nil
 }
 
 // VaultDelKey deletes a key from the vault.
 func (v *ObjectStorageVault) VaultDelKey(key string) error {
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return err
 	}
+	defer doc.close()
 
-	var updatedLines []string
-	lines := strings.Split(contents, "\n")
-	keyFound := false
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 && parts[0] == key {
-			keyFound = true
-			continue
-		}
-		updatedLines = append(updatedLines, line)
+	if !doc.del(key) {
+		return errors.New("key not found in vault")
 	}
 
-	if !keyFound {
-		return errors.New("key not found in vault")
+	return v.writeVault(doc)
+}
+
+// VaultRenameKeys renames keys in the vault in a single write.
+func (v *ObjectStorageVault) VaultRenameKeys(renames map[string]string) error {
+	doc, err := v.readVault()
+	if err != nil {
+		return err
 	}
+	defer doc.close()
 
-	updatedContents := strings.Join(updatedLines, "\n")
-	return v.writeVault(updatedContents)
+	if err := doc.rename(renames); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultListMembers returns the recipients the vault is encrypted to.
+func (v *ObjectStorageVault) VaultListMembers() ([]string, error) {
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	return doc.Recipients, nil
+}
+
+// VaultAddMembers encrypts the vault to additional recipients.
+func (v *ObjectStorageVault) VaultAddMembers(recipients []string) error {
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.addMembers(recipients, v.identities); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultRemoveMembers removes recipients from the vault, the key-encryption
+// key is rotated so removed members can't unwrap new data keys.
+func (v *ObjectStorageVault) VaultRemoveMembers(recipients []string) error {
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.removeMembers(recipients); err != nil {
+		return err
+	}
+
+	if err := doc.rotate(); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
 }
 
 // VaultEnableBackup enables or disables vault backups.
@@ -265,7 +512,9 @@ func (v *ObjectStorageVault) VaultEnableBackup(value bool) {
 
 // GetSensitiveStrings returns the sensitive strings in the vault.
 func (v *ObjectStorageVault) SetSensitiveStrings(kv *crypto.SecureKVStore) {
-	kv.Put("SECTOOL_OS_SENSITIVE_1", string(v.key))
+	if len(v.key) > 0 {
+		kv.PutBytes("SECTOOL_OS_SENSITIVE_1", v.key)
+	}
 }
 
 // isNotFoundError checks if an error is an S3 not found error.
@@ -277,22 +526,451 @@ func isNotFoundError(err error) bool {
 // VaultGetMultipleValues returns the values of multiple keys from the vault.
 func (v *ObjectStorageVault) VaultGetMultipleValues(keys []string, kv *crypto.SecureKVStore) error {
 
-	contents, err := v.readVault()
+	doc, err := v.readVault()
 	if err != nil {
 		return err
 	}
+	defer doc.close()
 
-	lines := strings.Split(contents, "\n")
-	for _, line := range lines {
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			for _, key := range keys {
-				if parts[0] == key {
-					kv.Put(parts[0], parts[1])
-				}
+	for _, key := range keys {
+		if value, ok := doc.getBytes(key); ok {
+			kv.PutBytes(key, value)
+		}
+	}
+
+	return nil
+}
+
+// Rekey re-encrypts the vault and its backups with a new key, or removes the
+// backups if purgeBackups is set. A vault with members is refused unless
+// dropMembers is set. It returns the list of rewritten or removed objects.
+func (v *ObjectStorageVault) Rekey(newKey []byte, purgeBackups bool, dropMembers bool) ([]string, error) {
+	if len(newKey) == 0 {
+		return nil, errors.New("new key is empty")
+	}
+
+	if v.cacheExists() {
+		return nil, ErrVaultUnlocked
+	}
+
+	backups, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// The live vault goes last so it is only replaced once every backup is done
+	targets := []string{}
+	if !purgeBackups {
+		targets = append(targets, backups...)
+	}
+	targets = append(targets, v.fileName)
+
+	// Decrypt and re-encrypt everything first, nothing is written if any
+	// artifact can't be decrypted with the current key. The key-encryption
+	// key is rotated as well, so the old key can't unwrap new data keys.
+	rewritten := make(map[string][]byte, len(targets))
+	for _, target := range targets {
+		data, err := v.readObject(target)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read '%s': %w", target, err)
+		}
+
+		doc, err := decryptVault(data, v.context, v.key, v.identities)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt '%s': %w", target, err)
+		}
+
+		// The new key replaces the members of the vault, only if asked to
+		if len(doc.Recipients) > 0 && !dropMembers {
+			doc.close()
+			return nil, fmt.Errorf("%w, members remove rotates its key: '%s'", ErrVaultHasMembers, target)
+		}
+		doc.Recipients = nil
+		if err := doc.rotate(); err != nil {
+			doc.close()
+			return nil, err
+		}
+
+		encryptedData, err := encryptVault(doc, v.context, newKey, v.kdf)
+		doc.close()
+		if err != nil {
+			return nil, err
+		}
+		rewritten[target] = encryptedData
+	}
+
+	// Every object is written under a staging name first, so a failed write
+	// leaves all of them under the current key
+	staged := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if err := v.writeObject(v.rekeyStagingName(target), rewritten[target]); err != nil {
+			v.deleteStaged(staged)
+			return nil, fmt.Errorf("failed to stage '%s': %w", target, err)
+		}
+		staged = append(staged, v.rekeyStagingName(target))
+	}
+
+	// The report lists the objects switched to the new key, if switching
+	// fails the staged copies are kept so the remaining ones can be recovered
+	report := make([]string, 0, len(targets))
+	for _, target := range targets {
+		if err := v.writeObject(target, rewritten[target]); err != nil {
+			return report, fmt.Errorf("failed to write '%s', the new versions are staged as '%s*': %w", target, v.fileName+rekeyStagingSuffix, err)
+		}
+		report = append(report, target)
+	}
+	v.deleteStaged(staged)
+
+	v.key = newKey
+
+	if purgeBackups {
+		for _, backup := range backups {
+			if err := v.deleteObject(backup); err != nil {
+				return report, err
 			}
+			report = append(report, backup+" (removed)")
 		}
 	}
 
+	return report, nil
+}
+
+// rekeyStagingSuffix follows the vault name in the staging objects of a rekey,
+// they don't share the prefix of the backups.
+const rekeyStagingSuffix = ".rekey"
+
+// VaultMigrate re-encrypts the vault and its backups written before they were
+// bound to the vault context, with the same key or members. It returns the
+// list of rewritten objects.
+func (v *ObjectStorageVault) VaultMigrate() ([]string, error) {
+	if v.cacheExists() {
+		return nil, ErrVaultUnlocked
+	}
+
+	backups, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	// The live vault goes last, its counter is recorded once it is written
+	targets := append(backups, v.fileName)
+
+	report := []string{}
+	for _, target := range targets {
+		data, err := v.readObject(target)
+		if err != nil && !isNotFoundError(err) {
+			return report, fmt.Errorf("failed to read '%s': %w", target, err)
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		doc, err := decryptUnboundVault(data, v.context, v.key, v.identities)
+		if err != nil {
+			return report, fmt.Errorf("failed to decrypt '%s': %w", target, err)
+		}
+		if doc == nil {
+			continue
+		}
+
+		if target == v.fileName {
+			seen, err := seenCounter(v.location)
+			if err != nil {
+				doc.close()
+				return report, err
+			}
+			doc.counter = max(doc.counter, seen)
+		}
+
+		encryptedData, err := encryptVault(doc, v.context, v.key, v.kdf)
+		doc.close()
+		if err != nil {
+			return report, err
+		}
+		if err := v.writeObject(target, encryptedData); err != nil {
+			return report, err
+		}
+		report = append(report, target)
+
+		if target == v.fileName {
+			if err := recordCounter(v.location, doc.counter, false); err != nil {
+				return report, err
+			}
+		}
+	}
+
+	return report, nil
+}
+
+// rekeyStagingName returns the object a rekeyed object is staged as.
+func (v *ObjectStorageVault) rekeyStagingName(target string) string {
+	return v.fileName + rekeyStagingSuffix + strings.TrimPrefix(target, v.fileName)
+}
+
+// deleteStaged removes the staging objects of a rekey, best effort.
+func (v *ObjectStorageVault) deleteStaged(names []string) {
+	for _, name := range names {
+		_ = v.deleteObject(name)
+	}
+}
+
+// VaultKeyHistory returns the current and previous versions of a key.
+func (v *ObjectStorageVault) VaultKeyHistory(key string) ([]SecretVersion, error) {
+	doc, err := v.readVault()
+	if err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	versions, ok := doc.history(key)
+	if !ok {
+		return nil, errors.New("key not found in vault")
+	}
+
+	return versions, nil
+}
+
+// VaultRollback restores a previous version of a key.
+func (v *ObjectStorageVault) VaultRollback(key string, version int) error {
+	doc, err := v.readVault()
+	if err != nil {
+		return err
+	}
+	defer doc.close()
+
+	if err := doc.rollback(key, version, v.history); err != nil {
+		return err
+	}
+
+	return v.writeVault(doc)
+}
+
+// VaultListBackups lists the backups of the vault, newest first.
+func (v *ObjectStorageVault) VaultListBackups() ([]Backup, error) {
+	names, err := v.listBackups()
+	if err != nil {
+		return nil, err
+	}
+
+	return parseBackups(v.fileName, names), nil
+}
+
+// VaultRestoreBackup replaces the vault with a backup, after checking the
+// backup can be decrypted with the vault key.
+func (v *ObjectStorageVault) VaultRestoreBackup(name string) error {
+	if v.cacheExists() {
+		return ErrVaultUnlocked
+	}
+
+	names, err := v.listBackups()
+	if err != nil {
+		return err
+	}
+
+	backup, ok := resolveBackup(parseBackups(v.fileName, names), name)
+	if !ok {
+		return fmt.Errorf("backup '%s' not found", name)
+	}
+
+	data, err := v.readObject(backup.Name)
+	if err != nil {
+		return err
+	}
+
+	doc, err := decryptVault(data, v.context, v.key, v.identities)
+	if err != nil {
+		return fmt.Errorf("backup '%s' can't be restored: %w", backup.Name, err)
+	}
+	defer doc.close()
+
+	// The backup is written as a new version of the vault, so the restore
+	// isn't taken for a rollback. The current vault is backed up first when
+	// backups are enabled, so the restore can be undone.
+	return v.writeVault(doc)
+}
+
+// VaultPruneBackups removes the backups not retained by the policy, or by the
+// configured policy if nil.
+func (v *ObjectStorageVault) VaultPruneBackups(policy *RetentionPolicy) ([]string, error) {
+	if policy == nil {
+		policy = &v.retention
+	}
+
+	return v.pruneBackups(*policy)
+}
+
+// cacheExists reports whether the vault is unlocked to the local cache.
+func (v *ObjectStorageVault) cacheExists() bool {
+	_, err := os.Stat(v.cachePath)
+	return err == nil
+}
+
+// readCache reads the local cache of the unlocked vault, or returns nil if
+// the vault is locked.
+func (v *ObjectStorageVault) readCache() (*vaultDocument, error) {
+	contents, err := os.ReadFile(v.cachePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := decryptVault(contents, v.context, v.key, v.identities)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt '%s': %w", v.cachePath, err)
+	}
+	if doc.UnlockedUntil == nil {
+		doc.UnlockedUntil = &time.Time{}
+	}
+	return doc, nil
+}
+
+// lockExpired uploads the expired local cache, it fails if the vault changed
+// in the bucket since it was unlocked as those changes would be lost.
+func (v *ObjectStorageVault) lockExpired(doc *vaultDocument) error {
+	if err := v.lockCache(doc, false); err != nil {
+		return fmt.Errorf("the unlocked vault expired but can't be locked: %w", err)
+	}
+	fmt.Fprintf(os.Stderr, "The unlocked vault expired, '%s' was locked again.\n", v.cachePath)
 	return nil
 }
+
+// writeCache encrypts the unlocked vault to the local cache.
+func (v *ObjectStorageVault) writeCache(doc *vaultDocument) error {
+	if err := os.MkdirAll(filepath.Dir(v.cachePath), 0700); err != nil {
+		return err
+	}
+
+	encryptedData, err := encryptVault(doc, v.context, v.key, v.kdf)
+	if err != nil {
+		return err
+	}
+
+	return fsutil.WriteFileAtomic(v.cachePath, encryptedData, fsutil.DefaultFileMode)
+}
+
+// readRemote returns the raw contents of the vault in the S3 bucket, nil if
+// it doesn't exist yet.
+func (v *ObjectStorageVault) readRemote() ([]byte, error) {
+	data, err := v.readObject(v.fileName)
+	if err != nil && isNotFoundError(err) {
+		return nil, nil
+	}
+	return data, err
+}
+
+// lockCache uploads the unlocked vault and removes the local cache. Unless
+// forced it fails if the vault changed in the bucket since it was unlocked.
+func (v *ObjectStorageVault) lockCache(doc *vaultDocument, force bool) error {
+	data, err := v.readRemote()
+	if err != nil {
+		return err
+	}
+	if !force && doc.stale(data) {
+		return ErrStaleWorkingCopy
+	}
+
+	doc.UnlockedUntil, doc.UnlockedFrom = nil, ""
+	if err := v.writeVault(doc); err != nil {
+		return err
+	}
+
+	return os.Remove(v.cachePath)
+}
+
+// VaultUnlockFor copies the vault to a local encrypted cache used by the
+// following reads and writes, so they don't reach the bucket until it is
+// locked or expires. Unlocking an unlocked vault changes its expiry.
+func (v *ObjectStorageVault) VaultUnlockFor(d time.Duration) (*WorkingCopy, error) {
+	if d <= 0 {
+		return nil, errors.New("the vault can only be unlocked for a positive duration")
+	}
+
+	doc, err := v.readCache()
+	if err != nil {
+		return nil, err
+	}
+	if doc != nil && doc.expired(time.Now()) {
+		err := v.lockExpired(doc)
+		doc.close()
+		if err != nil {
+			return nil, err
+		}
+		doc = nil
+	}
+
+	data, err := v.readRemote()
+	if err != nil {
+		doc.close()
+		return nil, err
+	}
+	if doc == nil {
+		if doc, err = decryptVault(data, v.context, v.key, v.identities); err != nil {
+			return nil, err
+		}
+		if len(data) > 0 {
+			if err := checkCounter(v.location, doc.counter); err != nil {
+				doc.close()
+				return nil, err
+			}
+		}
+	}
+	defer doc.close()
+
+	doc.unlock(data, time.Now().Add(d))
+	if err := v.writeCache(doc); err != nil {
+		return nil, err
+	}
+
+	return doc.workingCopy(v.cachePath, data), nil
+}
+
+// VaultLockWorkingCopy uploads the local cache to the bucket and removes it.
+// Unless forced it fails with ErrStaleWorkingCopy if the vault changed in the
+// bucket since it was unlocked.
+func (v *ObjectStorageVault) VaultLockWorkingCopy(force bool) error {
+	doc, err := v.readCache()
+	if doc == nil || err != nil {
+		return err
+	}
+	defer doc.close()
+
+	return v.lockCache(doc, force)
+}
+
+// VaultDiscardWorkingCopy removes the local cache, dropping its changes.
+func (v *ObjectStorageVault) VaultDiscardWorkingCopy() error {
+	err := os.Remove(v.cachePath)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// VaultWorkingCopy describes the local cache, or returns nil if the vault is
+// locked.
+func (v *ObjectStorageVault) VaultWorkingCopy() (*WorkingCopy, error) {
+	doc, err := v.readCache()
+	if doc == nil || err != nil {
+		return nil, err
+	}
+	defer doc.close()
+
+	data, err := v.readRemote()
+	if err != nil {
+		return nil, err
+	}
+	return doc.workingCopy(v.cachePath, data), nil
+}
+
+// Lock uploads the local cache of the unlocked vault.
+func (v *ObjectStorageVault) Lock() error {
+	return v.VaultLockWorkingCopy(false)
+}
+
+// Unlock copies the vault to the local cache for the default duration.
+func (v *ObjectStorageVault) Unlock() error {
+	_, err := v.VaultUnlockFor(defaultUnlockDuration)
+	return err
+}
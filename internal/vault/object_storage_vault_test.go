@@ -0,0 +1,102 @@
+package vault
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/a13labs/sectool/internal/crypto"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeBucket is an in memory S3 bucket serving the object requests of the
+// vault, with path style addressing.
+type fakeBucket struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (b *fakeBucket) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		b.objects[r.URL.Path] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := b.objects[r.URL.Path]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, "<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>")
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(b.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusNotImplemented)
+	}
+}
+
+// newTestObjectStorageVault returns a vault stored in a fake bucket.
+func newTestObjectStorageVault(t *testing.T) (*ObjectStorageVault, *fakeBucket) {
+	bucket := &fakeBucket{objects: map[string][]byte{}}
+	server := httptest.NewServer(bucket)
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		Region:                     "us-east-1",
+		BaseEndpoint:               aws.String(server.URL),
+		UsePathStyle:               true,
+		Credentials:                aws.AnonymousCredentials{},
+		RequestChecksumCalculation: aws.RequestChecksumCalculationWhenRequired,
+		ResponseChecksumValidation: aws.ResponseChecksumValidationWhenRequired,
+	})
+
+	return &ObjectStorageVault{
+		client:    client,
+		bucket:    "test",
+		location:  server.URL + "/test/repository.vault",
+		context:   vaultContext("test/repository.vault"),
+		key:       []byte("password"),
+		kdf:       crypto.DefaultKDFParams,
+		fileName:  "repository.vault",
+		cachePath: filepath.Join(t.TempDir(), "repository.vault"),
+	}, bucket
+}
+
+func TestObjectStorageVault_Initialize(t *testing.T) {
+	vault, bucket := newTestObjectStorageVault(t)
+
+	if err := vault.Initialize(); err != nil {
+		t.Fatalf("Expected the vault to be created, got %v", err)
+	}
+	if _, ok := bucket.objects["/test/repository.vault"]; !ok {
+		t.Fatal("Expected the vault to be written to the bucket")
+	}
+	if keys := vault.VaultListKeys(); len(keys) != 0 {
+		t.Errorf("Expected an empty vault, got %v", keys)
+	}
+
+	if err := vault.VaultSetValue("KEY", "value"); err != nil {
+		t.Fatal(err)
+	}
+	// Initializing an existing vault leaves it untouched
+	if err := vault.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if value, err := vault.VaultGetValue("KEY"); err != nil || value != "value" {
+		t.Errorf("Expected 'value', got '%s' (%v)", value, err)
+	}
+}
@@ -2,6 +2,8 @@ package vault
 
 import (
 	"errors"
+	"fmt"
+	"time"
 
 	"github.com/a13labs/sectool/internal/config"
 	"github.com/a13labs/sectool/internal/crypto"
@@ -15,12 +17,55 @@ type VaultProvider interface {
 	VaultDelKey(key string) error
 	VaultHasKey(key string) bool
 	VaultEnableBackup(value bool)
+	// SetSensitiveStrings stores the credentials of the provider, so they are
+	// hidden from the output of exec. Providers holding them as strings
+	// leave copies that can't be wiped.
 	SetSensitiveStrings(*crypto.SecureKVStore)
 	VaultGetMultipleValues(keys []string, kv *crypto.SecureKVStore) error
 	Lock() error
 	Unlock() error
 }
 
+// ErrNotSupported is returned when an operation is not supported by a provider.
+var ErrNotSupported = errors.New("operation not supported by the vault provider")
+
+// defaultHistorySize is the number of previous versions kept per secret.
+const defaultHistorySize = 5
+
+// SecretVersion represents a version of a secret.
+type SecretVersion struct {
+	Version int
+	Value   string
+	Updated time.Time
+	Current bool
+}
+
+// HistoryProvider is implemented by providers that keep previous versions of
+// secrets.
+type HistoryProvider interface {
+	VaultKeyHistory(key string) ([]SecretVersion, error)
+	VaultRollback(key string, version int) error
+}
+
+// RekeyProvider is implemented by providers that support rotating the
+// encryption key of the vault and its backups. A vault with members is only
+// switched back to a key, dropping its members, if dropMembers is set.
+type RekeyProvider interface {
+	Rekey(newKey []byte, purgeBackups bool, dropMembers bool) ([]string, error)
+}
+
+// MigrateProvider is implemented by providers that can bind a vault and its
+// backups, written before they were bound to the vault context, to it.
+type MigrateProvider interface {
+	VaultMigrate() ([]string, error)
+}
+
+// VersionProvider is implemented by providers that can tell cheaply whether
+// the vault changed, so a copy of its contents can be reused until it does.
+type VersionProvider interface {
+	VaultVersion() (string, error)
+}
+
 // NewVaultProvider creates a new vault provider based on the configuration.
 func NewVaultProvider(cfg config.Config) (VaultProvider, error) {
 
@@ -35,3 +80,66 @@ func NewVaultProvider(cfg config.Config) (VaultProvider, error) {
 		return nil, errors.New("unsupported vault provider")
 	}
 }
+
+// VaultID returns the ID the vault selected by the configuration is bound to,
+// or an empty string for providers without one.
+func VaultID(cfg *config.Config) string {
+	switch {
+	case cfg.Provider == config.FileProvider && cfg.FileVault != nil:
+		return fileVaultID(cfg.FileVault)
+	case cfg.Provider == config.ObjectStorageProvider && cfg.ObjectStorageVault != nil:
+		return objectStorageVaultID(cfg.ObjectStorageVault)
+	}
+	return ""
+}
+
+// kdfParams converts the KDF configuration into crypto parameters.
+func kdfParams(c *config.KDFConfig) (crypto.KDFParams, error) {
+	if c == nil {
+		return crypto.DefaultKDFParams, nil
+	}
+
+	var params crypto.KDFParams
+	switch c.Algorithm {
+	case "", "argon2id":
+		params = crypto.DefaultKDFParams
+		params.Algorithm = crypto.KDFArgon2id
+		if c.Time != 0 {
+			params.Time = c.Time
+		}
+		if c.Memory != 0 {
+			params.Memory = c.Memory
+		}
+		if c.Threads != 0 {
+			params.Threads = c.Threads
+		}
+	case "scrypt":
+		params = crypto.DefaultScryptParams
+		if c.LogN != 0 {
+			params.LogN = c.LogN
+		}
+		if c.R != 0 {
+			params.R = c.R
+		}
+		if c.P != 0 {
+			params.P = c.P
+		}
+	default:
+		return crypto.KDFParams{}, fmt.Errorf("unsupported kdf algorithm: %s", c.Algorithm)
+	}
+
+	if err := params.Validate(); err != nil {
+		return crypto.KDFParams{}, err
+	}
+
+	return params, nil
+}
+
+// historySize returns the number of previous versions to keep per secret, a
+// negative value disables history.
+func historySize(size int) int {
+	if size == 0 {
+		return defaultHistorySize
+	}
+	return max(size, 0)
+}
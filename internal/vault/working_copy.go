@@ -0,0 +1,99 @@
+package vault
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"slices"
+	"time"
+)
+
+// defaultUnlockDuration is how long Unlock keeps the vault unlocked.
+const defaultUnlockDuration = 15 * time.Minute
+
+// ErrStaleWorkingCopy is returned when locking a working copy would overwrite
+// changes made to the vault since it was unlocked.
+var ErrStaleWorkingCopy = errors.New("the vault changed since it was unlocked")
+
+// ErrVaultUnlocked is returned by operations replacing the whole vault while
+// a working copy is in use.
+var ErrVaultUnlocked = errors.New("the vault is unlocked, lock it first")
+
+// WorkingCopy describes an unlocked vault.
+type WorkingCopy struct {
+	Path    string
+	Expires time.Time
+	// Stale is set if the vault changed since it was unlocked.
+	Stale bool
+}
+
+// WorkingCopyProvider is implemented by providers that can be unlocked for
+// editing, reads and writes then go to a working copy of the vault until it
+// is locked again. The working copy expires, it is locked again by the first
+// operation after its expiry.
+type WorkingCopyProvider interface {
+	VaultUnlockFor(d time.Duration) (*WorkingCopy, error)
+	VaultLockWorkingCopy(force bool) error
+	VaultDiscardWorkingCopy() error
+	// VaultWorkingCopy returns nil if the vault is locked.
+	VaultWorkingCopy() (*WorkingCopy, error)
+}
+
+// digest identifies the contents of a vault, to tell whether it changed
+// while unlocked.
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// unlocked reports whether the document is the working copy of a vault.
+func (d *vaultDocument) unlocked() bool {
+	return d.UnlockedUntil != nil
+}
+
+// expired reports whether the working copy is past its expiry.
+func (d *vaultDocument) expired(now time.Time) bool {
+	return d.UnlockedUntil != nil && !now.Before(*d.UnlockedUntil)
+}
+
+// stale reports whether the vault changed since the working copy was taken,
+// working copies left by older versions don't record it.
+func (d *vaultDocument) stale(contents []byte) bool {
+	return d.UnlockedFrom != "" && d.UnlockedFrom != digest(contents)
+}
+
+// unlock turns the document into a working copy of the vault contents.
+func (d *vaultDocument) unlock(contents []byte, expires time.Time) {
+	if d.UnlockedFrom == "" {
+		d.UnlockedFrom = digest(contents)
+	}
+	d.UnlockedUntil = &expires
+}
+
+// applyWorkingCopy replaces the contents of the document with the ones of a
+// working copy, the entries left unchanged keep their sealed values so only
+// the edited secrets change in the vault.
+func (d *vaultDocument) applyWorkingCopy(wc *vaultDocument) error {
+	previous := &vaultDocument{Entries: d.Entries}
+	d.Entries = nil
+	for _, entry := range wc.Entries {
+		current := previous.entry(entry.Key)
+		if sameEntry(current, &entry) && len(current.History) == len(entry.History) {
+			d.Entries = append(d.Entries, *current)
+			continue
+		}
+		d.replace(entry)
+	}
+
+	// Members removed while unlocked must not be able to unwrap new keys
+	if slices.Equal(d.Recipients, wc.Recipients) {
+		return nil
+	}
+	d.Recipients = slices.Clone(wc.Recipients)
+	return d.rotate()
+}
+
+// workingCopy describes the working copy of a vault stored at path.
+func (d *vaultDocument) workingCopy(path string, contents []byte) *WorkingCopy {
+	return &WorkingCopy{Path: path, Expires: *d.UnlockedUntil, Stale: d.stale(contents)}
+}
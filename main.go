@@ -23,8 +23,13 @@ package main
 
 import (
 	"github.com/a13labs/sectool/cmd"
+	_ "github.com/a13labs/sectool/cmd/agent"
+	_ "github.com/a13labs/sectool/cmd/audit"
 	_ "github.com/a13labs/sectool/cmd/exec"
+	_ "github.com/a13labs/sectool/cmd/file"
+	_ "github.com/a13labs/sectool/cmd/git"
 	_ "github.com/a13labs/sectool/cmd/ssh"
+	_ "github.com/a13labs/sectool/cmd/values"
 	_ "github.com/a13labs/sectool/cmd/vault"
 )
 
@@ -22,11 +22,15 @@ THE SOFTWARE.
 package vault
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"github.com/a13labs/sectool/internal/agent"
+	"github.com/a13labs/sectool/internal/audit"
 	"github.com/a13labs/sectool/internal/config"
+	"github.com/a13labs/sectool/internal/crypto"
 	"github.com/a13labs/sectool/internal/vault"
 )
 
@@ -37,14 +41,15 @@ func GetSecret(path string, key string) (string, error) {
 		return "", err
 	}
 
-	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
 	if err != nil {
 		fmt.Println("Error initializing vault provider.")
 		return "", err
 	}
 	raw_value, err := vaultProvider.VaultGetValue(key)
+	err = audit.Record(cfg, "get", []string{key}, err)
 	if err != nil {
-		fmt.Println("Error getting value.")
+		fmt.Printf("Error getting value: %v\n", err)
 		return "", err
 	}
 
@@ -58,15 +63,16 @@ func DeleteSecret(path string, key string) error {
 		return err
 	}
 
-	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
 	if err != nil {
 		fmt.Println("Error initializing vault provider.")
 		return err
 	}
 
 	err = vaultProvider.VaultDelKey(key)
+	err = audit.Record(cfg, "delete", []string{key}, err)
 	if err != nil {
-		fmt.Println("Error deleting key/value.")
+		fmt.Printf("Error deleting key/value: %v\n", err)
 		return err
 	}
 
@@ -80,55 +86,759 @@ func ListSecrets(path string) ([]string, error) {
 		return nil, err
 	}
 
-	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
 	if err != nil {
 		fmt.Println("Error initializing vault provider.")
 		return nil, err
 	}
-	return vaultProvider.VaultListKeys(), nil
+
+	keys := vaultProvider.VaultListKeys()
+	if err := audit.Record(cfg, "list", nil, nil); err != nil {
+		fmt.Printf("Error listing keys: %v\n", err)
+		return nil, err
+	}
+	return keys, nil
+}
+
+func ListSecretsMetadata(path string) (map[string]vault.SecretMetadata, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
+	}
+
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	metadataProvider, ok := vaultProvider.(vault.MetadataProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support secret metadata.")
+		return nil, vault.ErrNotSupported
+	}
+
+	metadata, err := metadataProvider.VaultListMetadata()
+	err = audit.Record(cfg, "list-metadata", nil, err)
+	if err != nil {
+		fmt.Printf("Error listing metadata: %v\n", err)
+		return nil, err
+	}
+
+	return metadata, nil
 }
 
 func SetSecret(path string, key string, value string, backup bool) error {
+	return SetSecretWithMetadata(path, key, value, vault.SecretMetadata{}, backup)
+}
+
+func SetSecretWithMetadata(path string, key string, value string, meta vault.SecretMetadata, backup bool) error {
+	if err := vault.ValidateKey(key); err != nil {
+		fmt.Printf("Invalid key: %v\n", err)
+		return err
+	}
+
 	cfg, err := config.ReadConfig(path)
 	if err != nil {
 		fmt.Printf("Error reading config file: %v\n", err)
 		return err
 	}
 
-	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
 	if err != nil {
 		fmt.Println("Error initializing vault provider.")
 		return err
 	}
 
-	vaultProvider.VaultEnableBackup(backup)
-	// if key starts with "file://", read from file
-	if len(key) > 7 && key[:7] == "file://" {
-		if _, err := os.Stat(key[7:]); os.IsNotExist(err) {
+	if backup {
+		vaultProvider.VaultEnableBackup(true)
+	}
+	// if value starts with "file://", read from file
+	if len(value) > 7 && value[:7] == "file://" {
+		if _, err := os.Stat(value[7:]); os.IsNotExist(err) {
 			fmt.Println("File does not exist.")
 			return err
 		}
-		v, err := os.ReadFile(key[7:])
+		v, err := os.ReadFile(value[7:])
 		if err != nil {
 			fmt.Println("Error reading file.")
 			return err
 		}
-		key = string(v)
+		value = string(v)
 	}
-	// if key is "stdin://", read from stdin
-	if key == "stdin://" {
+	// if value is "stdin://", read from stdin
+	if value == "stdin://" {
 		v, err := io.ReadAll(os.Stdin)
 		if err != nil {
 			fmt.Println("Error reading stdin:", err)
 			return err
 		}
-		key = string(v)
+		value = string(v)
+	}
+
+	if metadataProvider, ok := vaultProvider.(vault.MetadataProvider); ok {
+		err = metadataProvider.VaultSetValueWithMetadata(key, value, meta)
+	} else if !meta.IsEmpty() {
+		fmt.Println("Vault provider does not support secret metadata.")
+		return vault.ErrNotSupported
+	} else {
+		err = vaultProvider.VaultSetValue(key, value)
+	}
+	err = audit.Record(cfg, "set", []string{key}, err)
+	if err != nil {
+		fmt.Printf("Error setting key/value: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func MoveSecrets(path string, src string, dst string, backup bool) (moved map[string]string, err error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
+	}
+
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	renames, err := vault.MoveKeys(vaultProvider.VaultListKeys(), src, dst)
+	if err != nil {
+		fmt.Printf("Error moving keys: %v\n", err)
+		return nil, err
+	}
+
+	if backup {
+		vaultProvider.VaultEnableBackup(true)
+	}
+
+	// Both the old and the new keys are recorded
+	keys := make([]string, 0, 2*len(renames))
+	for from, to := range renames {
+		keys = append(keys, from, to)
+	}
+	defer func() { err = audit.Record(cfg, "move", keys, err) }()
+
+	// The agent forwards renames, it fails if its vault can't rename
+	if renameProvider, ok := vaultProvider.(vault.RenameProvider); ok {
+		err = renameProvider.VaultRenameKeys(renames)
+		if err == nil {
+			return renames, nil
+		}
+		if !errors.Is(err, vault.ErrNotSupported) {
+			fmt.Printf("Error moving keys: %v\n", err)
+			return nil, err
+		}
+	}
+
+	// Copy each key and delete the original, for providers that can't rename
+	metadataProvider, hasMetadata := vaultProvider.(vault.MetadataProvider)
+	for from, to := range renames {
+		var value string
+		value, err = vaultProvider.VaultGetValue(from)
+		if err != nil {
+			fmt.Printf("Error getting value: %v\n", err)
+			return nil, err
+		}
+
+		if hasMetadata {
+			var meta vault.SecretMetadata
+			meta, err = metadataProvider.VaultGetMetadata(from)
+			if err == nil {
+				err = metadataProvider.VaultSetValueWithMetadata(to, value, meta)
+			}
+		} else {
+			err = vaultProvider.VaultSetValue(to, value)
+		}
+		if err != nil {
+			fmt.Printf("Error setting key/value: %v\n", err)
+			return nil, err
+		}
+
+		err = vaultProvider.VaultDelKey(from)
+		if err != nil {
+			fmt.Printf("Error deleting key/value: %v\n", err)
+			return nil, err
+		}
+	}
+
+	return renames, nil
+}
+
+func DescribeSecret(path string, key string) (vault.SecretMetadata, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return vault.SecretMetadata{}, err
+	}
+
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return vault.SecretMetadata{}, err
+	}
+
+	metadataProvider, ok := vaultProvider.(vault.MetadataProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support secret metadata.")
+		return vault.SecretMetadata{}, vault.ErrNotSupported
+	}
+
+	meta, err := metadataProvider.VaultGetMetadata(key)
+	err = audit.Record(cfg, "describe", []string{key}, err)
+	if err != nil {
+		fmt.Printf("Error getting metadata: %v\n", err)
+		return vault.SecretMetadata{}, err
+	}
+
+	return meta, nil
+}
+
+func RekeyVault(path string, oldKey string, newKey string, purgeBackups bool, dropMembers bool) ([]string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
+	}
+
+	// The agent holds the vault with the current key
+	if err := agent.Stop(*cfg); err != nil {
+		fmt.Printf("Error locking the agent: %v\n", err)
+		return nil, err
+	}
+
+	if oldKey != "" {
+		setVaultKey(cfg, oldKey)
+	}
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	rekeyProvider, ok := vaultProvider.(vault.RekeyProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support rekeying.")
+		return nil, errors.New("vault provider does not support rekeying")
+	}
+
+	report, err := rekeyProvider.Rekey([]byte(newKey), purgeBackups, dropMembers)
+	err = audit.Record(cfg, "rekey", nil, err)
+	if errors.Is(err, vault.ErrVaultHasMembers) {
+		fmt.Println("The vault has members, members remove rotates its key. Run with --drop-members to switch it back to a key.")
+		return nil, err
+	}
+	if err != nil {
+		fmt.Println("Error rekeying vault.")
+		return report, err
+	}
+
+	return report, nil
+}
+
+// MigrateVault binds the vault and its backups, written before they were
+// bound to the vault context, to it. It returns the list of rewritten files.
+func MigrateVault(path string) ([]string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
 	}
-	err = vaultProvider.VaultSetValue(key, value)
+
+	// The agent can't open the vault until it is migrated
+	if err := agent.Stop(*cfg); err != nil {
+		fmt.Printf("Error locking the agent: %v\n", err)
+		return nil, err
+	}
+
+	// A vault with members has no key, its members open it
+	if key, err := vaultKey(cfg); err == nil {
+		setVaultKey(cfg, key)
+	}
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	migrateProvider, ok := vaultProvider.(vault.MigrateProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support migrating.")
+		return nil, vault.ErrNotSupported
+	}
+
+	report, err := migrateProvider.VaultMigrate()
+	err = audit.Record(cfg, "migrate", nil, err)
+	if err != nil {
+		fmt.Println("Error migrating vault.")
+		return report, err
+	}
+
+	return report, nil
+}
+
+func GetSecretHistory(path string, key string) ([]vault.SecretVersion, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
+	}
+
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
 	if err != nil {
-		fmt.Println("Error setting key/value.")
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	historyProvider, ok := vaultProvider.(vault.HistoryProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support secret history.")
+		return nil, vault.ErrNotSupported
+	}
+
+	versions, err := historyProvider.VaultKeyHistory(key)
+	err = audit.Record(cfg, "history", []string{key}, err)
+	if err != nil {
+		fmt.Printf("Error getting history: %v\n", err)
+		return nil, err
+	}
+
+	return versions, nil
+}
+
+func RollbackSecret(path string, key string, version int, backup bool) error {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return err
+	}
+
+	vaultProvider, err := agent.OpenVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return err
+	}
+
+	historyProvider, ok := vaultProvider.(vault.HistoryProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support secret history.")
+		return vault.ErrNotSupported
+	}
+
+	if backup {
+		vaultProvider.VaultEnableBackup(true)
+	}
+	err = historyProvider.VaultRollback(key, version)
+	err = audit.Record(cfg, "rollback", []string{key}, err)
+	if err != nil {
+		fmt.Printf("Error rolling back key: %v\n", err)
 		return err
 	}
 
 	return nil
 }
+
+// newBackupProvider opens the vault without the agent, restoring a backup
+// replaces the vault as a whole and the agent reloads it on its next request.
+func newBackupProvider(path string) (*config.Config, vault.BackupProvider, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, nil, err
+	}
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, nil, err
+	}
+
+	backupProvider, ok := vaultProvider.(vault.BackupProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support backups.")
+		return nil, nil, vault.ErrNotSupported
+	}
+
+	return cfg, backupProvider, nil
+}
+
+func ListBackups(path string) ([]vault.Backup, error) {
+	cfg, backupProvider, err := newBackupProvider(path)
+	if err != nil {
+		return nil, err
+	}
+
+	backups, err := backupProvider.VaultListBackups()
+	err = audit.Record(cfg, "backup-list", nil, err)
+	if err != nil {
+		fmt.Printf("Error listing backups: %v\n", err)
+		return nil, err
+	}
+
+	return backups, nil
+}
+
+func RestoreBackup(path string, name string) error {
+	cfg, backupProvider, err := newBackupProvider(path)
+	if err != nil {
+		return err
+	}
+
+	err = backupProvider.VaultRestoreBackup(name)
+	err = audit.Record(cfg, "backup-restore", nil, err)
+	if err != nil {
+		fmt.Printf("Error restoring backup: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func PruneBackups(path string, policy *vault.RetentionPolicy) ([]string, error) {
+	cfg, backupProvider, err := newBackupProvider(path)
+	if err != nil {
+		return nil, err
+	}
+
+	removed, err := backupProvider.VaultPruneBackups(policy)
+	err = audit.Record(cfg, "backup-prune", nil, err)
+	if err != nil {
+		fmt.Printf("Error pruning backups: %v\n", err)
+		return removed, err
+	}
+
+	return removed, nil
+}
+
+// newMembersProvider opens the vault without the agent, changing the members
+// rotates the key-encryption key and the agent reloads the vault on its next
+// request.
+func newMembersProvider(path string) (*config.Config, vault.MembersProvider, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, nil, err
+	}
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, nil, err
+	}
+
+	membersProvider, ok := vaultProvider.(vault.MembersProvider)
+	if !ok {
+		fmt.Println("Vault provider does not support members.")
+		return nil, nil, vault.ErrNotSupported
+	}
+
+	return cfg, membersProvider, nil
+}
+
+func ListMembers(path string) ([]string, error) {
+	cfg, membersProvider, err := newMembersProvider(path)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := membersProvider.VaultListMembers()
+	err = audit.Record(cfg, "members-list", nil, err)
+	if err != nil {
+		fmt.Printf("Error listing members: %v\n", err)
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func AddMembers(path string, recipients []string) error {
+	cfg, membersProvider, err := newMembersProvider(path)
+	if err != nil {
+		return err
+	}
+
+	err = membersProvider.VaultAddMembers(recipients)
+	err = audit.Record(cfg, "members-add", nil, err)
+	if err != nil {
+		fmt.Printf("Error adding members: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+func RemoveMembers(path string, recipients []string) error {
+	cfg, membersProvider, err := newMembersProvider(path)
+	if err != nil {
+		return err
+	}
+
+	err = membersProvider.VaultRemoveMembers(recipients)
+	err = audit.Record(cfg, "members-remove", nil, err)
+	if err != nil {
+		fmt.Printf("Error removing members: %v\n", err)
+		return err
+	}
+
+	return nil
+}
+
+// setVaultKey replaces the configured vault key.
+func setVaultKey(cfg *config.Config, key string) {
+	switch cfg.Provider {
+	case config.FileProvider:
+		if cfg.FileVault != nil {
+			fileVault := *cfg.FileVault
+			fileVault.Key = key
+			cfg.FileVault = &fileVault
+		}
+	case config.ObjectStorageProvider:
+		if cfg.ObjectStorageVault != nil {
+			objectStorageVault := *cfg.ObjectStorageVault
+			objectStorageVault.Key = key
+			cfg.ObjectStorageVault = &objectStorageVault
+		}
+	}
+}
+
+// SSHAgentKey returns the vault key derived from the ssh-agent key configured
+// for the vault.
+func SSHAgentKey(path string) (string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return "", err
+	}
+
+	_, agentConfig := keySources(cfg)
+	if agentConfig == nil {
+		fmt.Println("No ssh-agent key is configured for the vault.")
+		return "", errors.New("no ssh-agent key is configured")
+	}
+
+	key, err := vault.AgentVaultKey(agentConfig, vault.VaultID(cfg))
+	if err != nil {
+		fmt.Printf("Error deriving key from ssh-agent: %v\n", err)
+		return "", err
+	}
+
+	return key, nil
+}
+
+// keySources returns the configured vault key and ssh-agent key.
+func keySources(cfg *config.Config) (string, *config.SSHAgentConfig) {
+	switch cfg.Provider {
+	case config.FileProvider:
+		if cfg.FileVault != nil {
+			return cfg.FileVault.Key, cfg.FileVault.SSHAgent
+		}
+	case config.ObjectStorageProvider:
+		if cfg.ObjectStorageVault != nil {
+			return cfg.ObjectStorageVault.Key, cfg.ObjectStorageVault.SSHAgent
+		}
+	}
+	return "", nil
+}
+
+// vaultKey returns the configured vault key, the one derived from the
+// configured ssh-agent key, or the one set in the FILE_VAULT_KEY environment
+// variable.
+func vaultKey(cfg *config.Config) (string, error) {
+	key, agentConfig := keySources(cfg)
+	if key == "" && agentConfig != nil {
+		return vault.AgentVaultKey(agentConfig, vault.VaultID(cfg))
+	}
+	if key == "" {
+		key = os.Getenv("FILE_VAULT_KEY")
+	}
+	if key == "" {
+		return "", errors.New("vault key is not defined")
+	}
+	return key, nil
+}
+
+// VaultKey returns the key of the vault selected by the configuration.
+func VaultKey(path string) (string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return "", err
+	}
+
+	key, err := vaultKey(cfg)
+	err = audit.Record(cfg, "key", nil, err)
+	if err != nil {
+		fmt.Printf("Error reading vault key: %v\n", err)
+		return "", err
+	}
+
+	return key, nil
+}
+
+// SplitKey splits the vault key, the configured one unless key is given, into
+// Shamir shares, threshold of which rebuild it. The key is checked against the
+// vault first.
+func SplitKey(path string, key string, shares int, threshold int) ([]string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return nil, err
+	}
+
+	if key == "" {
+		key, err = vaultKey(cfg)
+		if err != nil {
+			fmt.Printf("Error reading vault key: %v\n", err)
+			return nil, err
+		}
+	}
+	setVaultKey(cfg, key)
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Println("Error initializing vault provider.")
+		return nil, err
+	}
+
+	// Reading no keys still decrypts the vault
+	err = vaultProvider.VaultGetMultipleValues(nil, crypto.NewSecureKVStore(crypto.NewKeyManager()))
+	err = audit.Record(cfg, "split-key", nil, err)
+	if err != nil {
+		fmt.Printf("Key does not open the vault: %v\n", err)
+		return nil, err
+	}
+
+	split, err := crypto.SplitSecret([]byte(key), shares, threshold)
+	if err != nil {
+		fmt.Printf("Error splitting key: %v\n", err)
+		return nil, err
+	}
+
+	texts := make([]string, len(split))
+	for i, share := range split {
+		texts[i] = share.String()
+	}
+	return texts, nil
+}
+
+// CombineKey rebuilds a vault key from its Shamir shares, errors are printed
+// to stderr as the key is printed to stdout.
+func CombineKey(path string, shares []string) (string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
+		return "", err
+	}
+
+	parsed := make([]crypto.Share, 0, len(shares))
+	for i, text := range shares {
+		share, err := crypto.ParseShare(text)
+		if err != nil {
+			err = audit.Record(cfg, "combine-key", nil, err)
+			fmt.Fprintf(os.Stderr, "Error parsing share %d: %v\n", i+1, err)
+			return "", err
+		}
+		parsed = append(parsed, share)
+	}
+
+	key, err := crypto.CombineShares(parsed)
+	err = audit.Record(cfg, "combine-key", nil, err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error combining shares: %v\n", err)
+		return "", err
+	}
+
+	return string(key), nil
+}
+
+func newGitProvider(path string) (*config.Config, vault.GitProvider, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading config file: %v\n", err)
+		return nil, nil, err
+	}
+
+	vaultProvider, err := vault.NewVaultProvider(*cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error initializing vault provider.")
+		return nil, nil, err
+	}
+
+	gitProvider, ok := vaultProvider.(vault.GitProvider)
+	if !ok {
+		fmt.Fprintln(os.Stderr, "Vault provider does not support git drivers.")
+		return nil, nil, vault.ErrNotSupported
+	}
+
+	return cfg, gitProvider, nil
+}
+
+// TextConvVaultFile returns the text representation git diffs for a copy of
+// the vault, values are replaced by fingerprints.
+func TextConvVaultFile(path string, file string) (string, error) {
+	cfg, gitProvider, err := newGitProvider(path)
+	if err != nil {
+		return "", err
+	}
+
+	text, err := gitProvider.VaultTextConv(file)
+	err = audit.Record(cfg, "git-textconv", nil, err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error decrypting vault file: %v\n", err)
+		return "", err
+	}
+
+	return text, nil
+}
+
+// MergeVaultFiles merges three copies of the vault into ours, returning the
+// conflicting keys.
+func MergeVaultFiles(path string, base, ours, theirs string) ([]string, error) {
+	cfg, gitProvider, err := newGitProvider(path)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts, err := gitProvider.VaultMerge(base, ours, theirs)
+	err = audit.Record(cfg, "git-merge", conflicts, err)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error merging vault files: %v\n", err)
+		return nil, err
+	}
+
+	return conflicts, nil
+}
+
+// VaultPath returns the path of the file vault.
+func VaultPath(path string) (string, error) {
+	cfg, err := config.ReadConfig(path)
+	if err != nil {
+		fmt.Printf("Error reading config file: %v\n", err)
+		return "", err
+	}
+
+	if cfg.Provider != config.FileProvider {
+		fmt.Println("Only file vaults are stored in the repository.")
+		return "", vault.ErrNotSupported
+	}
+
+	vaultPath := ""
+	if cfg.FileVault != nil {
+		vaultPath = cfg.FileVault.Path
+	}
+	if vaultPath == "" {
+		vaultPath, _ = os.LookupEnv("FILE_VAULT_PATH")
+		if vaultPath == "" {
+			vaultPath = "repository.vault"
+		}
+	}
+
+	return vaultPath, nil
+}